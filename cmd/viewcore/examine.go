@@ -0,0 +1,268 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/debug/internal/core"
+	"golang.org/x/debug/internal/gocore"
+)
+
+// An examineFormat says how the examine command shows memory, as in
+// gdb's x/<count><format><size>.
+type examineFormat struct {
+	count  int
+	format byte  // x, d, u, o, t, a, c or s
+	size   int64 // 1, 2, 4 or 8 bytes
+}
+
+// parseExamineFormat parses the part of x/<count><format><size> after
+// the slash. The format and size letters may come in either order.
+// The default is one word of the pointer size in hex.
+func parseExamineFormat(s string, ptrSize int64) (examineFormat, error) {
+	f := examineFormat{count: 1, format: 'x'}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i > 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil || n <= 0 {
+			return f, fmt.Errorf("bad count %q", s[:i])
+		}
+		f.count = n
+	}
+	for _, c := range []byte(s[i:]) {
+		switch c {
+		case 'x', 'd', 'u', 'o', 't', 'a', 'c', 's':
+			f.format = c
+		case 'b':
+			f.size = 1
+		case 'h':
+			f.size = 2
+		case 'w':
+			f.size = 4
+		case 'g':
+			f.size = 8
+		default:
+			return f, fmt.Errorf("unknown format or size letter %q in %q", c, s)
+		}
+	}
+	switch {
+	case f.format == 'a':
+		f.size = ptrSize
+	case f.format == 'c' || f.format == 's':
+		f.size = 1
+	case f.size == 0:
+		f.size = ptrSize
+	}
+	return f, nil
+}
+
+// An examineLine is a line of the output of the examine command.
+type examineLine struct {
+	addr   core.Address
+	values []examineValue
+}
+
+// An examineValue is a unit of memory shown by the examine command.
+type examineValue struct {
+	addr core.Address
+	text string
+
+	// For the a format, the address held and what it points into.
+	ptr core.Address
+	loc location
+}
+
+// examine reads f.count units of memory at a, and returns them grouped
+// in lines as gdb shows them. If some memory isn't readable, it returns
+// the lines read so far and an error.
+func examine(p *core.Process, c *gocore.Process, a core.Address, f examineFormat) ([]examineLine, error) {
+	perLine := 16 / int(f.size)
+	switch f.format {
+	case 't':
+		perLine = 8 / int(f.size)
+	case 'a', 's':
+		perLine = 1
+	}
+	if perLine < 1 {
+		perLine = 1
+	}
+	var lines []examineLine
+	for i := 0; i < f.count; i++ {
+		v, n, err := examineUnit(p, c, a, f)
+		if err != nil {
+			return lines, err
+		}
+		if i%perLine == 0 {
+			lines = append(lines, examineLine{addr: a})
+		}
+		l := &lines[len(lines)-1]
+		l.values = append(l.values, v)
+		a = a.Add(n)
+	}
+	return lines, nil
+}
+
+// examineUnit reads the unit of memory at a, and returns it and its size.
+func examineUnit(p *core.Process, c *gocore.Process, a core.Address, f examineFormat) (examineValue, int64, error) {
+	v := examineValue{addr: a}
+	if f.format == 's' {
+		// A NUL-terminated string. A string without a NUL in its
+		// first 4096 bytes is cut there, and the next one starts
+		// right after.
+		var b []byte
+		for len(b) < 4096 {
+			if !p.Readable(a.Add(int64(len(b)))) {
+				return v, 0, fmt.Errorf("address %x is not readable", a.Add(int64(len(b))))
+			}
+			x := p.ReadUint8(a.Add(int64(len(b))))
+			if x == 0 {
+				v.text = strconv.Quote(string(b))
+				return v, int64(len(b)) + 1, nil
+			}
+			b = append(b, x)
+		}
+		v.text = strconv.Quote(string(b))
+		return v, int64(len(b)), nil
+	}
+	if !p.ReadableN(a, f.size) {
+		return v, 0, fmt.Errorf("address %x is not readable", a)
+	}
+	var x uint64
+	switch f.size {
+	case 1:
+		x = uint64(p.ReadUint8(a))
+	case 2:
+		x = uint64(p.ReadUint16(a))
+	case 4:
+		x = uint64(p.ReadUint32(a))
+	case 8:
+		x = p.ReadUint64(a)
+	}
+	bits := 8 * f.size
+	switch f.format {
+	case 'x':
+		v.text = fmt.Sprintf("0x%0*x", 2*f.size, x)
+	case 'd':
+		// Sign extend.
+		v.text = fmt.Sprint(int64(x<<(64-bits)) >> (64 - bits))
+	case 'u':
+		v.text = fmt.Sprint(x)
+	case 'o':
+		v.text = fmt.Sprintf("0%o", x)
+	case 't':
+		v.text = fmt.Sprintf("%0*b", bits, x)
+	case 'c':
+		if x < 0x80 {
+			v.text = fmt.Sprintf("%d %s", x, strconv.QuoteRuneToASCII(rune(x)))
+		} else {
+			v.text = fmt.Sprintf("%d '\\x%02x'", x, x)
+		}
+	case 'a':
+		v.ptr = core.Address(x)
+		v.text = fmt.Sprintf("0x%x", x)
+		if x != 0 {
+			v.loc = locate(c, v.ptr)
+		}
+	}
+	return v, f.size, nil
+}
+
+func runExamine(cmd *cobra.Command, args []string) error {
+	p, c, err := readCore()
+	if err != nil {
+		return err
+	}
+	f := examineFormat{count: 1, format: 'x', size: p.PtrSize()}
+	if strings.HasPrefix(args[0], "/") {
+		if f, err = parseExamineFormat(args[0][1:], p.PtrSize()); err != nil {
+			return err
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing address")
+	}
+	expr := strings.Join(args, " ")
+	a, err := newEvaluator(p, c).address(expr)
+	if err != nil {
+		return fmt.Errorf("can't evaluate %q as an address: %v", expr, err)
+	}
+	lines, err := examine(p, c, a, f)
+
+	if structuredOutput() {
+		rw := newRecordWriter("addr", "symbol", "value", "points_to")
+		for _, l := range lines {
+			for _, v := range l.values {
+				rw.write(v.addr, symbolName(p, v.addr), v.text, v.loc.String())
+			}
+		}
+		rw.flush()
+		return err
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, l := range lines {
+		fmt.Fprintf(t, "%x", l.addr)
+		if s := symbolName(p, l.addr); s != "" {
+			fmt.Fprintf(t, " <%s>", s)
+		}
+		fmt.Fprintf(t, ":")
+		for _, v := range l.values {
+			fmt.Fprintf(t, "\t%s", v.text)
+			if s := v.loc.String(); s != "" {
+				fmt.Fprintf(t, "\t%s", s)
+			}
+		}
+		fmt.Fprintf(t, "\n")
+	}
+	t.Flush()
+	return err
+}
+
+// splitExamine splits the examine command name and its format, as in
+// x/4xg, into separate arguments, x and /4xg, for the command parser.
+// The command is the first argument that isn't a flag of root or the
+// value of one.
+func splitExamine(root *cobra.Command, args []string) []string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			if !strings.HasPrefix(arg, "x/") {
+				return args
+			}
+			split := append([]string{}, args[:i]...)
+			split = append(split, "x", arg[1:])
+			return append(split, args[i+1:]...)
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		var f *pflag.Flag
+		if name := strings.TrimPrefix(arg, "--"); name != arg {
+			if f = root.Flags().Lookup(name); f == nil {
+				f = root.PersistentFlags().Lookup(name)
+			}
+		} else if name := arg[1:]; len(name) == 1 {
+			if f = root.Flags().ShorthandLookup(name); f == nil {
+				f = root.PersistentFlags().ShorthandLookup(name)
+			}
+		}
+		if f != nil && f.NoOptDefVal == "" {
+			i++ // the flag's value
+		}
+	}
+	return args
+}
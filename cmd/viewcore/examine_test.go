@@ -0,0 +1,146 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/debug/internal/core"
+)
+
+func TestParseExamineFormat(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want examineFormat
+		err  string
+	}{
+		{in: "", want: examineFormat{count: 1, format: 'x', size: 8}},
+		{in: "4xg", want: examineFormat{count: 4, format: 'x', size: 8}},
+		{in: "4gx", want: examineFormat{count: 4, format: 'x', size: 8}},
+		{in: "16xb", want: examineFormat{count: 16, format: 'x', size: 1}},
+		{in: "dh", want: examineFormat{count: 1, format: 'd', size: 2}},
+		{in: "3uw", want: examineFormat{count: 3, format: 'u', size: 4}},
+		{in: "t", want: examineFormat{count: 1, format: 't', size: 8}},
+		// Pointers have the pointer size, and characters and strings
+		// are made of bytes, whatever the size letter.
+		{in: "2a", want: examineFormat{count: 2, format: 'a', size: 8}},
+		{in: "ab", want: examineFormat{count: 1, format: 'a', size: 8}},
+		{in: "cg", want: examineFormat{count: 1, format: 'c', size: 1}},
+		{in: "s", want: examineFormat{count: 1, format: 's', size: 1}},
+
+		{in: "0x", err: `bad count "0"`},
+		{in: "99999999999999999999x", err: "bad count"},
+		{in: "4q", err: `unknown format or size letter 'q' in "4q"`},
+		{in: "x4", err: `unknown format or size letter '4'`},
+	} {
+		got, err := parseExamineFormat(test.in, 8)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseExamineFormat(%q): got error %v, want %q", test.in, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseExamineFormat(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseExamineFormat(%q) = %+v, want %+v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestSplitExamine(t *testing.T) {
+	for _, test := range []struct {
+		args, want string
+	}{
+		{"x/4xg c000123450", "x /4xg c000123450"},
+		{"x /4xg c000123450", "x /4xg c000123450"},
+		{"x c000123450", "x c000123450"},
+		{"print x/2", "print x/2"},
+		{"--exe /tmp/test x/s main.version", "--exe /tmp/test x /s main.version"},
+		{"--exe=/tmp/test x/s main.version", "--exe=/tmp/test x /s main.version"},
+		// The value of a flag isn't the command, even if it looks like one.
+		{"--base x/y x/2a runtime.allgs", "--base x/y x /2a runtime.allgs"},
+		{"-c x/2a", "-c x/2a"},
+		// A boolean flag has no value.
+		{"--ignore-build-id x/2a runtime.allgs", "--ignore-build-id x /2a runtime.allgs"},
+	} {
+		got := splitExamine(cmdRoot, strings.Fields(test.args))
+		if want := strings.Fields(test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitExamine(%q) = %q, want %q", test.args, got, want)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	p, c := loadTestCore(t)
+	e := newEvaluator(p, c)
+	var allgs core.Address
+	for _, r := range c.Globals() {
+		if r.Name == "runtime.allgs" {
+			allgs = r.Addr
+		}
+	}
+	if allgs == 0 {
+		t.Fatal("no global runtime.allgs")
+	}
+	syms, err := p.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		expr string
+		want core.Address
+		err  string
+	}{
+		// Numbers are in Go syntax, so decimal unless they have a
+		// prefix, as in gdb.
+		{expr: "0x400000", want: 0x400000},
+		{expr: "400000", want: 400000},
+		{expr: " 10 ", want: 10},
+		{expr: "0b101", want: 5},
+		{expr: "0x400000+16", want: 0x400010},
+		{expr: "400000 - 0x10", want: 400000 - 0x10},
+		// Hex digits including a letter are hex without 0x, whether
+		// they start with a digit or not. Offsets are still in Go syntax.
+		{expr: "c000123450", want: 0xc000123450},
+		{expr: "7ffd1234", want: 0x7ffd1234},
+		{expr: "7FFD1234", want: 0x7ffd1234},
+		{expr: "7ffd1234+16", want: 0x7ffd1244},
+
+		// Globals and symbols stand for their addresses, and
+		// pointers for the address they hold.
+		{expr: "runtime.allgs", want: allgs},
+		{expr: "(runtime.allgs)", want: allgs},
+		{expr: "runtime.allgs+8", want: allgs + 8},
+		{expr: "runtime.main", want: syms["runtime.main"]},
+		{expr: "runtime.allgs[0]", want: p.ReadPtr(p.ReadPtr(allgs))},
+
+		{expr: "1.5", err: "not an integer"},
+		{expr: "nosuchname", err: "undefined: nosuchname"},
+		{expr: "400000 +", err: "expected operand"},
+	} {
+		got, err := e.address(test.expr)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("address(%q) = %#x, %v, want error containing %q", test.expr, got, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("address(%q): %v", test.expr, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("address(%q) = %#x, want %#x", test.expr, got, test.want)
+		}
+	}
+}
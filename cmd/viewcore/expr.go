@@ -0,0 +1,987 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/debug/internal/core"
+	"golang.org/x/debug/internal/gocore"
+)
+
+// An evaluator evaluates Go expressions against the memory of a core file.
+//
+// Supported expressions are a subset of Go:
+//
+//	pkg.Var.field[3].x   globals, locals, fields, indexing
+//	*ptr                 pointer indirection
+//	&x                   address of a variable
+//	(*T)(0xc000123000)   conversion of an address to a pointer
+//	len(s), cap(s)       length and capacity
+//	m["key"]             map index by constant key
+//	x+1, x == y, ...     arithmetic and comparisons on basic values
+//
+// Package paths containing slashes may be used as qualifiers, as in
+// net/http.DefaultClient. A global may also be named by its last package
+// path element alone (http.DefaultClient) if that is unambiguous.
+type evaluator struct {
+	p *core.Process
+	c *gocore.Process
+
+	// frame, if non-nil, is the stack frame whose local variables
+	// are in scope.
+	frame *gocore.Frame
+
+	// vars, if non-nil, resolves identifiers before local and global
+	// variables are consulted.
+	vars func(name string) (value, bool)
+
+	globals map[string]*gocore.Root // lazily initialized
+
+	// paths maps the placeholder identifiers substituted for package
+	// paths by parseExpr back to the paths.
+	paths map[string]string
+}
+
+// A value is the result of evaluating an expression.
+//
+// Values either live in the inferior's memory (c == nil), or are
+// constants (c != nil). Constants are untyped if typ is nil; typed
+// constants are used for pointers that aren't stored anywhere, like
+// the result of &x.
+type value struct {
+	typ *gocore.Type
+	a   core.Address // location of the value; only if c == nil
+	c   constant.Value
+}
+
+func newEvaluator(p *core.Process, c *gocore.Process) *evaluator {
+	return &evaluator{p: p, c: c}
+}
+
+// pathRE matches a package path containing a slash, followed by the
+// dot introducing a qualified name.
+var pathRE = regexp.MustCompile(`(?:[\w\-.]+/)+[\w\-]+\.`)
+
+// parseExpr parses the expression s. Package paths containing slashes
+// are replaced by placeholder identifiers, recorded in e.paths, before
+// handing s to the Go parser.
+func (e *evaluator) parseExpr(s string) (ast.Expr, error) {
+	var b strings.Builder
+	for len(s) > 0 {
+		// Copy string and character literals verbatim.
+		if q := s[0]; q == '"' || q == '\'' || q == '`' {
+			i := 1
+			for i < len(s) && s[i] != q {
+				if s[i] == '\\' && q != '`' {
+					i++
+				}
+				i++
+			}
+			if i < len(s) {
+				i++
+			}
+			b.WriteString(s[:i])
+			s = s[i:]
+			continue
+		}
+		i := strings.IndexAny(s, "\"'`")
+		if i < 0 {
+			i = len(s)
+		}
+		b.WriteString(pathRE.ReplaceAllStringFunc(s[:i], func(m string) string {
+			path := m[:len(m)-1]
+			if e.paths == nil {
+				e.paths = map[string]string{}
+			}
+			id := fmt.Sprintf("_path%d_", len(e.paths))
+			e.paths[id] = path
+			return id + "."
+		}))
+		s = s[i:]
+	}
+	return parser.ParseExpr(b.String())
+}
+
+// eval parses and evaluates the expression s.
+func (e *evaluator) eval(s string) (v value, err error) {
+	x, err := e.parseExpr(s)
+	if err != nil {
+		return value{}, err
+	}
+	// Reads of unmapped memory panic; report them as errors.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return e.evalExpr(x)
+}
+
+// address parses and evaluates the expression s as an address, as the
+// examine command takes them. Names of global variables and symbols
+// stand for their addresses, and bare hex numbers for themselves, so
+// that runtime.allgs+8, c000123450 and 400000 are addresses. Other
+// expressions are evaluated as by eval: pointers stand for the address
+// they hold, integers, in Go syntax, for themselves, and other values
+// for their location.
+func (e *evaluator) address(s string) (a core.Address, err error) {
+	s = strings.TrimSpace(s)
+	if isBareHex(s) {
+		// It wouldn't parse as a number.
+		s = "0x" + s
+	}
+	x, err := e.parseExpr(s)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return e.addressExpr(x)
+}
+
+// leadingNumber matches the run of hex digits at the start of an
+// expression starting with a digit, like 7ffd1234+8 or 400000, but
+// not 0x10 or 1.5.
+var leadingNumber = regexp.MustCompile(`^([0-9][0-9a-fA-F]*)(?:[^\w.]|$)`)
+
+// isBareHex reports whether the expression s starts with a hex number
+// without 0x, like 7ffd1234 or 7ffd1234+8. As in gdb, numbers are
+// otherwise decimal, so only a number made of hex digits including a
+// letter counts. Binary numbers, as in 0b101, are not taken for hex.
+func isBareHex(s string) bool {
+	m := leadingNumber.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	n := strings.ToLower(m[1])
+	return !strings.HasPrefix(n, "0b") && strings.ContainsAny(n, "abcdef")
+}
+
+func (e *evaluator) addressExpr(x ast.Expr) (core.Address, error) {
+	switch y := x.(type) {
+	case *ast.ParenExpr:
+		return e.addressExpr(y.X)
+	case *ast.BinaryExpr:
+		if y.Op == token.ADD || y.Op == token.SUB {
+			a, err := e.addressExpr(y.X)
+			if err != nil {
+				return 0, err
+			}
+			v, err := e.evalExpr(y.Y)
+			if err != nil {
+				return 0, err
+			}
+			n, err := e.intValue(v)
+			if err != nil {
+				return 0, err
+			}
+			if y.Op == token.SUB {
+				n = -n
+			}
+			return a.Add(n), nil
+		}
+	case *ast.Ident, *ast.SelectorExpr:
+		name, _ := e.qualifiedName(y)
+		if r := e.global(name); r != nil {
+			return r.Addr, nil
+		}
+		syms, _ := e.p.Symbols()
+		if a, ok := syms[name]; ok {
+			return a, nil
+		}
+		if id, ok := y.(*ast.Ident); ok {
+			if n, err := strconv.ParseUint(id.Name, 16, 64); err == nil {
+				return core.Address(n), nil
+			}
+		}
+	}
+	v, err := e.evalExpr(x)
+	if err != nil {
+		return 0, err
+	}
+	if v.c == nil && v.typ.Kind != gocore.KindPtr {
+		return v.a, nil
+	}
+	if v.typ != nil && v.typ.Kind == gocore.KindPtr {
+		return e.pointer(v), nil
+	}
+	n, err := e.intValue(v)
+	return core.Address(n), err
+}
+
+func (e *evaluator) evalExpr(x ast.Expr) (value, error) {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return e.evalExpr(x.X)
+	case *ast.BasicLit:
+		c := constant.MakeFromLiteral(x.Value, x.Kind, 0)
+		if c.Kind() == constant.Unknown {
+			return value{}, fmt.Errorf("bad literal %s", x.Value)
+		}
+		return value{c: c}, nil
+	case *ast.Ident:
+		switch x.Name {
+		case "true", "false":
+			return value{c: constant.MakeBool(x.Name == "true")}, nil
+		case "nil":
+			return value{c: constant.MakeUint64(0)}, nil
+		}
+		if v, ok := e.lookup(x); ok {
+			return v, nil
+		}
+		return value{}, fmt.Errorf("undefined: %s", x.Name)
+	case *ast.SelectorExpr:
+		if v, ok := e.lookup(x); ok {
+			return v, nil
+		}
+		v, err := e.evalExpr(x.X)
+		if err != nil {
+			if _, ok := x.X.(*ast.Ident); ok {
+				return value{}, fmt.Errorf("undefined: %s", e.exprString(x))
+			}
+			return value{}, err
+		}
+		return e.field(v, x.Sel.Name)
+	case *ast.IndexExpr:
+		v, err := e.evalExpr(x.X)
+		if err != nil {
+			return value{}, err
+		}
+		i, err := e.evalExpr(x.Index)
+		if err != nil {
+			return value{}, err
+		}
+		return e.index(v, i)
+	case *ast.StarExpr:
+		v, err := e.evalExpr(x.X)
+		if err != nil {
+			return value{}, err
+		}
+		return e.deref(v)
+	case *ast.UnaryExpr:
+		return e.unary(x)
+	case *ast.BinaryExpr:
+		return e.binary(x)
+	case *ast.CallExpr:
+		return e.call(x)
+	}
+	return value{}, fmt.Errorf("unsupported expression %s", e.exprString(x))
+}
+
+// lookup resolves x, an identifier or a chain of selectors on an
+// identifier, as the name of a local or global variable.
+func (e *evaluator) lookup(x ast.Expr) (value, bool) {
+	name, ok := e.qualifiedName(x)
+	if !ok {
+		return value{}, false
+	}
+	if e.vars != nil && !strings.Contains(name, ".") {
+		if v, ok := e.vars(name); ok {
+			return v, true
+		}
+	}
+	if e.frame != nil && !strings.Contains(name, ".") {
+		for _, r := range e.frame.Roots() {
+			if r.Name == name {
+				return value{typ: r.Type, a: r.Addr}, true
+			}
+		}
+	}
+	if r := e.global(name); r != nil {
+		return value{typ: r.Type, a: r.Addr}, true
+	}
+	return value{}, false
+}
+
+// global returns the global variable with the given name, or nil.
+// If there is no global with exactly that name, a global whose name
+// has name as a suffix after a package path slash is used, if unique.
+func (e *evaluator) global(name string) *gocore.Root {
+	if e.globals == nil {
+		e.globals = map[string]*gocore.Root{}
+		for _, r := range e.c.Globals() {
+			if _, ok := e.globals[r.Name]; !ok {
+				e.globals[r.Name] = r
+			}
+		}
+	}
+	if r := e.globals[name]; r != nil {
+		return r
+	}
+	var found *gocore.Root
+	for n, r := range e.globals {
+		if strings.HasSuffix(n, "/"+name) {
+			if found != nil {
+				return nil // ambiguous
+			}
+			found = r
+		}
+	}
+	return found
+}
+
+// qualifiedName returns the dotted name spelled by x, if x is an
+// identifier or a selector chain on one.
+func (e *evaluator) qualifiedName(x ast.Expr) (string, bool) {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if p, ok := e.paths[x.Name]; ok {
+			return p, true
+		}
+		return x.Name, true
+	case *ast.SelectorExpr:
+		n, ok := e.qualifiedName(x.X)
+		if !ok {
+			return "", false
+		}
+		return n + "." + x.Sel.Name, true
+	}
+	return "", false
+}
+
+// field returns the field named name of the struct v, or of the struct
+// pointed to by v.
+func (e *evaluator) field(v value, name string) (value, error) {
+	if v.typ != nil && v.typ.Kind == gocore.KindPtr {
+		var err error
+		if v, err = e.deref(v); err != nil {
+			return value{}, err
+		}
+	}
+	if v.typ == nil || v.typ.Kind != gocore.KindStruct || v.c != nil {
+		return value{}, fmt.Errorf("%s is not a struct", e.typeName(v))
+	}
+	for _, f := range v.typ.Fields {
+		if f.Name == name {
+			return value{typ: f.Type, a: v.a.Add(f.Off)}, nil
+		}
+	}
+	var names []string
+	for _, f := range v.typ.Fields {
+		names = append(names, f.Name)
+	}
+	return value{}, fmt.Errorf("%s has no field %s (fields: %s)", v.typ, name, strings.Join(names, ", "))
+}
+
+// deref returns the value pointed to by the pointer v.
+func (e *evaluator) deref(v value) (value, error) {
+	if v.typ == nil || v.typ.Kind != gocore.KindPtr {
+		return value{}, fmt.Errorf("can't indirect through %s", e.typeName(v))
+	}
+	if v.typ.Elem == nil {
+		return value{}, fmt.Errorf("can't indirect through %s", v.typ)
+	}
+	if _, _, ok := e.mapTypes(v.typ); ok {
+		return value{}, fmt.Errorf("can't indirect through map %s", v.typ)
+	}
+	a := e.pointer(v)
+	if a == 0 {
+		return value{}, fmt.Errorf("nil pointer dereference")
+	}
+	if !e.p.ReadableN(a, v.typ.Elem.Size) {
+		return value{}, fmt.Errorf("address %x is not readable", a)
+	}
+	return value{typ: v.typ.Elem, a: a}, nil
+}
+
+// pointer returns the address held by the pointer-valued v.
+func (e *evaluator) pointer(v value) core.Address {
+	if v.c != nil {
+		x, _ := constant.Uint64Val(constant.ToInt(v.c))
+		return core.Address(x)
+	}
+	return e.p.ReadPtr(v.a)
+}
+
+// index returns v[i].
+func (e *evaluator) index(v, i value) (value, error) {
+	if v.typ != nil && v.typ.Kind == gocore.KindPtr && v.typ.Elem != nil && v.typ.Elem.Kind == gocore.KindArray {
+		var err error
+		if v, err = e.deref(v); err != nil {
+			return value{}, err
+		}
+	}
+	if v.typ == nil || v.c != nil {
+		return value{}, fmt.Errorf("can't index %s", e.typeName(v))
+	}
+	if kt, vt, ok := e.mapTypes(v.typ); ok {
+		return e.mapIndex(v, kt, vt, i)
+	}
+	n, err := e.intValue(i)
+	if err != nil {
+		return value{}, fmt.Errorf("bad index: %v", err)
+	}
+	var base core.Address
+	var elem *gocore.Type
+	var length int64
+	switch v.typ.Kind {
+	case gocore.KindArray:
+		base, elem, length = v.a, v.typ.Elem, v.typ.Count
+	case gocore.KindSlice, gocore.KindString:
+		base = e.p.ReadPtr(v.a)
+		length = e.p.ReadInt(v.a.Add(e.p.PtrSize()))
+		elem = v.typ.Elem
+	default:
+		return value{}, fmt.Errorf("can't index %s", v.typ)
+	}
+	if n < 0 || n >= length {
+		return value{}, fmt.Errorf("index %d out of range [0:%d]", n, length)
+	}
+	return value{typ: elem, a: base.Add(n * elem.Size)}, nil
+}
+
+// mapTypes returns the key and element types of t, if it is a map.
+func (e *evaluator) mapTypes(t *gocore.Type) (k, v *gocore.Type, ok bool) {
+	if t.Kind != gocore.KindPtr {
+		return nil, nil, false
+	}
+	k, v = e.c.MapTypes(t)
+	return k, v, k != nil
+}
+
+// mapIndex returns m[k] for the map m with key and element types kt and
+// vt. The key must be a basic value.
+func (e *evaluator) mapIndex(m value, kt, vt *gocore.Type, key value) (value, error) {
+	want, err := e.constant(key)
+	if err != nil {
+		return value{}, fmt.Errorf("bad map key: %v", err)
+	}
+	var res value
+	found := false
+	e.c.ForEachMapEntry(m.typ, e.pointer(m), func(k, v core.Address) bool {
+		c, err := e.constant(value{typ: kt, a: k})
+		if err == nil && c.Kind() == want.Kind() && constant.Compare(c, token.EQL, want) {
+			res = value{typ: vt, a: v}
+			found = true
+			return false
+		}
+		return true
+	})
+	if !found {
+		return value{}, fmt.Errorf("key %s not found in map", want)
+	}
+	return res, nil
+}
+
+// call evaluates the builtins len and cap, and conversions.
+func (e *evaluator) call(x *ast.CallExpr) (value, error) {
+	if id, ok := x.Fun.(*ast.Ident); ok && (id.Name == "len" || id.Name == "cap") {
+		if len(x.Args) != 1 {
+			return value{}, fmt.Errorf("%s takes one argument", id.Name)
+		}
+		v, err := e.evalExpr(x.Args[0])
+		if err != nil {
+			return value{}, err
+		}
+		n, err := e.length(v, id.Name == "cap")
+		if err != nil {
+			return value{}, err
+		}
+		return value{c: constant.MakeInt64(n)}, nil
+	}
+	t, err := e.resolveType(x.Fun)
+	if err != nil {
+		return value{}, fmt.Errorf("%s is not a function or a known type: %v", e.exprString(x.Fun), err)
+	}
+	if len(x.Args) != 1 {
+		return value{}, fmt.Errorf("conversion to %s takes one argument", t)
+	}
+	v, err := e.evalExpr(x.Args[0])
+	if err != nil {
+		return value{}, err
+	}
+	if t.Kind == gocore.KindPtr {
+		// Converting an address or another pointer.
+		var a core.Address
+		if v.typ != nil && v.typ.Kind == gocore.KindPtr {
+			a = e.pointer(v)
+		} else {
+			n, err := e.intValue(v)
+			if err != nil {
+				return value{}, fmt.Errorf("can't convert %s to %s", e.typeName(v), t)
+			}
+			a = core.Address(n)
+		}
+		return value{typ: t, c: constant.MakeUint64(uint64(a))}, nil
+	}
+	if v.c == nil {
+		// Reinterpret the memory.
+		return value{typ: t, a: v.a}, nil
+	}
+	return value{}, fmt.Errorf("can't convert constant to %s", t)
+}
+
+// length returns len(v), or cap(v) if capacity is set.
+func (e *evaluator) length(v value, capacity bool) (int64, error) {
+	if v.c != nil && v.c.Kind() == constant.String && !capacity {
+		return int64(len(constant.StringVal(v.c))), nil
+	}
+	if v.typ == nil || v.c != nil {
+		return 0, fmt.Errorf("invalid argument %s", e.typeName(v))
+	}
+	switch v.typ.Kind {
+	case gocore.KindArray:
+		return v.typ.Count, nil
+	case gocore.KindSlice:
+		if capacity {
+			return e.p.ReadInt(v.a.Add(2 * e.p.PtrSize())), nil
+		}
+		return e.p.ReadInt(v.a.Add(e.p.PtrSize())), nil
+	case gocore.KindString:
+		if !capacity {
+			return e.p.ReadInt(v.a.Add(e.p.PtrSize())), nil
+		}
+	case gocore.KindPtr:
+		if _, _, ok := e.mapTypes(v.typ); ok && !capacity {
+			return e.c.MapLen(v.typ, e.pointer(v)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid argument %s", v.typ)
+}
+
+// unary evaluates a unary expression.
+func (e *evaluator) unary(x *ast.UnaryExpr) (v value, err error) {
+	v, err = e.evalExpr(x.X)
+	if err != nil {
+		return value{}, err
+	}
+	if x.Op == token.AND {
+		if v.c != nil {
+			return value{}, fmt.Errorf("can't take the address of %s", e.exprString(x.X))
+		}
+		return value{typ: e.ptrTo(v.typ), c: constant.MakeUint64(uint64(v.a))}, nil
+	}
+	c, err := e.constant(v)
+	if err != nil {
+		return value{}, err
+	}
+	defer catchConstantPanic(&err)
+	return value{c: constant.UnaryOp(x.Op, c, 0)}, nil
+}
+
+// binary evaluates a binary expression on basic values.
+func (e *evaluator) binary(x *ast.BinaryExpr) (v value, err error) {
+	l, err := e.evalExpr(x.X)
+	if err != nil {
+		return value{}, err
+	}
+	r, err := e.evalExpr(x.Y)
+	if err != nil {
+		return value{}, err
+	}
+	lc, err := e.constant(l)
+	if err != nil {
+		return value{}, err
+	}
+	rc, err := e.constant(r)
+	if err != nil {
+		return value{}, err
+	}
+	defer catchConstantPanic(&err)
+	switch x.Op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return value{c: constant.MakeBool(constant.Compare(lc, x.Op, rc))}, nil
+	case token.SHL, token.SHR:
+		s, ok := constant.Uint64Val(rc)
+		if !ok {
+			return value{}, fmt.Errorf("bad shift count %s", rc)
+		}
+		return value{c: constant.Shift(lc, x.Op, uint(s))}, nil
+	case token.QUO:
+		if lc.Kind() == constant.Int && rc.Kind() == constant.Int {
+			if constant.Sign(rc) == 0 {
+				return value{}, fmt.Errorf("division by zero")
+			}
+			return value{c: constant.BinaryOp(lc, token.QUO_ASSIGN, rc)}, nil
+		}
+	case token.REM:
+		if constant.Sign(rc) == 0 {
+			return value{}, fmt.Errorf("division by zero")
+		}
+	}
+	return value{c: constant.BinaryOp(lc, x.Op, rc)}, nil
+}
+
+// catchConstantPanic turns the panics go/constant uses to report
+// mismatched operands into errors.
+func catchConstantPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("invalid operation: %v", r)
+	}
+}
+
+// constant returns the value of the basic value v as a constant.
+func (e *evaluator) constant(v value) (constant.Value, error) {
+	if v.c != nil {
+		return v.c, nil
+	}
+	a := v.a
+	switch v.typ.Kind {
+	case gocore.KindBool:
+		return constant.MakeBool(e.p.ReadUint8(a) != 0), nil
+	case gocore.KindInt:
+		switch v.typ.Size {
+		case 1:
+			return constant.MakeInt64(int64(e.p.ReadInt8(a))), nil
+		case 2:
+			return constant.MakeInt64(int64(e.p.ReadInt16(a))), nil
+		case 4:
+			return constant.MakeInt64(int64(e.p.ReadInt32(a))), nil
+		case 8:
+			return constant.MakeInt64(e.p.ReadInt64(a)), nil
+		}
+	case gocore.KindUint:
+		switch v.typ.Size {
+		case 1:
+			return constant.MakeUint64(uint64(e.p.ReadUint8(a))), nil
+		case 2:
+			return constant.MakeUint64(uint64(e.p.ReadUint16(a))), nil
+		case 4:
+			return constant.MakeUint64(uint64(e.p.ReadUint32(a))), nil
+		case 8:
+			return constant.MakeUint64(e.p.ReadUint64(a)), nil
+		}
+	case gocore.KindFloat:
+		switch v.typ.Size {
+		case 4:
+			return constant.MakeFloat64(float64(math.Float32frombits(e.p.ReadUint32(a)))), nil
+		case 8:
+			return constant.MakeFloat64(math.Float64frombits(e.p.ReadUint64(a))), nil
+		}
+	case gocore.KindString:
+		s, err := e.readString(a, maxStringValue, false)
+		if err != nil {
+			return nil, err
+		}
+		return constant.MakeString(s), nil
+	case gocore.KindPtr:
+		return constant.MakeUint64(uint64(e.p.ReadPtr(a))), nil
+	}
+	return nil, fmt.Errorf("%s is not a basic value", v.typ)
+}
+
+// mustConstant is like constant but panics on error.
+func (e *evaluator) mustConstant(v value) constant.Value {
+	c, err := e.constant(v)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// intValue returns the value of the integer v.
+func (e *evaluator) intValue(v value) (int64, error) {
+	c, err := e.constant(v)
+	if err != nil {
+		return 0, err
+	}
+	c = constant.ToInt(c)
+	if c.Kind() != constant.Int {
+		return 0, fmt.Errorf("%s is not an integer", c)
+	}
+	if n, ok := constant.Int64Val(c); ok {
+		return n, nil
+	}
+	if n, ok := constant.Uint64Val(c); ok {
+		return int64(n), nil
+	}
+	return 0, fmt.Errorf("%s overflows", c)
+}
+
+// maxStringValue is the length of the longest string whose value is
+// used in expressions. Longer strings are likely corrupt.
+const maxStringValue = 1 << 20
+
+// readString returns the contents of the string header at a. If the
+// string is longer than max bytes, readString returns an error when
+// truncate is false, and the first max bytes otherwise.
+func (e *evaluator) readString(a core.Address, max int64, truncate bool) (string, error) {
+	p := e.p.ReadPtr(a)
+	n := e.p.ReadInt(a.Add(e.p.PtrSize()))
+	if n > max {
+		if !truncate {
+			return "", fmt.Errorf("string at %#x is too long (%d bytes)", a, n)
+		}
+		n = max
+	}
+	if n <= 0 {
+		return "", nil
+	}
+	if !e.p.ReadableN(p, n) {
+		return "", fmt.Errorf("contents of string at %#x, at address %x, are not readable", a, p)
+	}
+	b := make([]byte, n)
+	e.p.ReadAt(b, p)
+	return string(b), nil
+}
+
+// resolveType returns the type named by the type expression x.
+func (e *evaluator) resolveType(x ast.Expr) (*gocore.Type, error) {
+	name, err := e.typeExprString(x)
+	if err != nil {
+		return nil, err
+	}
+	if t := e.c.FindType(name); t != nil {
+		return t, nil
+	}
+	if strings.HasPrefix(name, "*") {
+		if t := e.c.FindType(name[1:]); t != nil {
+			return e.ptrTo(t), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown type %s", name)
+}
+
+// ptrTo returns a pointer type with element type t.
+func (e *evaluator) ptrTo(t *gocore.Type) *gocore.Type {
+	if pt := e.c.FindType("*" + t.Name); pt != nil && pt.Elem == t {
+		return pt
+	}
+	return &gocore.Type{Name: "*" + t.Name, Size: e.p.PtrSize(), Kind: gocore.KindPtr, Elem: t}
+}
+
+// typeExprString returns the name of the type denoted by x, as the
+// runtime would spell it.
+func (e *evaluator) typeExprString(x ast.Expr) (string, error) {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return e.typeExprString(x.X)
+	case *ast.Ident, *ast.SelectorExpr:
+		if n, ok := e.qualifiedName(x); ok {
+			return n, nil
+		}
+	case *ast.StarExpr:
+		s, err := e.typeExprString(x.X)
+		return "*" + s, err
+	case *ast.ArrayType:
+		s, err := e.typeExprString(x.Elt)
+		if x.Len == nil {
+			return "[]" + s, err
+		}
+		n, ok := x.Len.(*ast.BasicLit)
+		if !ok {
+			break
+		}
+		return "[" + n.Value + "]" + s, err
+	case *ast.MapType:
+		k, err := e.typeExprString(x.Key)
+		if err != nil {
+			return "", err
+		}
+		v, err := e.typeExprString(x.Value)
+		return "map[" + k + "]" + v, err
+	}
+	return "", fmt.Errorf("bad type %s", e.exprString(x))
+}
+
+// exprString returns x as source text, with package paths restored.
+func (e *evaluator) exprString(x ast.Expr) string {
+	s := types.ExprString(x)
+	for id, p := range e.paths {
+		s = strings.ReplaceAll(s, id, p)
+	}
+	return s
+}
+
+// typeName returns a description of the type of v for error messages.
+func (e *evaluator) typeName(v value) string {
+	if v.typ == nil {
+		return "untyped constant"
+	}
+	return v.typ.Name
+}
+
+// A valuePrinter formats values in a form similar to fmt's %+v verb.
+type valuePrinter struct {
+	e *evaluator
+	b strings.Builder
+
+	// maxElems is the maximum number of elements printed
+	// for arrays, slices and maps.
+	maxElems int64
+	// maxString is the maximum number of bytes printed for strings.
+	maxString int64
+}
+
+// formatValue returns v formatted as by %+v. Composite values and
+// pointers are followed to at most depth levels.
+func (e *evaluator) formatValue(v value, depth int) (s string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	vp := &valuePrinter{e: e, maxElems: 64, maxString: 256}
+	vp.value(v, depth)
+	return vp.b.String(), nil
+}
+
+func (vp *valuePrinter) value(v value, depth int) {
+	e := vp.e
+	if v.c != nil {
+		if v.typ != nil && v.typ.Kind == gocore.KindPtr {
+			vp.pointer(v.typ, e.pointer(v), depth)
+			return
+		}
+		vp.b.WriteString(v.c.String())
+		return
+	}
+	t, a := v.typ, v.a
+	switch t.Kind {
+	case gocore.KindBool, gocore.KindInt, gocore.KindUint, gocore.KindFloat:
+		c := e.mustConstant(v)
+		if c.Kind() == constant.Float {
+			f, _ := constant.Float64Val(c)
+			vp.b.WriteString(strconv.FormatFloat(f, 'g', -1, int(t.Size*8)))
+			return
+		}
+		vp.b.WriteString(c.String())
+	case gocore.KindComplex:
+		half := t.Size / 2
+		re := vp.e.mustConstant(value{typ: &gocore.Type{Kind: gocore.KindFloat, Size: half}, a: a})
+		im := vp.e.mustConstant(value{typ: &gocore.Type{Kind: gocore.KindFloat, Size: half}, a: a.Add(half)})
+		r, _ := constant.Float64Val(re)
+		i, _ := constant.Float64Val(im)
+		fmt.Fprintf(&vp.b, "%v", complex(r, i))
+	case gocore.KindString:
+		n := e.p.ReadInt(a.Add(e.p.PtrSize()))
+		s, err := e.readString(a, vp.maxString, true)
+		if err != nil {
+			fmt.Fprintf(&vp.b, "<%v>", err)
+			return
+		}
+		vp.b.WriteString(strconv.Quote(s))
+		if n > vp.maxString {
+			fmt.Fprintf(&vp.b, "...+%d more", n-vp.maxString)
+		}
+	case gocore.KindPtr:
+		vp.pointer(t, e.p.ReadPtr(a), depth)
+	case gocore.KindFunc:
+		closure := e.p.ReadPtr(a)
+		if closure == 0 {
+			vp.b.WriteString("nil")
+			return
+		}
+		if f := e.c.FindFunc(e.p.ReadPtr(closure)); f != nil {
+			vp.b.WriteString(f.Name())
+			return
+		}
+		fmt.Fprintf(&vp.b, "func@%#x", closure)
+	case gocore.KindIface, gocore.KindEface:
+		dt, da := e.c.DynamicValue(t, a)
+		if dt == nil {
+			vp.b.WriteString("nil")
+			return
+		}
+		vp.value(value{typ: dt, a: da}, depth)
+	case gocore.KindArray:
+		vp.elems(t.Elem, a, t.Count, depth)
+	case gocore.KindSlice:
+		vp.elems(t.Elem, e.p.ReadPtr(a), e.p.ReadInt(a.Add(e.p.PtrSize())), depth)
+	case gocore.KindStruct:
+		if depth <= 0 {
+			vp.b.WriteString("{...}")
+			return
+		}
+		vp.b.WriteByte('{')
+		for i, f := range t.Fields {
+			if i > 0 {
+				vp.b.WriteByte(' ')
+			}
+			vp.b.WriteString(f.Name)
+			vp.b.WriteByte(':')
+			vp.value(value{typ: f.Type, a: a.Add(f.Off)}, depth-1)
+		}
+		vp.b.WriteByte('}')
+	default:
+		fmt.Fprintf(&vp.b, "?%s@%#x", t, a)
+	}
+}
+
+// pointer formats the pointer of type t with value a.
+func (vp *valuePrinter) pointer(t *gocore.Type, a core.Address, depth int) {
+	e := vp.e
+	if a == 0 {
+		vp.b.WriteString("nil")
+		return
+	}
+	if kt, vt, ok := e.mapTypes(t); ok {
+		vp.mapEntries(t, kt, vt, a, depth)
+		return
+	}
+	if t.Elem == nil || depth <= 0 || strings.HasPrefix(t.Name, "chan ") || !e.p.ReadableN(a, t.Elem.Size) {
+		fmt.Fprintf(&vp.b, "%#x", a)
+		return
+	}
+	switch t.Elem.Kind {
+	case gocore.KindStruct, gocore.KindArray, gocore.KindSlice:
+		vp.b.WriteByte('&')
+		vp.value(value{typ: t.Elem, a: a}, depth)
+	default:
+		fmt.Fprintf(&vp.b, "%#x", a)
+	}
+}
+
+// elems formats the n elements of type t starting at a.
+func (vp *valuePrinter) elems(t *gocore.Type, a core.Address, n int64, depth int) {
+	if depth <= 0 && n > 0 {
+		fmt.Fprintf(&vp.b, "[...%d elements]", n)
+		return
+	}
+	vp.b.WriteByte('[')
+	for i := int64(0); i < n; i++ {
+		if i > 0 {
+			vp.b.WriteByte(' ')
+		}
+		if i == vp.maxElems {
+			fmt.Fprintf(&vp.b, "...+%d more", n-i)
+			break
+		}
+		vp.value(value{typ: t, a: a.Add(i * t.Size)}, depth-1)
+	}
+	vp.b.WriteByte(']')
+}
+
+// mapEntries formats the map of type t whose header is at h.
+func (vp *valuePrinter) mapEntries(t, kt, vt *gocore.Type, h core.Address, depth int) {
+	e := vp.e
+	n := e.c.MapLen(t, h)
+	if depth <= 0 && n > 0 {
+		fmt.Fprintf(&vp.b, "map[...%d entries]", n)
+		return
+	}
+	vp.b.WriteString("map[")
+	i := int64(0)
+	e.c.ForEachMapEntry(t, h, func(k, v core.Address) bool {
+		if i > 0 {
+			vp.b.WriteByte(' ')
+		}
+		if i == vp.maxElems {
+			fmt.Fprintf(&vp.b, "...+%d more", n-i)
+			return false
+		}
+		i++
+		vp.value(value{typ: kt, a: k}, depth-1)
+		vp.b.WriteByte(':')
+		vp.value(value{typ: vt, a: v}, depth-1)
+		return true
+	})
+	vp.b.WriteByte(']')
+}
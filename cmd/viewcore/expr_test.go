@@ -0,0 +1,189 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/debug/internal/core"
+	"golang.org/x/debug/internal/gocore"
+)
+
+// loadTestCore loads the core file of the gocore tests, which was made
+// by go1.9 from a program that dereferences nil in main.main.
+func loadTestCore(t *testing.T) (*core.Process, *gocore.Process) {
+	t.Helper()
+	if runtime.GOOS == "android" {
+		t.Skip("skipping test on android")
+	}
+	dir := filepath.Join("..", "..", "internal", "gocore", "testdata")
+	p, err := core.Core(filepath.Join(dir, "core"), dir, "")
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	c, err := gocore.Core(p)
+	if err != nil {
+		t.Fatalf("can't parse Go core: %s", err)
+	}
+	return p, c
+}
+
+// loadZipTestCore loads the core file in the zip file name of the gocore
+// tests, which holds it as tmp/coretest/core.
+func loadZipTestCore(t *testing.T, name string) (*core.Process, *gocore.Process) {
+	t.Helper()
+	if runtime.GOOS == "android" {
+		t.Skip("skipping test on android")
+	}
+	dir := t.TempDir()
+	r, err := zip.OpenReader(filepath.Join("..", "..", "internal", "gocore", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		rf, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rf)
+		rf.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, f.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := core.Core(filepath.Join(dir, "tmp", "coretest", "core"), dir, "")
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	c, err := gocore.Core(p)
+	if err != nil {
+		t.Fatalf("can't parse Go core: %s", err)
+	}
+	return p, c
+}
+
+// exprTest is an expression and the result of printing its value,
+// or an error that should occur.
+type exprTest struct {
+	expr string
+	want string
+	err  string
+}
+
+func testExprs(t *testing.T, e *evaluator, tests []exprTest) {
+	t.Helper()
+	for _, test := range tests {
+		v, err := e.eval(test.expr)
+		var got string
+		if err == nil {
+			got, err = e.formatValue(v, 1)
+		}
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got %q, %v, want error containing %q", test.expr, got, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s = %s, want %s", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	p, c := loadTestCore(t)
+	testExprs(t, newEvaluator(p, c), []exprTest{
+		// Globals, fields and indexing.
+		{expr: `runtime.buildVersion`, want: `"go1.9"`},
+		{expr: `runtime.buildVersion[2]`, want: `49`},
+		{expr: `runtime.allgs[0].goid`, want: `1`},
+		{expr: `(*runtime.allgs[1]).goid`, want: `2`},
+		{expr: `runtime.m0.id`, want: `0`},
+		{expr: `runtime.allgs[3]`, err: `index 3 out of range [0:3]`},
+		{expr: `runtime.buildVersion.x`, err: `is not a struct`},
+		{expr: `runtime.m0.nosuchfield`, err: `has no field nosuchfield`},
+		{expr: `runtime.nosuchvar`, err: `undefined: runtime.nosuchvar`},
+
+		// Builtins.
+		{expr: `len(runtime.allgs)`, want: `3`},
+		{expr: `cap(runtime.allgs)`, want: `4`},
+		{expr: `len(runtime.buildVersion)`, want: `5`},
+		{expr: `len("abc")`, want: `3`},
+		{expr: `len(runtime.m0)`, err: `invalid argument`},
+
+		// Conversions.
+		{expr: `(*runtime.g)(runtime.allgs[1]).goid`, want: `2`},
+		{expr: `(*runtime.nosuchtype)(runtime.allgs[1])`, err: `unknown type`},
+
+		// Arithmetic and comparisons.
+		{expr: `runtime.allgs[0].goid + 1`, want: `2`},
+		{expr: `len(runtime.allgs) * 2 == 6`, want: `true`},
+		{expr: `runtime.buildVersion == "go1.9"`, want: `true`},
+		{expr: `7 / 2`, want: `3`},
+		{expr: `1 / 0`, err: `division by zero`},
+		{expr: `runtime.m0 + 1`, err: `is not a basic value`},
+	})
+}
+
+// TestEvalMap evaluates expressions on the map main.contexts of
+// ../../internal/gocore/testdata/context/test.go.
+func TestEvalMap(t *testing.T) {
+	p, c := loadZipTestCore(t, "1.20-context.zip")
+	testExprs(t, newEvaluator(p, c), []exprTest{
+		{expr: `len(main.contexts)`, want: `5`},
+		{expr: `main.contexts["value"].(*context.valueCtx).val`, err: `unsupported expression`},
+		{expr: `main.contexts["nosuchkey"]`, err: `key "nosuchkey" not found`},
+		{expr: `main.contexts[1]`, err: `not found`},
+		{expr: `*main.contexts`, err: `can't indirect through map`},
+	})
+
+	// The value is an interface; print follows it to the context.
+	e := newEvaluator(p, c)
+	v, err := e.eval(`main.contexts["canceled"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := e.formatValue(v, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, `err:&{s:"context canceled"}`) {
+		t.Errorf(`main.contexts["canceled"] = %s, want a canceled context`, s)
+	}
+}
+
+func TestParseExprPaths(t *testing.T) {
+	e := &evaluator{}
+	x, err := e.parseExpr(`net/http.DefaultClient.Timeout + len("a/b.c")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.exprString(x), `net/http.DefaultClient.Timeout + len("a/b.c")`; got != want {
+		t.Errorf("parsed %s, want %s", got, want)
+	}
+	if len(e.paths) != 1 {
+		t.Errorf("got paths %v, want just net/http", e.paths)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/debug/internal/core"
+	"golang.org/x/debug/internal/gocore"
+)
+
+// parsePattern returns the bytes to search for, given the kind of
+// pattern and its text, and the alignment to search them at by default.
+func parsePattern(p *core.Process, kind string, text []string) ([]byte, int, error) {
+	switch kind {
+	case "ptr":
+		if len(text) != 1 {
+			return nil, 0, fmt.Errorf("want one pointer value, got %q", strings.Join(text, " "))
+		}
+		n, err := strconv.ParseUint(strings.TrimPrefix(text[0], "0x"), 16, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("can't parse %q as a pointer value", text[0])
+		}
+		b := make([]byte, p.PtrSize())
+		if p.PtrSize() == 4 {
+			if n>>32 != 0 {
+				return nil, 0, fmt.Errorf("%q doesn't fit in a pointer", text[0])
+			}
+			p.ByteOrder().PutUint32(b, uint32(n))
+		} else {
+			p.ByteOrder().PutUint64(b, n)
+		}
+		return b, int(p.PtrSize()), nil
+	case "hex":
+		s := strings.TrimPrefix(strings.Join(text, ""), "0x")
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, 0, fmt.Errorf("can't parse %q as hex bytes", strings.Join(text, " "))
+		}
+		return b, 1, nil
+	case "str":
+		s := strings.Join(text, " ")
+		if s == "" {
+			return nil, 0, fmt.Errorf("empty string")
+		}
+		return []byte(s), 1, nil
+	}
+	return nil, 0, fmt.Errorf("unknown kind of pattern %q: want ptr, hex or str", kind)
+}
+
+// A location describes what holds an address: a heap object, a global
+// variable, a goroutine stack frame or a symbol.
+type location struct {
+	kind string       // "object", "global", "stack", "symbol" or ""
+	base core.Address // address of the object, variable or frame
+	name string       // like main.T.name, runtime.allgs[0] or goroutine 17 main.f.x
+}
+
+// String describes the location, as in "object c000123450 main.T.name",
+// or "" if it is unknown.
+func (l location) String() string {
+	switch l.kind {
+	case "":
+		return ""
+	case "symbol":
+		return l.name
+	}
+	return fmt.Sprintf("%s %x %s", l.kind, l.base, l.name)
+}
+
+// locate returns the location of a.
+func locate(c *gocore.Process, a core.Address) location {
+	if x, off := c.FindObject(a); x != 0 {
+		return location{kind: "object", base: c.Addr(x), name: typeName(c, x) + fieldName(c, x, off)}
+	}
+	for _, r := range c.Globals() {
+		if a >= r.Addr && a < r.Addr.Add(r.Type.Size) {
+			return location{kind: "global", base: r.Addr, name: r.Name + typeFieldName(r.Type, a.Sub(r.Addr))}
+		}
+	}
+	for _, g := range c.Goroutines() {
+		for _, f := range g.Frames() {
+			if a < f.Min() || a >= f.Max() {
+				continue
+			}
+			name := fmt.Sprintf("goroutine %d %s", g.ID(), f.Func().Name())
+			for _, r := range f.Roots() {
+				if a >= r.Addr && a < r.Addr.Add(r.Type.Size) {
+					name += "." + r.Name + typeFieldName(r.Type, a.Sub(r.Addr))
+					break
+				}
+			}
+			return location{kind: "stack", base: f.Min(), name: name}
+		}
+	}
+	if s, _ := c.Process().SymbolAt(a); s != nil {
+		return location{kind: "symbol", base: s.Addr, name: symbolName(c.Process(), a)}
+	}
+	return location{}
+}
+
+func runFind(cmd *cobra.Command, args []string) error {
+	p, c, err := readCore()
+	if err != nil {
+		return err
+	}
+	pattern, align, err := parsePattern(p, args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("align") {
+		if align, err = cmd.Flags().GetInt("align"); err != nil {
+			return err
+		}
+	}
+	topN, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return err
+	}
+
+	var hits []core.Address
+	p.Search(pattern, align, func(a core.Address) bool {
+		hits = append(hits, a)
+		return topN <= 0 || len(hits) < topN
+	})
+
+	if structuredOutput() {
+		rw := newRecordWriter("addr", "kind", "base", "name")
+		for _, a := range hits {
+			l := locate(c, a)
+			rw.write(a, l.kind, l.base, l.name)
+		}
+		rw.flush()
+		return nil
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(t, "address\tin\n")
+	for _, a := range hits {
+		in := locate(c, a).String()
+		if in == "" {
+			in = "?"
+		}
+		fmt.Fprintf(t, "%x\t%s\n", a, in)
+	}
+	t.Flush()
+	if topN > 0 && len(hits) == topN {
+		fmt.Printf("stopped after %d matches; use --top to see more\n", topN)
+	}
+	return nil
+}
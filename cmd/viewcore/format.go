@@ -0,0 +1,128 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// outputFormat is the value of the --format flag.
+var outputFormat = "text"
+
+// structuredOutput reports whether commands should write records in
+// one of the machine-readable formats rather than text.
+func structuredOutput() bool {
+	return outputFormat != "text"
+}
+
+// checkOutputFormat returns an error if the --format flag is not
+// one of the supported formats.
+func checkOutputFormat() error {
+	switch outputFormat {
+	case "text", "json", "jsonl", "csv":
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q; want text, json, jsonl or csv", outputFormat)
+}
+
+// A recordWriter writes the output of a command as records with a fixed
+// list of named fields, in the format selected by --format:
+//
+//	json   a JSON array of objects
+//	jsonl  one JSON object per line
+//	csv    a header line with the field names, then one line per record
+//
+// Addresses are written as hexadecimal strings, as JSON numbers can't
+// represent all 64-bit values exactly.
+type recordWriter struct {
+	fields []string
+	w      *bufio.Writer
+	csv    *csv.Writer
+	n      int // records written
+}
+
+// newRecordWriter returns a recordWriter for records with the given
+// fields, writing to standard output.
+func newRecordWriter(fields ...string) *recordWriter {
+	rw := &recordWriter{fields: fields, w: bufio.NewWriter(os.Stdout)}
+	if outputFormat == "csv" {
+		rw.csv = csv.NewWriter(rw.w)
+		rw.csv.Write(fields)
+	}
+	return rw
+}
+
+// write writes a record. values are the values of the fields, in order.
+func (rw *recordWriter) write(values ...interface{}) {
+	if len(values) != len(rw.fields) {
+		panic(fmt.Sprintf("record has %d values, want %d", len(values), len(rw.fields)))
+	}
+	for i, v := range values {
+		if a, ok := v.(core.Address); ok {
+			values[i] = fmt.Sprintf("%#x", uint64(a))
+		}
+	}
+	switch outputFormat {
+	case "csv":
+		s := make([]string, len(values))
+		for i, v := range values {
+			s[i] = fmt.Sprint(v)
+		}
+		rw.csv.Write(s)
+	case "json":
+		if rw.n == 0 {
+			io.WriteString(rw.w, "[\n")
+		} else {
+			io.WriteString(rw.w, ",\n")
+		}
+		rw.writeObject(values)
+	case "jsonl":
+		rw.writeObject(values)
+		io.WriteString(rw.w, "\n")
+	}
+	rw.n++
+}
+
+// writeObject writes a record as a JSON object, with the fields in order.
+func (rw *recordWriter) writeObject(values []interface{}) {
+	rw.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			rw.w.WriteByte(',')
+		}
+		k, _ := json.Marshal(rw.fields[i])
+		b, err := json.Marshal(v)
+		if err != nil {
+			b, _ = json.Marshal(fmt.Sprint(v))
+		}
+		rw.w.Write(k)
+		rw.w.WriteByte(':')
+		rw.w.Write(b)
+	}
+	rw.w.WriteByte('}')
+}
+
+// flush finishes the output.
+func (rw *recordWriter) flush() {
+	switch outputFormat {
+	case "csv":
+		rw.csv.Flush()
+	case "json":
+		if rw.n == 0 {
+			io.WriteString(rw.w, "[")
+		}
+		io.WriteString(rw.w, "\n]\n")
+	}
+	rw.w.Flush()
+}
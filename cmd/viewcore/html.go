@@ -185,6 +185,56 @@ func serveHTML(c *gocore.Process, port int, async bool) {
 		}
 		fmt.Fprintf(w, "</table>\n")
 	})
+	http.HandleFunc("/memory", func(w http.ResponseWriter, r *http.Request) {
+		as, ok := r.URL.Query()["a"]
+		if !ok || len(as) != 1 {
+			fmt.Fprintf(w, "wrong or missing a= address specification")
+			return
+		}
+		p := c.Process()
+		a, err := newEvaluator(p, c).address(as[0])
+		if err != nil {
+			fmt.Fprintf(w, "unparseable a= address specification: %s", html.EscapeString(err.Error()))
+			return
+		}
+		fs := r.URL.Query().Get("f")
+		if fs == "" {
+			fs = "32a"
+		}
+		f, err := parseExamineFormat(fs, p.PtrSize())
+		if err != nil {
+			fmt.Fprintf(w, "unparseable f= format specification: %s", html.EscapeString(err.Error()))
+			return
+		}
+		lines, err := examine(p, c, a, f)
+
+		tableStyle(w)
+		fmt.Fprintf(w, "<h1>memory at %x</h1>\n", a)
+		if s := symbolName(p, a); s != "" {
+			fmt.Fprintf(w, "<h3>%s</h3>\n", html.EscapeString(s))
+		}
+		cols := 1
+		if len(lines) > 0 {
+			cols = len(lines[0].values)
+		}
+		fmt.Fprintf(w, "<table>\n")
+		fmt.Fprintf(w, "<tr><th align=left>address</th><th align=left>symbol</th><th align=left colspan=\"%d\">value</th></tr>\n", cols)
+		for _, l := range lines {
+			fmt.Fprintf(w, "<tr><td>%x</td><td>%s</td>", l.addr, html.EscapeString(symbolName(p, l.addr)))
+			for _, v := range l.values {
+				if f.format == 'a' {
+					fmt.Fprintf(w, "<td>%s</td>", htmlPointer(c, v.ptr))
+				} else {
+					fmt.Fprintf(w, "<td><pre>%s</pre></td>", html.EscapeString(v.text))
+				}
+			}
+			fmt.Fprintf(w, "</tr>\n")
+		}
+		fmt.Fprintf(w, "</table>\n")
+		if err != nil {
+			fmt.Fprintf(w, "<h3>%s</h3>\n", html.EscapeString(err.Error()))
+		}
+	})
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "<h1>core dump viewer</h1>\n")
 		fmt.Fprintf(w, "%s<br/>\n", c.Process().Arch())
@@ -353,7 +403,16 @@ func htmlPointer(c *gocore.Process, a core.Address) string {
 	}
 	x, i := c.FindObject(a)
 	if x == 0 {
-		return fmt.Sprintf("%x", a)
+		// Not a heap object. Maybe a global, a function or
+		// some data of a shared library.
+		s := fmt.Sprintf("%x", a)
+		if c.Process().Readable(a) {
+			s = fmt.Sprintf("<a href=\"/memory?a=%x\">%x</a>", a, a)
+		}
+		if sym := symbolName(c.Process(), a); sym != "" {
+			return fmt.Sprintf("%s &lt;%s&gt;", s, html.EscapeString(sym))
+		}
+		return s
 	}
 	s := fmt.Sprintf("<a href=\"/object?o=%x\">object %x</a>", c.Addr(x), c.Addr(x))
 	if i == 0 {
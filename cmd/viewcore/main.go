@@ -13,9 +13,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"go/constant"
+	"hash/fnv"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
 	"runtime/debug"
 	"runtime/pprof"
 	"sort"
@@ -23,6 +30,7 @@ import (
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/chzyer/readline"
 	"github.com/spf13/cobra"
@@ -52,6 +60,14 @@ Example:
 
   viewcore mycore overview
 
+To run several commands while loading the core only once, give them with
+-c, or list them, one per line, in a file given with --script. Their
+arguments are split and quoted as in a shell. viewcore exits with a
+non-zero status if any of them fails.
+
+  viewcore mycore -c 'histogram --top 20' -c goroutines
+  viewcore mycore --script triage.vc
+
 For available analysis tools, run the following command.
 
   viewcore help
@@ -60,7 +76,7 @@ For available analysis tools, run the following command.
 	PersistentPostRun: func(cmd *cobra.Command, args []string) { endProfile() },
 
 	Args: cobra.ExactArgs(0), // either empty, <corefile> or help <subcommand>
-	Run:  runRoot,
+	RunE: runRoot,
 }
 
 // Subcommands
@@ -69,21 +85,35 @@ var (
 		Use:   "overview",
 		Short: "print a few overall statistics",
 		Args:  cobra.ExactArgs(0),
-		Run:   runOverview,
+		RunE:  runOverview,
 	}
 
 	cmdMappings = &cobra.Command{
 		Use:   "mappings",
 		Short: "print virtual memory mappings",
 		Args:  cobra.ExactArgs(0),
-		Run:   runMappings,
+		RunE:  runMappings,
 	}
 
 	cmdGoroutines = &cobra.Command{
 		Use:   "goroutines",
 		Short: "list goroutines",
 		Args:  cobra.ExactArgs(0),
-		Run:   runGoroutines,
+		RunE:  runGoroutines,
+	}
+
+	cmdThreads = &cobra.Command{
+		Use:   "threads",
+		Short: "list threads with their program counters and stack pointers",
+		Args:  cobra.ExactArgs(0),
+		RunE:  runThreads,
+	}
+
+	cmdRegs = &cobra.Command{
+		Use:   "regs [<pid>]",
+		Short: "print the registers of a thread, or of all threads",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE:  runRegs,
 	}
 
 	cmdHistogram = &cobra.Command{
@@ -94,49 +124,200 @@ var (
 			"If N is specified, it will reports only the top N buckets\n" +
 			"based on the total bytes.",
 		Args: cobra.ExactArgs(0),
-		Run:  runHistogram,
+		RunE: runHistogram,
 	}
 
 	cmdBreakdown = &cobra.Command{
 		Use:   "breakdown",
 		Short: "print memory use by class",
 		Args:  cobra.ExactArgs(0),
-		Run:   runBreakdown,
+		RunE:  runBreakdown,
+	}
+
+	cmdDupStrings = &cobra.Command{
+		Use:   "dupstrings",
+		Short: "report strings and byte slices with identical contents",
+		Long: `Report strings and byte slices whose contents are held by more than
+one heap object, with the number of copies, their total size, and the
+bytes that sharing a single copy would save. The referrer is a path
+from a root to a string or slice holding the first copy found.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: runDupStrings,
+	}
+
+	cmdWaste = &cobra.Command{
+		Use:   "waste",
+		Short: "report unused capacity of slices, maps and buffers",
+		Long: `Report unused capacity of slices, maps and buffers.
+
+For each slice, the length is compared to the size of its backing
+object. For each map, the number of buckets allocated is compared to
+the number needed for its current count. For bytes.Buffer and
+strings.Builder, the unread length is compared to the capacity.
+Reclaimable bytes are totaled by the type and field holding the slice,
+map or buffer.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: runWaste,
+	}
+
+	cmdDiff = &cobra.Command{
+		Use:   "diff <corefile1> <corefile2>",
+		Short: "compare the heaps and goroutines of two cores of the same binary",
+		Long: `Compare two cores of the same binary, such as two cores taken some
+time apart while investigating a leak. The per-type object histograms,
+the goroutines grouped by stack, and the retained sizes of the globals
+are compared, ranking what grew the most first. The two cores must have
+the same build ID, unless --ignore-build-id is given.
+
+In interactive mode, the current core is compared to the given one:
+
+  diff <corefile2>
+`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: runDiff,
 	}
 
 	cmdObjects = &cobra.Command{
 		Use:   "objects",
 		Short: "print a list of all live objects",
 		Args:  cobra.ExactArgs(0),
-		Run:   runObjects,
+		RunE:  runObjects,
 	}
 
 	cmdObjgraph = &cobra.Command{
 		Use:   "objgraph <output_filename>",
 		Short: "dump object graph (dot)",
 		Args:  cobra.ExactArgs(1),
-		Run:   runObjgraph,
+		RunE:  runObjgraph,
+	}
+
+	cmdContexts = &cobra.Command{
+		Use:   "contexts",
+		Short: "print the tree of context.Context values in the heap",
+		Args:  cobra.ExactArgs(0),
+		RunE:  runContexts,
 	}
 
 	cmdReachable = &cobra.Command{
 		Use:   "reachable <address>",
 		Short: "find path from root to an object",
 		Args:  cobra.ExactArgs(1),
-		Run:   runReachable,
+		RunE:  runReachable,
 	}
 
 	cmdHTML = &cobra.Command{
 		Use:   "html",
 		Short: "start an http server for browsing core file data on the port specified with -port",
 		Args:  cobra.ExactArgs(0),
-		Run:   runHTML,
+		RunE:  runHTML,
+	}
+
+	cmdPrint = &cobra.Command{
+		Use:   "print [g <id> [frame <n>]] <expr>",
+		Short: "print the value of a Go expression",
+		Long: `Print the value of a Go expression.
+
+The expression may refer to global variables, and, if a goroutine
+and frame (0 is the innermost frame) are given, to the local variables
+of that frame. Supported forms include field selection, indexing of
+arrays, slices, strings and maps (by constant key), pointer
+indirection, len and cap, and conversions of addresses to pointers:
+
+  print runtime.allgs[0].goid
+  print len(main.cache.entries)
+  print main.cache.byName["foo"]
+  print (*net/http.Request)(0xc000123000)
+  print g 17 frame 2 req.URL
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runPrint,
+	}
+
+	cmdQuery = &cobra.Command{
+		Use:   "query <query>",
+		Short: "select objects by type, size and field values",
+		Long: `Run a query over the objects in the heap.
+
+Queries have the form
+
+  select <item>, ... [from <type>] [where <cond>]
+      [group by <item>, ...] [order by <item> [asc|desc]] [limit <n>]
+
+Items and conditions are expressions as accepted by the print command,
+in which .f selects the field f of the current object, and addr, size,
+retained and type are the address, size, retained size and type of the
+object. Without a from clause, objects that don't have the fields a
+query selects are skipped. The aggregates count(*), sum, min, max and
+avg summarize groups of objects. For example:
+
+  select addr, size, .name from *main.Session where .closed == false and size > 4096 order by retained desc limit 20
+  select type, count(*), sum(size) group by type order by sum(size) desc limit 10
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runQuery,
 	}
 
 	cmdRead = &cobra.Command{
 		Use:   "read <address> [<size>]",
 		Short: "read a chunk of memory", // oh very helpful!
 		Args:  cobra.RangeArgs(1, 2),
-		Run:   runRead,
+		RunE:  runRead,
+	}
+
+	cmdExamine = &cobra.Command{
+		Use:     "x/<count><format><size> <address>",
+		Aliases: []string{"x"}, // splitExamine separates the format
+		Short:   "examine memory in various formats, like gdb's x command",
+		Long: `Examine memory in various formats, like gdb's x command.
+
+The count is the number of units to show, 1 by default. The format is
+one of
+
+  x  hex (the default)
+  d  signed decimal
+  u  unsigned decimal
+  o  octal
+  t  binary
+  a  pointer, with the object, global, stack frame or symbol it points into
+  c  character
+  s  NUL-terminated string
+
+and the size of a unit is b (1 byte), h (2 bytes), w (4 bytes) or g
+(8 bytes), the pointer size by default. The address is an expression
+as accepted by the print command, in which names of globals and symbols
+stand for their addresses and pointers for the address they hold.
+Numbers are in Go syntax, so, as in gdb, 400000 is decimal and
+runtime.allgs+16 is 16 bytes past runtime.allgs. Unlike in gdb, an
+address made of hex digits including a letter, like c000123450 or
+7ffd1234, is hex even without 0x:
+
+  x/4xg c000123450
+  x/4xg 0x400000
+  x/2a runtime.allgs
+  x/16xb runtime.allgs+8
+  x/s main.version.str
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runExamine,
+	}
+
+	cmdFind = &cobra.Command{
+		Use:   "find ptr|hex|str <pattern>",
+		Short: "find the places in memory that hold a value",
+		Long: `Find the places in memory that hold a value, and tell the heap
+object, global variable, goroutine stack frame or symbol each is in.
+
+The value is a pointer, in hex, stored in the byte order of the core and
+aligned to the pointer size, a sequence of hex bytes, or a UTF-8 string:
+
+  find ptr c000123450
+  find hex deadbeef
+  find str "GET /index.html"
+`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: runFind,
 	}
 )
 
@@ -147,34 +328,77 @@ type config struct {
 	corefile string
 
 	// flags
-	base    string
-	exePath string
-	cpuprof string // TODO: move to subcommand config.
+	base          string
+	exePath       string
+	debugFile     string
+	ignoreBuildID bool
+	searchPath    string // list of directories, like $PATH
+	debuginfod    string // space-separated URLs, like $DEBUGINFOD_URLS
+	cpuprof       string // TODO: move to subcommand config.
 }
 
 var cfg config
 
+// batch holds the flags for running commands non-interactively.
+var batch struct {
+	commands []string // -c
+	script   string   // --script
+}
+
 func init() {
 	cmdRoot.PersistentFlags().StringVar(&cfg.base, "base", "", "root directory to find core dump file references")
 	cmdRoot.PersistentFlags().StringVar(&cfg.exePath, "exe", "", "main executable file")
+	cmdRoot.PersistentFlags().StringVar(&cfg.debugFile, "debug-file", "", "file with the DWARF and symbols of a stripped executable")
+	cmdRoot.PersistentFlags().StringVar(&cfg.searchPath, "search-path", "", "list of directories with .build-id trees in which to find the executable and libraries by GNU build ID")
+	cmdRoot.PersistentFlags().StringVar(&cfg.debuginfod, "debuginfod", "", "space-separated URLs of debuginfod servers from which to download the executable and libraries by build ID, if they aren't found locally, like --debuginfod=\"$DEBUGINFOD_URLS\"")
+	cmdRoot.PersistentFlags().BoolVar(&cfg.ignoreBuildID, "ignore-build-id", false, "use the executable even if its build ID doesn't match the core's")
 	cmdRoot.PersistentFlags().StringVar(&cfg.cpuprof, "prof", "", "write cpu profile of viewcore to this file for viewcore's developers")
+	cmdRoot.PersistentFlags().StringVar(&outputFormat, "format", "text", "output format: text, json, jsonl or csv")
+	cmdRoot.Flags().StringArrayVarP(&batch.commands, "command", "c", nil, "run the command instead of starting the interactive shell; may be repeated")
+	cmdRoot.Flags().StringVar(&batch.script, "script", "", "run the commands in the file, one per line, instead of starting the interactive shell")
 
 	// subcommand flags
 	cmdHTML.Flags().IntP("port", "p", 8080, "port for http server")
 
 	cmdHistogram.Flags().Int("top", 0, "reports only top N entries if N>0")
 
+	cmdDupStrings.Flags().Int("top", 20, "reports only top N entries if N>0")
+
+	cmdWaste.Flags().Int("top", 20, "reports only top N entries if N>0")
+
+	cmdDiff.Flags().Int("top", 20, "reports only top N entries of each kind if N>0")
+
+	cmdPrint.Flags().Int("depth", 3, "number of levels of pointers and composite values to print")
+
+	cmdFind.Flags().Int("align", 1, "report only matches at multiples of this alignment (default the pointer size for ptr)")
+	cmdFind.Flags().Int("top", 100, "reports only the first N matches if N>0")
+
 	cmdRoot.AddCommand(
 		cmdOverview,
 		cmdMappings,
 		cmdGoroutines,
+		cmdThreads,
+		cmdRegs,
 		cmdHistogram,
 		cmdBreakdown,
+		cmdDupStrings,
+		cmdWaste,
+		cmdDiff,
 		cmdObjects,
 		cmdObjgraph,
+		cmdContexts,
 		cmdReachable,
 		cmdHTML,
-		cmdRead)
+		cmdPrint,
+		cmdQuery,
+		cmdRead,
+		cmdFind,
+		cmdExamine)
+	for _, c := range cmdRoot.Commands() {
+		if c.RunE != nil {
+			c.RunE = runCommand(c.RunE)
+		}
+	}
 
 	// customize the usage template - viewcore's command structure
 	// is not typical of cobra-based command line tool.
@@ -182,6 +406,30 @@ func init() {
 	cmdRoot.SetUsageTemplate(usageTmpl)
 }
 
+// runCommand wraps the RunE function of a subcommand. Any panic while
+// running the command, such as one from gocore reading a damaged core,
+// is returned as the command's error, so that a failed command doesn't
+// end the interactive shell.
+func runCommand(run func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) (err error) {
+		// The arguments were fine, so don't print the usage on error.
+		cmd.SilenceUsage = true
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(error); ok {
+					err = e
+				} else {
+					err = fmt.Errorf("%v", r)
+				}
+			}
+		}()
+		return run(cmd, args)
+	}
+}
+
 // useLine is like cobra.Command.UseLine but tweaked to use commandPath.
 func useLine(c *cobra.Command) string {
 	var useline string
@@ -236,25 +484,29 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.{{e
 
 func main() {
 	args := os.Args[1:]
-	if len(args) > 0 && args[0] != "help" && !strings.HasPrefix(args[0], "-") {
+	if len(args) > 0 && args[0] != "help" && args[0] != "diff" && !strings.HasPrefix(args[0], "-") {
 		cfg.corefile = args[0]
 		args = args[1:]
 	}
-	cmdRoot.SetArgs(args)
-	cmdRoot.Execute()
+	cmdRoot.SetArgs(splitExamine(cmdRoot, args))
+	if err := cmdRoot.Execute(); err != nil {
+		// cobra has printed the error.
+		os.Exit(1)
+	}
 }
 
-var coreCache = &struct {
-	// copy of params used to generate p.
-	cfg config
+// coreCache holds the cores loaded so far, keyed by the configuration
+// used to load them. Commands like diff need more than one at a time.
+var coreCache = map[config]*loadedCore{}
 
+type loadedCore struct {
 	coreP   *core.Process
 	gocoreP *gocore.Process
-	err     error
-}{}
+}
 
 func ResetSubCommandFlagValues(root *cobra.Command) {
 	for _, c := range root.Commands() {
+		c.SilenceUsage = false
 		c.Flags().VisitAll(func(f *pflag.Flag) {
 			if f.Changed {
 				f.Value.Set(f.DefValue)
@@ -266,11 +518,28 @@ func ResetSubCommandFlagValues(root *cobra.Command) {
 
 // readCore reads corefile and returns core and gocore process states.
 func readCore() (*core.Process, *gocore.Process, error) {
-	cc := coreCache
-	if cc.cfg == cfg {
-		return cc.coreP, cc.gocoreP, cc.err
+	return readCoreConfig(cfg)
+}
+
+// readCoreConfig is like readCore, but reads the core described by cfg
+// instead of the global configuration.
+func readCoreConfig(cfg config) (*core.Process, *gocore.Process, error) {
+	if lc := coreCache[cfg]; lc != nil {
+		return lc.coreP, lc.gocoreP, nil
+	}
+	opts := core.Options{
+		IgnoreBuildID:  cfg.ignoreBuildID,
+		DebugFile:      cfg.debugFile,
+		DebuginfodURLs: strings.Fields(cfg.debuginfod),
+	}
+	if cfg.searchPath != "" {
+		opts.SearchPath = filepath.SplitList(cfg.searchPath)
+	}
+	c, err := core.CoreWithOptions(cfg.corefile, cfg.base, cfg.exePath, opts)
+	var idErr *core.BuildIDError
+	if errors.As(err, &idErr) {
+		return nil, nil, fmt.Errorf("%v; use --ignore-build-id to use it anyway", err)
 	}
-	c, err := core.Core(cfg.corefile, cfg.base, cfg.exePath)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -284,38 +553,33 @@ func readCore() (*core.Process, *gocore.Process, error) {
 	for _, w := range c.Warnings() {
 		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
 	}
-	cc.cfg = cfg
-	cc.coreP = c
-	cc.gocoreP = p
-	cc.err = nil
+	for _, w := range p.Warnings() {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
+	}
+	coreCache[cfg] = &loadedCore{coreP: c, gocoreP: p}
 	return c, p, nil
 }
 
-func runRoot(cmd *cobra.Command, args []string) {
+func runRoot(cmd *cobra.Command, args []string) error {
 	if cfg.corefile == "" {
 		cmd.Usage()
-		return
+		return nil
+	}
+	cmd.SilenceUsage = true
+	if len(batch.commands) > 0 || batch.script != "" {
+		return runBatch(cmd)
 	}
 	// Interactive mode.
 	cfg.interactive = true
 
 	p, _, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
 
-	// Create a dummy root to run in shell.
-	root := &cobra.Command{}
-	// Make all subcommands of viewcore available in the shell.
-	for _, subcmd := range cmd.Commands() {
-		if subcmd.Name() == "help" {
-			root.SetHelpCommand(subcmd)
-			continue
-		}
-		root.AddCommand(subcmd)
-	}
+	sh := newShell(cmd)
 	// Also, add exit command to terminate the shell.
-	root.AddCommand(&cobra.Command{
+	sh.root.AddCommand(&cobra.Command{
 		Use:     "exit",
 		Aliases: []string{"quit", "bye"},
 		Short:   "exit from interactive mode",
@@ -325,11 +589,11 @@ func runRoot(cmd *cobra.Command, args []string) {
 	})
 
 	rootCompleter := readline.NewPrefixCompleter()
-	for _, child := range root.Commands() {
+	for _, child := range sh.root.Commands() {
 		cmdToCompleter(rootCompleter, child)
 	}
 
-	shell, err := readline.NewEx(&readline.Config{
+	rl, err := readline.NewEx(&readline.Config{
 		Prompt:       "(viewcore) ",
 		AutoComplete: rootCompleter,
 		EOFPrompt:    "\n",
@@ -337,35 +601,167 @@ func runRoot(cmd *cobra.Command, args []string) {
 	if err != nil {
 		panic(err)
 	}
-	defer shell.Close()
+	defer rl.Close()
 
 	// nice welcome message.
-	fmt.Fprintln(shell.Terminal)
+	fmt.Fprintln(rl.Terminal)
 	if args := p.Args(); args != "" {
-		fmt.Fprintf(shell.Terminal, "Core %q was generated by %q\n", cfg.corefile, args)
+		fmt.Fprintf(rl.Terminal, "Core %q was generated by %q\n", cfg.corefile, args)
 	}
-	fmt.Fprintf(shell.Terminal, "Entering interactive mode (type 'help' for commands)\n")
+	fmt.Fprintf(rl.Terminal, "Entering interactive mode (type 'help' for commands)\n")
 
 	for {
-		l, err := shell.Readline()
+		l, err := rl.Readline()
 		if err != nil {
 			if err != io.EOF {
 				fmt.Printf("Error: %v\n", err)
 			}
 			break
 		}
+		sh.run(l)
+	}
+	return nil
+}
 
-		err = capturePanic(func() {
-			ResetSubCommandFlagValues(root)
-			root.SetArgs(strings.Fields(l))
-			root.Execute()
-		})
+// runBatch runs the commands given by the -c and --script flags, in
+// that order, and exits with a non-zero status if any of them failed.
+func runBatch(cmd *cobra.Command) error {
+	if _, _, err := readCore(); err != nil {
+		return err
+	}
+	lines := batch.commands
+	if batch.script != "" {
+		b, err := os.ReadFile(batch.script)
 		if err != nil {
-			fmt.Printf("Error while trying to run command %q: %v", l, err)
+			return err
+		}
+		for _, l := range strings.Split(string(b), "\n") {
+			l = strings.TrimSpace(l)
+			if l == "" || strings.HasPrefix(l, "#") {
+				continue
+			}
+			lines = append(lines, l)
+		}
+	}
+	sh := newShell(cmd)
+	failed := 0
+	for _, l := range lines {
+		if !sh.run(l) {
+			failed++
 		}
 	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d commands failed", failed, len(lines))
+	}
+	return nil
+}
+
+// A shell runs viewcore commands read one line at a time,
+// in interactive and batch mode.
+type shell struct {
+	root   *cobra.Command
+	format string // default output format
 }
 
+func newShell(cmd *cobra.Command) *shell {
+	// Create a dummy root to run in shell.
+	sh := &shell{root: &cobra.Command{}, format: outputFormat}
+	// The output format may be given per command.
+	sh.root.PersistentFlags().StringVar(&outputFormat, "format", sh.format, "output format: text, json, jsonl or csv")
+	// Make all subcommands of viewcore available in the shell.
+	for _, subcmd := range cmd.Commands() {
+		if subcmd.Name() == "help" {
+			sh.root.SetHelpCommand(subcmd)
+			continue
+		}
+		sh.root.AddCommand(subcmd)
+	}
+	return sh
+}
+
+// run runs the command line l and reports whether it succeeded.
+// Errors are printed to standard error.
+func (sh *shell) run(l string) bool {
+	var ok bool
+	args, err := splitCommand(l)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	err = capturePanic(func() {
+		ResetSubCommandFlagValues(sh.root)
+		outputFormat = sh.format
+		sh.root.SetArgs(splitExamine(sh.root, args))
+		// Command errors are printed by cobra.
+		ok = sh.root.Execute() == nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while trying to run command %q: %v", l, err)
+		return false
+	}
+	return ok
+}
+
+// splitCommand splits the command line l into words separated by
+// spaces, as a shell does. Words may be quoted with single quotes,
+// within which all characters are literal, or with double quotes,
+// within which a backslash escapes a double quote or a backslash.
+// Outside quotes, a backslash escapes any character.
+func splitCommand(l string) ([]string, error) {
+	var words []string
+	var w strings.Builder
+	inWord := false
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, w.String())
+				w.Reset()
+				inWord = false
+			}
+			continue
+		case c == '\\':
+			if i+1 == len(l) {
+				return nil, fmt.Errorf("trailing backslash in %q", l)
+			}
+			i++
+			w.WriteByte(l[i])
+		case c == '\'':
+			j := strings.IndexByte(l[i+1:], '\'')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated ' in %q", l)
+			}
+			w.WriteString(l[i+1 : i+1+j])
+			i += 1 + j
+		case c == '"':
+			for i++; ; i++ {
+				if i == len(l) {
+					return nil, fmt.Errorf("unterminated \" in %q", l)
+				}
+				if l[i] == '"' {
+					break
+				}
+				if l[i] == '\\' && i+1 < len(l) && (l[i+1] == '"' || l[i+1] == '\\') {
+					i++
+				}
+				w.WriteByte(l[i])
+			}
+		default:
+			w.WriteByte(c)
+		}
+		inWord = true
+	}
+	if inWord {
+		words = append(words, w.String())
+	}
+	return words, nil
+}
+
+// capturePanic calls fn and returns the error reported by a panic in it,
+// along with the stack trace. Commands return their errors, and panics
+// in them are turned into errors by runCommand, so this only catches
+// panics in viewcore's own command handling.
 func capturePanic(fn func()) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -385,48 +781,135 @@ func cmdToCompleter(parent readline.PrefixCompleterInterface, c *cobra.Command)
 	}
 }
 
-func runOverview(cmd *cobra.Command, args []string) {
+func runOverview(cmd *cobra.Command, args []string) error {
 	p, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
 
+	var total, missing int64
+	var nMissing int
+	for _, m := range p.Mappings() {
+		total += m.Max().Sub(m.Min())
+		if m.Missing() {
+			missing += m.Size()
+			nMissing++
+		}
+	}
+	pageSize, _ := p.AuxvValue(core.AT_PAGESZ)
+	// Name the hardware capabilities, if we know their names.
+	hwcap := strings.Join(p.HWCAP(), " ")
+	if v, _ := p.AuxvValue(core.AT_HWCAP); hwcap == "" && v != 0 {
+		hwcap = fmt.Sprintf("%#x", v)
+	}
+	info := p.Info()
+	if info == nil {
+		info = &core.ProcessInfo{}
+	}
+	if structuredOutput() {
+		rw := newRecordWriter("arch", "runtime", "memory", "missing", "go_build_id", "gnu_build_id", "core_go_build_id", "core_gnu_build_id", "page_size", "platform", "hwcap",
+			"pid", "ppid", "pgrp", "sid", "uid", "gid", "state", "command", "args",
+			"signal", "signal_code", "fault_addr", "sender_pid", "sender_uid", "signal_thread")
+		exeID, coreID := p.BuildID(), p.CoreBuildID()
+		var sigName, sigCode string
+		var sig core.SignalInfo
+		var sigThread uint64
+		if s := p.Signal(); s != nil {
+			sig, sigName, sigCode = *s, s.Name(), s.CodeName()
+			if s.Thread != nil {
+				sigThread = s.Thread.Pid()
+			}
+		}
+		rw.write(p.Arch(), c.BuildVersion(), total, missing, exeID.Go, exeID.GNU, coreID.Go, coreID.GNU,
+			pageSize, p.AuxvString(core.AT_PLATFORM), hwcap,
+			info.PID, info.PPID, info.PGRP, info.SID, info.UID, info.GID, stateString(info), info.Fname, info.Args,
+			sigName, sigCode, sig.Addr, sig.PID, sig.UID, sigThread)
+		rw.flush()
+		return nil
+	}
 	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	if p.Info() != nil {
+		fmt.Fprintf(t, "command\t%s\n", info.Args)
+		fmt.Fprintf(t, "pid\t%d (parent %d, group %d, session %d)\n", info.PID, info.PPID, info.PGRP, info.SID)
+		fmt.Fprintf(t, "uid\t%d, gid %d\n", info.UID, info.GID)
+		fmt.Fprintf(t, "state\t%s\n", stateString(info))
+	}
+	if s := p.Signal(); s != nil && s.Thread != nil {
+		fmt.Fprintf(t, "signal\t%s, in thread %d\n", s, s.Thread.Pid())
+	} else if s != nil {
+		fmt.Fprintf(t, "signal\t%s\n", s)
+	}
 	fmt.Fprintf(t, "arch\t%s\n", p.Arch())
+	if s := p.AuxvString(core.AT_PLATFORM); s != "" {
+		fmt.Fprintf(t, "platform\t%s\n", s)
+	}
+	if pageSize != 0 {
+		fmt.Fprintf(t, "page size\t%d\n", pageSize)
+	}
+	if hwcap != "" {
+		fmt.Fprintf(t, "hwcap\t%s\n", hwcap)
+	}
 	fmt.Fprintf(t, "runtime\t%s\n", c.BuildVersion())
-	var total int64
-	for _, m := range p.Mappings() {
-		total += m.Max().Sub(m.Min())
+	fmt.Fprintf(t, "build id\t%s\n", p.BuildID())
+	if f := p.DebugFile(); f != "" {
+		fmt.Fprintf(t, "debug file\t%s\n", f)
+	}
+	if id := p.CoreBuildID(); id != p.BuildID() {
+		// Missing from the core, or different and --ignore-build-id is set.
+		fmt.Fprintf(t, "core build id\t%s\n", id)
 	}
 	fmt.Fprintf(t, "memory\t%.1f MB\n", float64(total)/(1<<20))
+	if nMissing > 0 {
+		fmt.Fprintf(t, "missing\t%.1f MB in %d mappings (core file is truncated)\n", float64(missing)/(1<<20), nMissing)
+	}
+	if n := len(p.Warnings()) + len(c.Warnings()); n > 0 {
+		fmt.Fprintf(t, "warnings\t%d, printed when the core was loaded\n", n)
+	}
 	t.Flush()
+	return nil
 }
 
-func runMappings(cmd *cobra.Command, args []string) {
+// stateString returns the state of the process as shown by ps, with
+// its nice value if it isn't 0.
+func stateString(info *core.ProcessInfo) string {
+	if info.StateName == 0 {
+		return ""
+	}
+	s := string(rune(info.StateName))
+	if info.Nice != 0 {
+		s += fmt.Sprintf(", nice %d", info.Nice)
+	}
+	return s
+}
+
+func runMappings(cmd *cobra.Command, args []string) error {
 	p, _, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
+	}
+	if structuredOutput() {
+		rw := newRecordWriter("min", "max", "perm", "file", "offset", "orig_file", "orig_offset", "missing")
+		for _, m := range p.Mappings() {
+			file, off := m.Source()
+			var origFile string
+			var origOff int64
+			if m.CopyOnWrite() {
+				origFile, origOff = m.OrigSource()
+			}
+			rw.write(m.Min(), m.Max(), permString(m.Perm()), file, off, origFile, origOff, m.Missing())
+		}
+		rw.flush()
+		return nil
 	}
 	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
 	fmt.Fprintf(t, "min\tmax\tperm\tsource\toriginal\t\n")
 	for _, m := range p.Mappings() {
-		perm := ""
-		if m.Perm()&core.Read != 0 {
-			perm += "r"
-		} else {
-			perm += "-"
-		}
-		if m.Perm()&core.Write != 0 {
-			perm += "w"
-		} else {
-			perm += "-"
-		}
-		if m.Perm()&core.Exec != 0 {
-			perm += "x"
-		} else {
-			perm += "-"
-		}
+		perm := permString(m.Perm())
 		file, off := m.Source()
+		if m.Missing() {
+			fmt.Fprintf(t, "%x\t%x\t%s\t(missing from core)\t\t\n", m.Min(), m.Max(), perm)
+			continue
+		}
 		fmt.Fprintf(t, "%x\t%x\t%s\t%s@%x\t", m.Min(), m.Max(), perm, file, off)
 		if m.CopyOnWrite() {
 			file, off = m.OrigSource()
@@ -435,15 +918,48 @@ func runMappings(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(t, "\t\n")
 	}
 	t.Flush()
+	return nil
+}
+
+// permString returns perm in the form "rwx", with - for missing permissions.
+func permString(perm core.Perm) string {
+	s := ""
+	if perm&core.Read != 0 {
+		s += "r"
+	} else {
+		s += "-"
+	}
+	if perm&core.Write != 0 {
+		s += "w"
+	} else {
+		s += "-"
+	}
+	if perm&core.Exec != 0 {
+		s += "x"
+	} else {
+		s += "-"
+	}
+	return s
 }
 
-func runGoroutines(cmd *cobra.Command, args []string) {
+func runGoroutines(cmd *cobra.Command, args []string) error {
 	_, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
+	}
+	if structuredOutput() {
+		// One record per frame.
+		rw := newRecordWriter("goroutine", "g", "stack_size", "state", "frame", "min", "max", "func", "pc")
+		for _, g := range c.Goroutines() {
+			for i, f := range g.Frames() {
+				rw.write(g.ID(), g.Addr(), g.Stack(), g.State(), i, f.Min(), f.Max(), f.Func().Name(), f.PC())
+			}
+		}
+		rw.flush()
+		return nil
 	}
 	for _, g := range c.Goroutines() {
-		fmt.Printf("G stacksize=%x\n", g.Stack())
+		fmt.Printf("G stacksize=%x state=%s\n", g.Stack(), g.State())
 		for _, f := range g.Frames() {
 			pc := f.PC()
 			entry := f.Func().Entry()
@@ -459,36 +975,131 @@ func runGoroutines(cmd *cobra.Command, args []string) {
 			fmt.Printf("  %016x %016x %s%s\n", f.Min(), f.Max(), f.Func().Name(), adj)
 		}
 	}
+	return nil
 }
 
-func runHistogram(cmd *cobra.Command, args []string) {
-	topN, err := cmd.Flags().GetInt("top")
+// funcName returns the name of the Go function containing pc, or else
+// of the function symbol containing it, or "?".
+func funcName(c *gocore.Process, pc core.Address) string {
+	if f := c.FindFunc(pc); f != nil {
+		return f.Name()
+	}
+	if s, _ := c.Process().SymbolAt(pc); s != nil && s.Func {
+		return s.Name
+	}
+	return "?"
+}
+
+// symbolName returns the symbol containing a, with the offset of a in
+// it, as in "runtime.allgs+0x8", or "" if there is none.
+func symbolName(p *core.Process, a core.Address) string {
+	s, off := p.SymbolAt(a)
+	if s == nil {
+		return ""
+	}
+	if off == 0 {
+		return s.Name
+	}
+	return fmt.Sprintf("%s+%#x", s.Name, off)
+}
+
+func runThreads(cmd *cobra.Command, args []string) error {
+	p, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
+	}
+	if structuredOutput() {
+		rw := newRecordWriter("pid", "pc", "sp", "fp", "lr", "func", "signal", "pending", "held", "user_time", "system_time")
+		for _, t := range p.Threads() {
+			var fp, lr core.Address
+			if r := t.Registers(); r != nil {
+				fp, lr = r.FP(), r.LR()
+			}
+			rw.write(t.Pid(), t.PC(), t.SP(), fp, lr, funcName(c, t.PC()),
+				t.Signal(), fmt.Sprintf("%#x", t.PendingSignals()), fmt.Sprintf("%#x", t.HeldSignals()),
+				t.UserTime().Seconds(), t.SystemTime().Seconds())
+		}
+		rw.flush()
+		return nil
 	}
-	_, c, err := readCore()
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(t, "pid\tpc\tsp\tfunc\tsignal\tpending\theld\tuser\tsystem\n")
+	for _, thr := range p.Threads() {
+		sig := "-"
+		if thr.Signal() != 0 {
+			sig = fmt.Sprint(thr.Signal())
+		}
+		fmt.Fprintf(t, "%d\t%x\t%x\t%s\t%s\t%#x\t%#x\t%s\t%s\n", thr.Pid(), thr.PC(), thr.SP(), funcName(c, thr.PC()),
+			sig, thr.PendingSignals(), thr.HeldSignals(), thr.UserTime(), thr.SystemTime())
+	}
+	t.Flush()
+	return nil
+}
+
+func runRegs(cmd *cobra.Command, args []string) error {
+	p, _, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
-	// Produce an object histogram (bytes per type).
-	type bucket struct {
-		name  string
-		size  int64
-		count int64
+	threads := p.Threads()
+	if len(args) > 0 {
+		pid, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("can't parse %q as a thread ID", args[0])
+		}
+		threads = nil
+		for _, t := range p.Threads() {
+			if t.Pid() == pid {
+				threads = append(threads, t)
+			}
+		}
+		if threads == nil {
+			return fmt.Errorf("no thread %d", pid)
+		}
 	}
-	var buckets []*bucket
-	m := map[string]*bucket{}
-	c.ForEachObject(func(x gocore.Object) bool {
-		name := typeName(c, x)
-		b := m[name]
-		if b == nil {
-			b = &bucket{name: name, size: c.Size(x)}
-			buckets = append(buckets, b)
-			m[name] = b
+	var rw *recordWriter
+	if structuredOutput() {
+		rw = newRecordWriter("pid", "reg", "value")
+	}
+	for i, thr := range threads {
+		r := thr.Registers()
+		if r == nil {
+			return fmt.Errorf("registers of %s aren't decoded", p.Arch())
 		}
-		b.count++
-		return true
-	})
+		if rw != nil {
+			for _, name := range r.Names() {
+				v, _ := r.Get(name)
+				rw.write(thr.Pid(), name, core.Address(v))
+			}
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("thread %d\n", thr.Pid())
+		t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		for _, name := range r.Names() {
+			v, _ := r.Get(name)
+			fmt.Fprintf(t, "%s\t%#x\t%d\n", name, v, int64(v))
+		}
+		t.Flush()
+	}
+	if rw != nil {
+		rw.flush()
+	}
+	return nil
+}
+
+func runHistogram(cmd *cobra.Command, args []string) error {
+	topN, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return err
+	}
+	_, c, err := readCore()
+	if err != nil {
+		return err
+	}
+	buckets := histogram(c)
 	sort.Slice(buckets, func(i, j int) bool {
 		return buckets[i].size*buckets[i].count > buckets[j].size*buckets[j].count
 	})
@@ -498,21 +1109,572 @@ func runHistogram(cmd *cobra.Command, args []string) {
 		buckets = buckets[:topN]
 	}
 
+	if structuredOutput() {
+		rw := newRecordWriter("count", "size", "bytes", "type")
+		for _, e := range buckets {
+			rw.write(e.count, e.size, e.count*e.size, e.name)
+		}
+		rw.flush()
+		return nil
+	}
 	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
 	fmt.Fprintf(t, "%s\t%s\t%s\t %s\n", "count", "size", "bytes", "type")
 	for _, e := range buckets {
 		fmt.Fprintf(t, "%d\t%d\t%d\t %s\n", e.count, e.size, e.count*e.size, e.name)
 	}
 	t.Flush()
+	return nil
+}
+
+func runDupStrings(cmd *cobra.Command, args []string) error {
+	topN, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return err
+	}
+	p, c, err := readCore()
+	if err != nil {
+		return err
+	}
+	list := dupStrings(p, c)
+	var total int64
+	for _, d := range list {
+		total += d.wasted
+	}
+	n := len(list)
+	if topN > 0 && len(list) > topN {
+		list = list[:topN]
+	}
+
+	if structuredOutput() {
+		rw := newRecordWriter("count", "bytes", "wasted", "value", "referrer")
+		for _, d := range list {
+			rw.write(d.count, d.bytes, d.wasted, string(d.sample), referrerPath(c, d.owner))
+		}
+		rw.flush()
+		return nil
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(t, "%s\t%s\t%s\t %s\t %s\n", "count", "bytes", "wasted", "value", "referrer")
+	for _, d := range list {
+		fmt.Fprintf(t, "%d\t%d\t%d\t %s\t %s\n", d.count, d.bytes, d.wasted, quoteTruncated(d.sample, 40), referrerPath(c, d.owner))
+	}
+	t.Flush()
+	fmt.Printf("%d bytes wasted by %d duplicated values\n", total, n)
+	return nil
 }
 
-func runBreakdown(cmd *cobra.Command, args []string) {
+// A dupString is a string or byte slice content held by more than one
+// heap object.
+type dupString struct {
+	sample []byte // the first bytes of the content
+	count  int64
+	bytes  int64 // total size of the backing objects
+	wasted int64 // bytes that could be saved by sharing one copy
+	owner  valueOwner
+
+	first gocore.Object // the first object found with the content
+}
+
+// dupStrings returns the contents of strings and byte slices found in
+// more than one heap object, the most wasteful first.
+func dupStrings(p *core.Process, c *gocore.Process) []*dupString {
+	// Find the heap objects backing strings and byte slices.
+	// Only objects holding an entire string or slice are considered;
+	// substrings share their backing store and can't be deduplicated.
+	type backing struct {
+		n     int64      // length of the contents
+		owner valueOwner // first string or slice found referring to it
+	}
+	backings := map[gocore.Object]*backing{}
+	var objs []gocore.Object
+	forEachTypedValue(c, func(t *gocore.Type, a core.Address, o valueOwner) bool {
+		if t.Kind != gocore.KindString && !isByteSlice(t) {
+			return true
+		}
+		ptr := p.ReadPtr(a)
+		n := p.ReadInt(a.Add(p.PtrSize()))
+		if ptr == 0 || n <= 0 {
+			return true
+		}
+		x, off := c.FindObject(ptr)
+		if x == 0 || off != 0 || n > c.Size(x) {
+			return true
+		}
+		if b := backings[x]; b != nil {
+			if n > b.n {
+				b.n = n
+			}
+			return true
+		}
+		backings[x] = &backing{n: n, owner: o}
+		objs = append(objs, x)
+		return true
+	})
+
+	// Group the backing objects by their contents.
+	dups := &dupTable{
+		hash: func(b []byte) uint64 {
+			h := fnv.New64a()
+			h.Write(b)
+			return h.Sum64()
+		},
+		read: func(x gocore.Object, b []byte) {
+			p.ReadAt(b, c.Addr(x))
+		},
+	}
+	for _, x := range objs {
+		b := backings[x]
+		dups.add(x, b.n, c.Size(x), b.owner)
+	}
+	var list []*dupString
+	for _, d := range dups.list {
+		if d.count > 1 {
+			list = append(list, d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].wasted != list[j].wasted {
+			return list[i].wasted > list[j].wasted
+		}
+		return list[i].count > list[j].count
+	})
+	return list
+}
+
+// A dupTable groups heap objects by their contents. Objects are looked
+// up by the hash and length of their contents, and then compared byte
+// by byte with the first object of each group, so that hash collisions
+// don't merge different contents.
+type dupTable struct {
+	hash func([]byte) uint64
+	read func(x gocore.Object, b []byte) // read the contents of x into b
+
+	m    map[dupKey][]*dupString
+	list []*dupString // in the order the contents were first seen
+
+	buf, buf2 []byte
+}
+
+type dupKey struct {
+	hash uint64
+	n    int64
+}
+
+// add adds the heap object x of size size, holding n bytes of contents,
+// found through o.
+func (t *dupTable) add(x gocore.Object, n, size int64, o valueOwner) {
+	if t.m == nil {
+		t.m = map[dupKey][]*dupString{}
+	}
+	if int64(cap(t.buf)) < n {
+		t.buf = make([]byte, n)
+		t.buf2 = make([]byte, n)
+	}
+	b := t.buf[:n]
+	t.read(x, b)
+	k := dupKey{t.hash(b), n}
+	var d *dupString
+	for _, e := range t.m[k] {
+		b2 := t.buf2[:n]
+		t.read(e.first, b2)
+		if bytes.Equal(b, b2) {
+			d = e
+			break
+		}
+	}
+	if d == nil {
+		sample := b
+		if len(sample) > 64 {
+			sample = sample[:64]
+		}
+		d = &dupString{sample: append([]byte(nil), sample...), owner: o, first: x}
+		t.m[k] = append(t.m[k], d)
+		t.list = append(t.list, d)
+	} else {
+		d.wasted += size
+	}
+	d.count++
+	d.bytes += size
+}
+
+func runWaste(cmd *cobra.Command, args []string) error {
+	topN, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return err
+	}
+	p, c, err := readCore()
+	if err != nil {
+		return err
+	}
+	list := waste(p, c)
+	var total int64
+	for _, g := range list {
+		total += g.bytes - g.used
+	}
+	if topN > 0 && len(list) > topN {
+		list = list[:topN]
+	}
+	if structuredOutput() {
+		rw := newRecordWriter("count", "bytes", "used", "reclaimable", "kind", "owner")
+		for _, g := range list {
+			if g.bytes == g.used {
+				break
+			}
+			rw.write(g.count, g.bytes, g.used, g.bytes-g.used, g.kind, g.owner)
+		}
+		rw.flush()
+		return nil
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(t, "%s\t%s\t%s\t%s\t %s\t %s\n", "count", "bytes", "used", "reclaimable", "kind", "owner")
+	for _, g := range list {
+		if g.bytes == g.used {
+			break
+		}
+		fmt.Fprintf(t, "%d\t%d\t%d\t%d\t %s\t %s\n", g.count, g.bytes, g.used, g.bytes-g.used, g.kind, g.owner)
+	}
+	t.Flush()
+	fmt.Printf("%d bytes reclaimable\n", total)
+	return nil
+}
+
+// A wasteGroup totals the slices, maps or buffers of one kind held by
+// the same type and field.
+type wasteGroup struct {
+	kind  string // slice, map, bytes.Buffer or strings.Builder
+	owner string // as returned by ownerKey
+	count int64
+	bytes int64 // allocated
+	used  int64
+}
+
+// waste returns the allocated and used sizes of the slices, maps and
+// buffers in the process, grouped by kind and owner, the most
+// reclaimable first.
+func waste(p *core.Process, c *gocore.Process) []*wasteGroup {
+	groups := map[string]*wasteGroup{}
+	var list []*wasteGroup
+	add := func(kind string, o valueOwner, allocated, used int64) {
+		key := kind + " " + ownerKey(c, o)
+		g := groups[key]
+		if g == nil {
+			g = &wasteGroup{kind: kind, owner: ownerKey(c, o)}
+			groups[key] = g
+			list = append(list, g)
+		}
+		if used > allocated {
+			used = allocated
+		}
+		g.count++
+		g.bytes += allocated
+		g.used += used
+	}
+
+	// Each backing object or map is reported once, for the first
+	// reference found to it.
+	seen := map[core.Address]bool{}
+	// slice reports the slice at a, of which only the bytes [off:len]
+	// are in use.
+	slice := func(kind string, t *gocore.Type, a core.Address, off int64, o valueOwner) {
+		ptr := p.ReadPtr(a)
+		n := p.ReadInt(a.Add(p.PtrSize()))
+		x, i := c.FindObject(ptr)
+		if x == 0 || i != 0 || seen[ptr] {
+			// Not in the heap, or a subslice sharing its
+			// backing store with another slice.
+			return
+		}
+		seen[ptr] = true
+		used := (n - off) * t.Elem.Size
+		if used < 0 {
+			used = 0
+		}
+		add(kind, o, c.Size(x), used)
+	}
+	forEachTypedValue(c, func(t *gocore.Type, a core.Address, o valueOwner) bool {
+		switch {
+		case t.Name == "bytes.Buffer" && t.HasField("buf") && t.HasField("off"):
+			buf, off := t.Fields[0], int64(0)
+			for _, f := range t.Fields {
+				switch f.Name {
+				case "buf":
+					buf = f
+				case "off":
+					off = p.ReadInt(a.Add(f.Off))
+				}
+			}
+			slice(t.Name, buf.Type, a.Add(buf.Off), off, o)
+		case t.Name == "strings.Builder" && t.HasField("buf"):
+			for _, f := range t.Fields {
+				if f.Name == "buf" {
+					slice(t.Name, f.Type, a.Add(f.Off), 0, o)
+				}
+			}
+		case t.Kind == gocore.KindSlice:
+			slice("slice", t, a, 0, o)
+		case t.Kind == gocore.KindPtr:
+			kt, _ := c.MapTypes(t)
+			m := p.ReadPtr(a)
+			if kt == nil || m == 0 || seen[m] {
+				return true
+			}
+			seen[m] = true
+			n, size := c.MapBuckets(t, m)
+			need := mapBucketsNeeded(c.MapLen(t, m))
+			add("map", o, n*size, need*size)
+		}
+		return true
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].bytes-list[i].used > list[j].bytes-list[j].used
+	})
+	return list
+}
+
+// mapBucketsNeeded returns the number of buckets a map with n entries
+// needs, following the runtime's load factor of 6.5 entries per bucket.
+func mapBucketsNeeded(n int64) int64 {
+	const bucketCnt = 8
+	b := int64(1)
+	for n > bucketCnt && n*2 > 13*b {
+		b *= 2
+	}
+	return b
+}
+
+// ownerKey returns the type and field path of o, with array indexes
+// elided so that all elements of an array are grouped together.
+func ownerKey(c *gocore.Process, o valueOwner) string {
+	var s string
+	if o.r != nil {
+		s = o.ownerType(c) + o.field(c)
+	} else if t, _ := c.Type(o.x); t != nil {
+		s = t.Name + strings.TrimSuffix(typeFieldName(t, o.off%t.Size), typeFieldName(o.t, 0))
+	} else {
+		s = o.String(c)
+	}
+	return arrayIndexRE.ReplaceAllString(s, "[]")
+}
+
+var arrayIndexRE = regexp.MustCompile(`\[\d+\]`)
+
+// A histogramBucket counts the objects of one type.
+type histogramBucket struct {
+	name  string
+	size  int64
+	count int64
+}
+
+// histogram returns an object histogram (bytes per type) of the heap of c.
+func histogram(c *gocore.Process) []*histogramBucket {
+	var buckets []*histogramBucket
+	m := map[string]*histogramBucket{}
+	c.ForEachObject(func(x gocore.Object) bool {
+		name := typeName(c, x)
+		b := m[name]
+		if b == nil {
+			b = &histogramBucket{name: name, size: c.Size(x)}
+			buckets = append(buckets, b)
+			m[name] = b
+		}
+		b.count++
+		return true
+	})
+	return buckets
+}
+
+// dumperBuildID returns the build ID of the executable that dumped the
+// core p, or, if the core doesn't record it, that of the executable
+// loaded with it.
+func dumperBuildID(p *core.Process) core.BuildID {
+	if id := p.CoreBuildID(); !id.IsZero() {
+		return id
+	}
+	return p.BuildID()
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	topN, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		// In interactive mode, compare the current core to another.
+		if cfg.corefile == "" {
+			return fmt.Errorf("diff needs two core files")
+		}
+		args = []string{cfg.corefile, args[0]}
+	}
+	// Keep only the current core loaded; the other is needed only for
+	// the diff.
+	defer func() {
+		for cc := range coreCache {
+			if cc != cfg {
+				delete(coreCache, cc)
+			}
+		}
+	}()
+	var ps [2]*core.Process
+	var cs [2]*gocore.Process
+	for i, file := range args {
+		cc := cfg
+		cc.corefile = file
+		p, c, err := readCoreConfig(cc)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		ps[i], cs[i] = p, c
+	}
+	if id1, id2 := dumperBuildID(ps[0]), dumperBuildID(ps[1]); id1 != id2 && !cfg.ignoreBuildID {
+		return fmt.Errorf("%s and %s were dumped by different executables (build id %s and %s); use --ignore-build-id to compare them anyway", args[0], args[1], id1, id2)
+	}
+	c1, c2 := cs[0], cs[1]
+
+	// A diffEntry is a named quantity measured in both cores.
+	type diffEntry struct {
+		name           string
+		count1, count2 int64
+		bytes1, bytes2 int64
+	}
+	// In structured output, all reports are records of one list,
+	// told apart by their section.
+	var rw *recordWriter
+	if structuredOutput() {
+		rw = newRecordWriter("section", "name", "count1", "count2", "bytes1", "bytes2")
+	}
+	report := func(title, what string, m map[string]*diffEntry) {
+		var list []*diffEntry
+		for _, e := range m {
+			if e.count1 != e.count2 || e.bytes1 != e.bytes2 {
+				list = append(list, e)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool {
+			di, dj := list[i].bytes2-list[i].bytes1, list[j].bytes2-list[j].bytes1
+			if di != dj {
+				return di > dj
+			}
+			if di, dj := list[i].count2-list[i].count1, list[j].count2-list[j].count1; di != dj {
+				return di > dj
+			}
+			return list[i].name < list[j].name
+		})
+		if topN > 0 && len(list) > topN {
+			list = list[:topN]
+		}
+		if rw != nil {
+			for _, e := range list {
+				rw.write(title, e.name, e.count1, e.count2, e.bytes1, e.bytes2)
+			}
+			return
+		}
+		fmt.Printf("%s:\n", title)
+		t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(t, "%s\t%s\t%s\t%s\t%s\t%s\t %s\n", "count1", "count2", "Δcount", "bytes1", "bytes2", "Δbytes", what)
+		for _, e := range list {
+			fmt.Fprintf(t, "%d\t%d\t%+d\t%d\t%d\t%+d\t %s\n", e.count1, e.count2, e.count2-e.count1, e.bytes1, e.bytes2, e.bytes2-e.bytes1, e.name)
+		}
+		t.Flush()
+		fmt.Println()
+	}
+	entry := func(m map[string]*diffEntry, name string) *diffEntry {
+		e := m[name]
+		if e == nil {
+			e = &diffEntry{name: name}
+			m[name] = e
+		}
+		return e
+	}
+
+	// Heap histograms.
+	types := map[string]*diffEntry{}
+	for _, b := range histogram(c1) {
+		e := entry(types, b.name)
+		e.count1, e.bytes1 = b.count, b.count*b.size
+	}
+	for _, b := range histogram(c2) {
+		e := entry(types, b.name)
+		e.count2, e.bytes2 = b.count, b.count*b.size
+	}
+	report("heap objects", "type", types)
+
+	// Goroutines, grouped by stack.
+	stacks := map[string]*diffEntry{}
+	for i, c := range cs {
+		for _, g := range c.Goroutines() {
+			e := entry(stacks, stackKey(g))
+			if i == 0 {
+				e.count1++
+				e.bytes1 += g.Stack()
+			} else {
+				e.count2++
+				e.bytes2 += g.Stack()
+			}
+		}
+	}
+	report("goroutines", "stack", stacks)
+
+	// Globals, by retained size.
+	globals := map[string]*diffEntry{}
+	for i, c := range cs {
+		for _, r := range c.Globals() {
+			e := entry(globals, r.Name)
+			if i == 0 {
+				e.count1 = 1
+				e.bytes1 = c.RootRetained(r)
+			} else {
+				e.count2 = 1
+				e.bytes2 = c.RootRetained(r)
+			}
+		}
+	}
+	report("globals by retained size", "global", globals)
+	if rw != nil {
+		rw.flush()
+	}
+	return nil
+}
+
+// stackKey describes the stack of g by its innermost function calls.
+func stackKey(g *gocore.Goroutine) string {
+	const maxFrames = 4
+	var names []string
+	for _, f := range g.Frames() {
+		if len(names) == maxFrames {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, f.Func().Name())
+	}
+	if len(names) == 0 {
+		return "(no frames)"
+	}
+	return strings.Join(names, " ← ")
+}
+
+func runBreakdown(cmd *cobra.Command, args []string) error {
 	_, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
-	t := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', tabwriter.AlignRight)
 	all := c.Stats().Size
+	if structuredOutput() {
+		// Stats are identified by their path from the root, like "heap/in use spans".
+		rw := newRecordWriter("name", "bytes", "percent")
+		var writeStat func(*gocore.Stats, string)
+		writeStat = func(s *gocore.Stats, prefix string) {
+			name := prefix + s.Name
+			rw.write(name, s.Size, float64(s.Size)*100/float64(all))
+			for _, c := range s.Children {
+				writeStat(c, name+"/")
+			}
+		}
+		writeStat(c.Stats(), "")
+		rw.flush()
+		return nil
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', tabwriter.AlignRight)
 	var printStat func(*gocore.Stats, string)
 	printStat = func(s *gocore.Stats, indent string) {
 		comment := ""
@@ -534,12 +1696,13 @@ func runBreakdown(cmd *cobra.Command, args []string) {
 	printStat(c.Stats(), "")
 	t.Flush()
 
+	return nil
 }
 
-func runObjgraph(cmd *cobra.Command, args []string) {
+func runObjgraph(cmd *cobra.Command, args []string) error {
 	_, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
 
 	fname := args[0]
@@ -547,7 +1710,7 @@ func runObjgraph(cmd *cobra.Command, args []string) {
 	// Dump object graph to output file.
 	w, err := os.Create(fname)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	fmt.Fprintf(w, "digraph {\n")
 	for k, r := range c.Globals() {
@@ -599,103 +1762,169 @@ func runObjgraph(cmd *cobra.Command, args []string) {
 		return true
 	})
 	fmt.Fprintf(w, "}")
-	w.Close()
+	if err := w.Close(); err != nil {
+		return err
+	}
 	fmt.Fprintf(os.Stderr, "wrote the object graph to %q\n", fname)
+	return nil
 }
 
-func runObjects(cmd *cobra.Command, args []string) {
+func runObjects(cmd *cobra.Command, args []string) error {
 	_, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
+	}
+	if structuredOutput() {
+		rw := newRecordWriter("addr", "size", "type")
+		c.ForEachObject(func(x gocore.Object) bool {
+			rw.write(c.Addr(x), c.Size(x), typeName(c, x))
+			return true
+		})
+		rw.flush()
+		return nil
 	}
 	c.ForEachObject(func(x gocore.Object) bool {
 		fmt.Printf("%16x %s\n", c.Addr(x), typeName(c, x))
 		return true
 	})
 
+	return nil
 }
 
-func runReachable(cmd *cobra.Command, args []string) {
+func runContexts(cmd *cobra.Command, args []string) error {
 	_, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
+	}
+	if structuredOutput() {
+		rw := newRecordWriter("address", "type", "parent", "parent type", "canceled", "deadline", "key type", "value type", "retained")
+		for _, x := range c.Contexts() {
+			var parent interface{} = ""
+			if x.Parent != nil {
+				parent = c.Addr(x.Parent.Object)
+			}
+			deadline := ""
+			if !x.Deadline.IsZero() {
+				deadline = x.Deadline.Format(time.RFC3339Nano)
+			}
+			rw.write(c.Addr(x.Object), x.Type.String(), parent, optTypeString(x.ParentType), x.Canceled, deadline, optTypeString(x.KeyType), optTypeString(x.ValueType), x.Retained)
+		}
+		rw.flush()
+		return nil
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(t, "context\tstate\tdeadline\tvalue\tretained\n")
+	var printCtx func(x *gocore.Context, indent string)
+	printCtx = func(x *gocore.Context, indent string) {
+		state := "live"
+		if x.Canceled {
+			state = "canceled"
+		}
+		deadline := "-"
+		if !x.Deadline.IsZero() {
+			deadline = x.Deadline.Format(time.RFC3339Nano)
+		}
+		value := "-"
+		if x.KeyType != nil || x.ValueType != nil {
+			value = fmt.Sprintf("%s=%s", typeString(x.KeyType), typeString(x.ValueType))
+		}
+		fmt.Fprintf(t, "%s%x %s\t%s\t%s\t%s\t%d\n", indent, c.Addr(x.Object), x.Type, state, deadline, value, x.Retained)
+		for _, y := range x.Children {
+			printCtx(y, indent+"  ")
+		}
+	}
+	for _, x := range c.Contexts() {
+		if x.Parent != nil {
+			continue
+		}
+		if x.ParentType != nil {
+			fmt.Fprintf(t, "%s\t\t\t\t\n", x.ParentType)
+			printCtx(x, "  ")
+		} else {
+			printCtx(x, "")
+		}
+	}
+	t.Flush()
+	return nil
+}
+
+// typeString returns the name of t, or "nil" if t is nil.
+func typeString(t *gocore.Type) string {
+	if t == nil {
+		return "nil"
+	}
+	return t.String()
+}
+
+// optTypeString returns the name of t, or "" if t is nil.
+func optTypeString(t *gocore.Type) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
+
+func runReachable(cmd *cobra.Command, args []string) error {
+	_, c, err := readCore()
+	if err != nil {
+		return err
 	}
 	n, err := strconv.ParseInt(args[0], 16, 64)
 	if err != nil {
-		exitf("can't parse %q as an object address\n", args[1])
+		return fmt.Errorf("can't parse %q as an object address", args[0])
 	}
 	a := core.Address(n)
 	obj, _ := c.FindObject(a)
 	if obj == 0 {
-		exitf("can't find object at address %s\n", args[1])
-	}
-
-	// Breadth-first search backwards until we reach a root.
-	type hop struct {
-		i int64         // offset in "from" object (the key in the path map) where the pointer is
-		x gocore.Object // the "to" object
-		j int64         // the offset in the "to" object
-	}
-	depth := map[gocore.Object]int{}
-	depth[obj] = 0
-	q := []gocore.Object{obj}
-	done := false
-	for !done {
-		if len(q) == 0 {
-			panic("can't find a root that can reach the object")
-		}
-		y := q[0]
-		q = q[1:]
-		c.ForEachReversePtr(y, func(x gocore.Object, r *gocore.Root, i, j int64) bool {
-			if r != nil {
-				// found it.
-				if r.Frame == nil {
-					// Print global
-					fmt.Printf("%s", r.Name)
-				} else {
-					// Print stack up to frame in question.
-					var frames []*gocore.Frame
-					for f := r.Frame.Parent(); f != nil; f = f.Parent() {
-						frames = append(frames, f)
-					}
-					for k := len(frames) - 1; k >= 0; k-- {
-						fmt.Printf("%s\n", frames[k].Func().Name())
-					}
-					// Print frame + variable in frame.
-					fmt.Printf("%s.%s", r.Frame.Func().Name(), r.Name)
-				}
-				fmt.Printf("%s → \n", typeFieldName(r.Type, i))
-
-				z := y
-				for {
-					fmt.Printf("%x %s", c.Addr(z), typeName(c, z))
-					if z == obj {
-						fmt.Println()
-						break
-					}
-					// Find an edge out of z which goes to an object
-					// closer to obj.
-					c.ForEachPtr(z, func(i int64, w gocore.Object, j int64) bool {
-						if d, ok := depth[w]; ok && d < depth[z] {
-							fmt.Printf(" %s → %s", objField(c, z, i), objRegion(c, w, j))
-							z = w
-							return false
-						}
-						return true
-					})
-					fmt.Println()
-				}
-				done = true
-				return false
-			}
-			if _, ok := depth[x]; ok {
-				// we already found a shorter path to this object.
-				return true
-			}
-			depth[x] = depth[y] + 1
-			q = append(q, x)
-			return true
-		})
+		return fmt.Errorf("can't find object at address %s", args[0])
+	}
+	r, i, y, depth := pathToRoot(c, obj)
+	if r == nil {
+		return fmt.Errorf("can't find a root that can reach the object")
+	}
+	printReachablePath(c, r, i, y, obj, depth)
+	return nil
+}
+
+// printReachablePath prints the path from the root r, through the pointer
+// at offset i in r, to the object y, and from there to obj, following
+// objects of decreasing depth.
+func printReachablePath(c *gocore.Process, r *gocore.Root, i int64, y, obj gocore.Object, depth map[gocore.Object]int) {
+	hops := pathHops(c, y, obj, depth)
+
+	name := r.Name
+	if r.Frame != nil {
+		name = r.Frame.Func().Name() + "." + r.Name
+	}
+	if structuredOutput() {
+		rw := newRecordWriter("hop", "addr", "name", "field")
+		rw.write(0, r.Addr, name, typeFieldName(r.Type, i))
+		for k, h := range hops {
+			rw.write(k+1, c.Addr(h.x), typeName(c, h.x), h.field)
+		}
+		rw.flush()
+		return
+	}
+
+	if r.Frame != nil {
+		// Print stack up to frame in question.
+		var frames []*gocore.Frame
+		for f := r.Frame.Parent(); f != nil; f = f.Parent() {
+			frames = append(frames, f)
+		}
+		for k := len(frames) - 1; k >= 0; k-- {
+			fmt.Printf("%s\n", frames[k].Func().Name())
+		}
+	}
+	// Print global, or frame + variable in frame.
+	fmt.Printf("%s", name)
+	fmt.Printf("%s → \n", typeFieldName(r.Type, i))
+	for _, h := range hops {
+		fmt.Printf("%x %s", c.Addr(h.x), typeName(c, h.x))
+		if h.x != obj {
+			fmt.Printf(" %s → %s", h.field, h.to)
+		}
+		fmt.Println()
 	}
 }
 
@@ -706,35 +1935,147 @@ var httpServer struct {
 	port int
 }
 
-func runHTML(cmd *cobra.Command, args []string) {
+func runHTML(cmd *cobra.Command, args []string) error {
 	httpServer.Lock()
 	defer httpServer.Unlock()
 	if httpServer.port != 0 {
 		fmt.Printf("already serving on http://localhost:%d\n", httpServer.port)
-		return
+		return nil
 	}
 	_, c, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
 
 	port, err := cmd.Flags().GetInt("port")
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
 	serveHTML(c, port, cfg.interactive)
 	httpServer.port = port
 	// TODO: launch web browser
+	return nil
 }
 
-func runRead(cmd *cobra.Command, args []string) {
+func runPrint(cmd *cobra.Command, args []string) error {
+	depth, err := cmd.Flags().GetInt("depth")
+	if err != nil {
+		return err
+	}
+	p, c, err := readCore()
+	if err != nil {
+		return err
+	}
+	e := newEvaluator(p, c)
+	if len(args) >= 2 && args[0] == "g" {
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("can't parse %q as a goroutine id", args[1])
+		}
+		var g *gocore.Goroutine
+		for _, x := range c.Goroutines() {
+			if x.ID() == id {
+				g = x
+				break
+			}
+		}
+		if g == nil {
+			return fmt.Errorf("no goroutine %d", id)
+		}
+		args = args[2:]
+		frame := 0
+		if len(args) >= 2 && args[0] == "frame" {
+			frame, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("can't parse %q as a frame number", args[1])
+			}
+			args = args[2:]
+		}
+		if frame < 0 || frame >= len(g.Frames()) {
+			return fmt.Errorf("goroutine %d has no frame %d", id, frame)
+		}
+		e.frame = g.Frames()[frame]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing expression")
+	}
+	v, err := e.eval(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	s, err := e.formatValue(v, depth)
+	if err != nil {
+		return err
+	}
+	if structuredOutput() {
+		// Constants have no address.
+		var addr interface{} = ""
+		if v.c == nil {
+			addr = v.a
+		}
+		rw := newRecordWriter("expression", "type", "address", "value")
+		rw.write(strings.Join(args, " "), optTypeString(v.typ), addr, s)
+		rw.flush()
+		return nil
+	}
+	fmt.Println(s)
+	return nil
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	p, c, err := readCore()
+	if err != nil {
+		return err
+	}
+	e := newEvaluator(p, c)
+	q, err := parseQuery(e, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	res := q.run(e)
+	if structuredOutput() {
+		rw := newRecordWriter(res.header...)
+		for _, row := range res.rows {
+			values := make([]interface{}, len(row))
+			for i, cell := range row {
+				values[i] = cell.s
+				if cell.key != nil && cell.key.Kind() == constant.Int {
+					if n, ok := constant.Int64Val(cell.key); ok {
+						values[i] = n
+					}
+				}
+			}
+			rw.write(values...)
+		}
+		rw.flush()
+	} else {
+		t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(t, "%s\n", strings.Join(res.header, "\t"))
+		for _, row := range res.rows {
+			for i, cell := range row {
+				if i > 0 {
+					fmt.Fprintf(t, "\t")
+				}
+				fmt.Fprintf(t, "%s", cell.s)
+			}
+			fmt.Fprintf(t, "\n")
+		}
+		t.Flush()
+	}
+	if res.errs > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: query failed for %d objects: %v\n", res.errs, res.err)
+	}
+	return nil
+}
+
+func runRead(cmd *cobra.Command, args []string) error {
 	p, _, err := readCore()
 	if err != nil {
-		exitf("%v\n", err)
+		return err
 	}
 	n, err := strconv.ParseInt(args[0], 16, 64)
 	if err != nil {
-		exitf("can't parse %q as an object address\n", args[1])
+		return fmt.Errorf("can't parse %q as an address", args[0])
 	}
 	a := core.Address(n)
 	if len(args) < 2 {
@@ -742,24 +2083,44 @@ func runRead(cmd *cobra.Command, args []string) {
 	} else {
 		n, err = strconv.ParseInt(args[1], 10, 64)
 		if err != nil {
-			exitf("can't parse %q as a byte count\n", args[2])
+			return fmt.Errorf("can't parse %q as a byte count", args[1])
 		}
 	}
 	if !p.ReadableN(a, n) {
-		exitf("address range [%x,%x] not readable\n", a, a.Add(n))
+		return fmt.Errorf("address range [%x,%x] not readable", a, a.Add(n))
 	}
 	b := make([]byte, n)
 	p.ReadAt(b, a)
-	for i, x := range b {
-		if i%16 == 0 {
-			if i > 0 {
-				fmt.Println()
+	if structuredOutput() {
+		// One record per line of the text output.
+		rw := newRecordWriter("addr", "bytes", "symbol")
+		for i := 0; i < len(b); i += 16 {
+			j := i + 16
+			if j > len(b) {
+				j = len(b)
+			}
+			rw.write(a.Add(int64(i)), hex.EncodeToString(b[i:j]), symbolName(p, a.Add(int64(i))))
+		}
+		rw.flush()
+		return nil
+	}
+	// Each line ends with the symbol its first byte is in, if any.
+	for i := 0; i < len(b); i += 16 {
+		sym := symbolName(p, a.Add(int64(i)))
+		fmt.Printf("%x:", a.Add(int64(i)))
+		for j := i; j < i+16 && (j < len(b) || sym != ""); j++ {
+			if j < len(b) {
+				fmt.Printf(" %02x", b[j])
+			} else {
+				fmt.Printf("   ")
 			}
-			fmt.Printf("%x:", a.Add(int64(i)))
 		}
-		fmt.Printf(" %02x", x)
+		if sym != "" {
+			fmt.Printf("  %s", sym)
+		}
+		fmt.Println()
 	}
-	fmt.Println()
+	return nil
 }
 
 // typeName returns a string representing the type of this object.
@@ -867,8 +2228,3 @@ func endProfile() {
 		pprof.StopCPUProfile()
 	}
 }
-
-func exitf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, args...)
-	os.Exit(1)
-}
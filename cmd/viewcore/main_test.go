@@ -0,0 +1,170 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/debug/internal/core"
+	"golang.org/x/debug/internal/gocore"
+)
+
+// TestDupStrings checks the duplicated contents found in the core of
+// ../../internal/gocore/testdata/dupstrings/test.go.
+func TestDupStrings(t *testing.T) {
+	p, c := loadZipTestCore(t, "1.20-dupstrings.zip")
+	var got []string
+	for _, d := range dupStrings(p, c) {
+		got = append(got, fmt.Sprintf("%q %d %d %d %s", d.sample, d.count, d.bytes, d.wasted, d.owner.String(c)))
+	}
+	want := []string{
+		`"xxxxxxxxxxxxxxxxxxxxname" 10 240 216 main.entry.name`,
+		`"value 0" 5 80 64 main.entry.value`,
+		`"value 1" 5 80 64 main.entry.value`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got duplicates\n%q\nwant\n%q", got, want)
+	}
+
+	d := dupStrings(p, c)[0]
+	path := referrerPath(c, d.owner)
+	if want := "main.tab → main.table.entries.ptr → [16]*main.entry[0] → main.entry.name"; path != want {
+		t.Errorf("referrer path %s, want %s", path, want)
+	}
+}
+
+// TestDupTable checks that contents with the same hash are only grouped
+// together if they are equal.
+func TestDupTable(t *testing.T) {
+	contents := map[gocore.Object]string{1: "ab", 2: "cd", 3: "ab", 4: "abc"}
+	dups := &dupTable{
+		hash: func([]byte) uint64 { return 0 },
+		read: func(x gocore.Object, b []byte) { copy(b, contents[x]) },
+	}
+	for x := gocore.Object(1); x <= 4; x++ {
+		dups.add(x, int64(len(contents[x])), 8, valueOwner{})
+	}
+	var got []string
+	for _, d := range dups.list {
+		got = append(got, fmt.Sprintf("%s:%d:%d", d.sample, d.count, d.wasted))
+	}
+	if want := "[ab:2:8 cd:1:0 abc:1:0]"; fmt.Sprint(got) != want {
+		t.Errorf("got groups %v, want %s", got, want)
+	}
+}
+
+// TestWaste checks the unused capacity found in the core of
+// ../../internal/gocore/testdata/waste/test.go.
+func TestWaste(t *testing.T) {
+	p, c := loadZipTestCore(t, "1.20-waste.zip")
+	got := map[string]string{}
+	for _, g := range waste(p, c) {
+		if strings.Contains(g.owner, "main.") {
+			got[g.kind+" "+g.owner] = fmt.Sprintf("%d %d %d", g.count, g.bytes, g.used)
+		}
+	}
+	// Each cache's map grew to 256 buckets of 208 bytes, and needs one.
+	want := map[string]string{
+		"map main.cache.items":          "2 106496 416",
+		"slice main.cache.ids":          "2 16384 160",
+		"bytes.Buffer main.cache.buf":   "2 2048 200",
+		"strings.Builder main.cache.sb": "2 8192 10",
+		"slice global main.lists[]":     "3 192 3",
+		"slice global main.caches":      "1 16 16",
+	}
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("%s: got count, bytes, used %q, want %q", k, got[k], w)
+		}
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("unexpected group %s: %s", k, got[k])
+		}
+	}
+}
+
+func TestMapBucketsNeeded(t *testing.T) {
+	// The runtime grows a map with more than 8 entries when it holds
+	// more than 6.5 entries per bucket.
+	for _, test := range []struct{ n, want int64 }{
+		{0, 1},
+		{8, 1},
+		{9, 2},
+		{13, 2},
+		{14, 4},
+		{26, 4},
+		{27, 8},
+		{1000, 256},
+	} {
+		if got := mapBucketsNeeded(test.n); got != test.want {
+			t.Errorf("mapBucketsNeeded(%d) = %d, want %d", test.n, got, test.want)
+		}
+	}
+}
+
+func TestOwnerKey(t *testing.T) {
+	_, c := loadZipTestCore(t, "1.20-waste.zip")
+	keys := map[string]bool{}
+	forEachTypedValue(c, func(typ *gocore.Type, _ core.Address, o valueOwner) bool {
+		if typ.Kind == gocore.KindSlice {
+			keys[ownerKey(c, o)] = true
+		}
+		return true
+	})
+	for _, k := range []string{
+		"global main.lists[]",  // array index elided
+		"global main.caches",   // global
+		"global runtime.allgs", // global of another package
+		"main.cache.ids",       // field of a heap object
+		"main.cache.buf.buf",   // field of a field
+		"main.cache.sb.buf",
+	} {
+		if !keys[k] {
+			t.Errorf("no owner %s", k)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	for _, test := range []struct {
+		l    string
+		want []string
+		err  string
+	}{
+		{l: "", want: nil},
+		{l: "  goroutines  ", want: []string{"goroutines"}},
+		{l: "print  x.y\t+ 1", want: []string{"print", "x.y", "+", "1"}},
+		{l: `query 'select .a where .s == "a b"'`, want: []string{"query", `select .a where .s == "a b"`}},
+		{l: `print m["a b"]`, want: []string{"print", "m[a b]"}},
+		{l: `find -s "a \"b\" \\ \c"`, want: []string{"find", "-s", `a "b" \ \c`}},
+		{l: `find -s a\ b 'it''s'`, want: []string{"find", "-s", "a b", "its"}},
+		{l: `x ''`, want: []string{"x", ""}},
+
+		{l: `find -s 'abc`, err: "unterminated '"},
+		{l: `find -s "abc`, err: `unterminated "`},
+		{l: `find -s abc\`, err: "trailing backslash"},
+	} {
+		got, err := splitCommand(test.l)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("splitCommand(%q): got error %v, want %q", test.l, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitCommand(%q): %v", test.l, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", test.l, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,670 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/scanner"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/debug/internal/gocore"
+)
+
+// A query is a parsed heap query, of the form
+//
+//	select <item>, ... [from <type>] [where <cond>]
+//	    [group by <item>, ...] [order by <item> [asc|desc]] [limit <n>]
+//
+// Items and conditions are Go expressions (see evaluator) in which
+//
+//	.f.g        selects fields of the current object
+//	addr        is the address of the object
+//	size        is its size in bytes
+//	retained    is the number of bytes it retains
+//	type        is the name of its type, as printed by histogram
+//	and, or, not may be used for &&, || and !
+//
+// Select items may also be the aggregates count(*), sum(x), min(x),
+// max(x) and avg(x), computed over all objects of a group. The type
+// in the from clause is a type name as printed by histogram, or a
+// path.Match pattern of one. A leading * is ignored, so both
+// "from main.Session" and "from *main.Session" select the objects
+// of type main.Session. Without a from clause, objects whose type
+// lacks the fields the query selects are skipped.
+type query struct {
+	items   []*queryItem // select list, followed by hidden items
+	nShown  int          // number of items in the select list
+	from    string
+	where   ast.Expr
+	groupBy []*queryItem
+	orderBy int // index of the sort item in items, or -1
+	desc    bool
+	limit   int // or -1
+
+	// fields holds the chains of fields the query selects on the
+	// current object, like [f g] for .f.g. Without a from clause,
+	// objects whose type doesn't have them are skipped.
+	fields [][]string
+}
+
+// A queryItem is an expression in a select list or a group by clause.
+type queryItem struct {
+	text string   // as written in the query
+	x    ast.Expr // expression, or the aggregate's argument
+	agg  string   // name of the aggregate function, if any
+}
+
+// aggregates is the set of aggregate functions.
+var aggregates = map[string]bool{"count": true, "sum": true, "min": true, "max": true, "avg": true}
+
+// objectVar is the identifier bound to the current object in
+// rewritten query expressions.
+const objectVar = "_obj"
+
+// A queryToken is a token of a query, with the original text it spans.
+type queryToken struct {
+	tok        token.Token
+	lit        string
+	start, end int // byte offsets in the query
+}
+
+// parseQuery parses the query s. Expressions are parsed with e.
+func parseQuery(e *evaluator, s string) (*query, error) {
+	var sc scanner.Scanner
+	var errs scanner.ErrorList
+	fset := token.NewFileSet()
+	file := fset.AddFile("query", -1, len(s))
+	sc.Init(file, []byte(s), func(pos token.Position, msg string) { errs.Add(pos, msg) }, 0)
+	var toks []queryToken
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue // automatically inserted
+		}
+		start := file.Offset(pos)
+		end := start + len(lit)
+		if lit == "" {
+			end = start + len(tok.String())
+		}
+		toks = append(toks, queryToken{tok, lit, start, end})
+	}
+	if errs.Len() > 0 {
+		return nil, errs.Err()
+	}
+
+	// Split the query into clauses.
+	clauses := map[string][]queryToken{}
+	var order []string
+	clause := ""
+	depth := 0
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch t.tok {
+		case token.LPAREN, token.LBRACK:
+			depth++
+		case token.RPAREN, token.RBRACK:
+			depth--
+		}
+		kw := ""
+		if depth == 0 {
+			switch {
+			case t.tok == token.SELECT:
+				kw = "select"
+			case t.tok == token.IDENT && (t.lit == "from" || t.lit == "where" || t.lit == "limit"):
+				kw = t.lit
+			case t.tok == token.IDENT && (t.lit == "group" || t.lit == "order") &&
+				i+1 < len(toks) && toks[i+1].tok == token.IDENT && toks[i+1].lit == "by":
+				kw = t.lit + " by"
+				i++
+			}
+		}
+		if kw != "" {
+			if _, ok := clauses[kw]; ok {
+				return nil, fmt.Errorf("duplicate %s clause", kw)
+			}
+			clause = kw
+			clauses[kw] = []queryToken{}
+			order = append(order, kw)
+			continue
+		}
+		if clause == "" {
+			return nil, fmt.Errorf("query must start with select")
+		}
+		clauses[clause] = append(clauses[clause], t)
+	}
+	if len(order) == 0 || order[0] != "select" {
+		return nil, fmt.Errorf("query must start with select")
+	}
+
+	q := &query{orderBy: -1, limit: -1}
+	var err error
+	if sel := clauses["select"]; len(sel) == 1 && sel[0].tok == token.MUL {
+		for _, name := range []string{"addr", "size", "type"} {
+			q.items = append(q.items, &queryItem{text: name, x: ast.NewIdent(name)})
+		}
+		q.items[2].x = ast.NewIdent("_type")
+	} else if q.items, err = parseQueryItems(e, s, sel); err != nil {
+		return nil, err
+	}
+	q.nShown = len(q.items)
+	if from, ok := clauses["from"]; ok {
+		if len(from) == 0 {
+			return nil, fmt.Errorf("missing type in from clause")
+		}
+		q.from = strings.TrimPrefix(s[from[0].start:from[len(from)-1].end], "*")
+		if _, err := path.Match(q.from, ""); err != nil {
+			return nil, fmt.Errorf("bad type pattern %q: %v", q.from, err)
+		}
+	}
+	if where, ok := clauses["where"]; ok {
+		if q.where, err = parseQueryExpr(e, s, where); err != nil {
+			return nil, fmt.Errorf("bad where clause: %v", err)
+		}
+	}
+	if group, ok := clauses["group by"]; ok {
+		if q.groupBy, err = parseQueryItems(e, s, group); err != nil {
+			return nil, err
+		}
+		for _, g := range q.groupBy {
+			if g.agg != "" {
+				return nil, fmt.Errorf("can't group by aggregate %s", g.text)
+			}
+		}
+	}
+	if ob, ok := clauses["order by"]; ok {
+		if n := len(ob); n > 0 && ob[n-1].tok == token.IDENT && (ob[n-1].lit == "asc" || ob[n-1].lit == "desc") {
+			q.desc = ob[n-1].lit == "desc"
+			ob = ob[:n-1]
+		}
+		items, err := parseQueryItems(e, s, ob)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 1 {
+			return nil, fmt.Errorf("can only order by a single item")
+		}
+		// Order by a column of the select list, if it is one.
+		for i, it := range q.items {
+			if it.text == items[0].text {
+				q.orderBy = i
+			}
+		}
+		if q.orderBy < 0 {
+			q.orderBy = len(q.items)
+			q.items = append(q.items, items[0])
+		}
+	}
+	if lim, ok := clauses["limit"]; ok {
+		if len(lim) != 1 || lim[0].tok != token.INT {
+			return nil, fmt.Errorf("limit must be an integer")
+		}
+		if q.limit, err = strconv.Atoi(lim[0].lit); err != nil {
+			return nil, fmt.Errorf("bad limit: %v", err)
+		}
+	}
+	if q.aggregate() {
+		for _, it := range q.items {
+			if it.agg == "" && !q.grouped(it) {
+				return nil, fmt.Errorf("%s must be an aggregate or appear in the group by clause", it.text)
+			}
+		}
+	}
+	exprs := []ast.Expr{q.where}
+	for _, it := range q.items {
+		exprs = append(exprs, it.x)
+	}
+	for _, it := range q.groupBy {
+		exprs = append(exprs, it.x)
+	}
+	for _, x := range exprs {
+		if x != nil {
+			q.fields = append(q.fields, objectFields(x)...)
+		}
+	}
+	return q, nil
+}
+
+// objectFields returns the chains of fields selected on the current
+// object in x.
+func objectFields(x ast.Expr) [][]string {
+	var fields [][]string
+	ast.Inspect(x, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		var chain []string
+		var y ast.Expr = sel
+		for {
+			s, ok := y.(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+			chain = append([]string{s.Sel.Name}, chain...)
+			y = s.X
+		}
+		if id, ok := y.(*ast.Ident); ok && id.Name == objectVar {
+			fields = append(fields, chain)
+			return false
+		}
+		return true
+	})
+	return fields
+}
+
+// hasFields reports whether t has the chain of fields f, looking
+// through pointers as field selection does.
+func hasFields(t *gocore.Type, f []string) bool {
+	for _, name := range f {
+		if t != nil && t.Kind == gocore.KindPtr {
+			t = t.Elem
+		}
+		if t == nil || t.Kind != gocore.KindStruct {
+			return false
+		}
+		var next *gocore.Type
+		for _, tf := range t.Fields {
+			if tf.Name == name {
+				next = tf.Type
+				break
+			}
+		}
+		if next == nil {
+			return false
+		}
+		t = next
+	}
+	return true
+}
+
+// parseQueryItems parses a comma-separated list of items.
+func parseQueryItems(e *evaluator, s string, toks []queryToken) ([]*queryItem, error) {
+	var items []*queryItem
+	depth := 0
+	start := 0
+	for i := 0; i <= len(toks); i++ {
+		if i < len(toks) {
+			switch toks[i].tok {
+			case token.LPAREN, token.LBRACK:
+				depth++
+			case token.RPAREN, token.RBRACK:
+				depth--
+			}
+			if toks[i].tok != token.COMMA || depth != 0 {
+				continue
+			}
+		}
+		part := toks[start:i]
+		start = i + 1
+		if len(part) == 0 {
+			return nil, fmt.Errorf("missing item")
+		}
+		it := &queryItem{text: s[part[0].start:part[len(part)-1].end]}
+		x, err := parseQueryExpr(e, s, part)
+		if err != nil {
+			return nil, fmt.Errorf("bad item %s: %v", it.text, err)
+		}
+		it.x = x
+		if call, ok := x.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && aggregates[id.Name] {
+				it.agg = id.Name
+				switch {
+				case id.Name == "count" && len(call.Args) == 0:
+					it.x = nil
+				case len(call.Args) == 1:
+					it.x = call.Args[0]
+				default:
+					return nil, fmt.Errorf("%s takes one argument", id.Name)
+				}
+			}
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// parseQueryExpr rewrites the query syntax in toks to Go and parses the
+// result as an expression.
+func parseQueryExpr(e *evaluator, s string, toks []queryToken) (ast.Expr, error) {
+	var b strings.Builder
+	prev := token.ILLEGAL
+	for i, t := range toks {
+		if i > 0 {
+			b.WriteString(s[toks[i-1].end:t.start])
+		}
+		text := s[t.start:t.end]
+		tok := t.tok
+		switch {
+		case t.tok == token.IDENT && t.lit == "and":
+			text, tok = "&&", token.LAND
+		case t.tok == token.IDENT && t.lit == "or":
+			text, tok = "||", token.LOR
+		case t.tok == token.IDENT && t.lit == "not":
+			text, tok = "!", token.NOT
+		case t.tok == token.TYPE:
+			text = "_type"
+		case t.tok == token.PERIOD && !endsOperand(prev):
+			text = objectVar + "."
+		case t.tok == token.MUL && prev == token.LPAREN && i > 1 && toks[i-2].lit == "count" &&
+			i+1 < len(toks) && toks[i+1].tok == token.RPAREN:
+			text = "" // count(*)
+		}
+		b.WriteString(text)
+		prev = tok
+	}
+	return e.parseExpr(b.String())
+}
+
+// endsOperand reports whether tok can be the last token of an operand,
+// in which case a following period is a selector.
+func endsOperand(tok token.Token) bool {
+	switch tok {
+	case token.IDENT, token.RPAREN, token.RBRACK, token.INT, token.STRING:
+		return true
+	}
+	return false
+}
+
+// aggregate reports whether q computes aggregates over groups of objects.
+func (q *query) aggregate() bool {
+	if len(q.groupBy) > 0 {
+		return true
+	}
+	for _, it := range q.items {
+		if it.agg != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// grouped reports whether it is one of the group by items of q.
+func (q *query) grouped(it *queryItem) bool {
+	for _, g := range q.groupBy {
+		if g.text == it.text {
+			return true
+		}
+	}
+	return false
+}
+
+// A queryCell is one computed value of a query result.
+type queryCell struct {
+	s   string         // formatted value
+	key constant.Value // for sorting and aggregation; nil if not a basic value
+}
+
+// A queryGroup accumulates the aggregates of a group of objects.
+type queryGroup struct {
+	cells []queryCell // non-aggregate items, from the first object
+	count []int64     // per item, number of objects with a value
+	acc   []constant.Value
+}
+
+// A queryResult is the result of running a query.
+type queryResult struct {
+	header []string
+	rows   [][]queryCell
+	// errs is the number of objects for which evaluating
+	// the query failed, and err the first such error.
+	errs int
+	err  error
+}
+
+// run runs q over all objects in the heap.
+func (q *query) run(e *evaluator) *queryResult {
+	c := e.c
+	res := &queryResult{}
+	for _, it := range q.items[:q.nShown] {
+		res.header = append(res.header, it.text)
+	}
+	fail := func(err error) {
+		if res.errs == 0 {
+			res.err = err
+		}
+		res.errs++
+	}
+	unsafePointer := c.FindType("unsafe.Pointer")
+
+	var x gocore.Object
+	var typ *gocore.Type
+	var tname string
+	e.vars = func(name string) (value, bool) {
+		switch name {
+		case objectVar:
+			if typ == nil {
+				panic(fmt.Sprintf("object %x has unknown type", c.Addr(x)))
+			}
+			return value{typ: typ, a: c.Addr(x)}, true
+		case "addr":
+			return value{typ: unsafePointer, c: constant.MakeUint64(uint64(c.Addr(x)))}, true
+		case "size":
+			return value{c: constant.MakeInt64(c.Size(x))}, true
+		case "retained":
+			return value{c: constant.MakeInt64(c.Retained(x))}, true
+		case "_type":
+			return value{c: constant.MakeString(tname)}, true
+		}
+		return value{}, false
+	}
+	defer func() { e.vars = nil }()
+
+	groups := map[string]*queryGroup{}
+	var groupOrder []string
+	c.ForEachObject(func(obj gocore.Object) bool {
+		x = obj
+		tname = typeName(c, x)
+		if q.from != "" {
+			if ok, _ := path.Match(q.from, tname); !ok {
+				return true
+			}
+		}
+		typ, _ = c.Type(x)
+		if q.from == "" {
+			for _, f := range q.fields {
+				if !hasFields(typ, f) {
+					return true
+				}
+			}
+		}
+		if q.where != nil {
+			v, err := e.evalSafe(q.where)
+			if err == nil {
+				var ok bool
+				if ok, err = e.truth(v); err == nil && !ok {
+					return true
+				}
+			}
+			if err != nil {
+				fail(err)
+				return true
+			}
+		}
+		if !q.aggregate() {
+			row := make([]queryCell, len(q.items))
+			for i, it := range q.items {
+				row[i] = q.cell(e, it.x, fail)
+			}
+			res.rows = append(res.rows, row)
+			return true
+		}
+		var key strings.Builder
+		for _, it := range q.groupBy {
+			key.WriteString(q.cell(e, it.x, fail).s)
+			key.WriteByte(0)
+		}
+		g := groups[key.String()]
+		if g == nil {
+			g = &queryGroup{
+				cells: make([]queryCell, len(q.items)),
+				count: make([]int64, len(q.items)),
+				acc:   make([]constant.Value, len(q.items)),
+			}
+			for i, it := range q.items {
+				if it.agg == "" {
+					g.cells[i] = q.cell(e, it.x, fail)
+				}
+			}
+			groups[key.String()] = g
+			groupOrder = append(groupOrder, key.String())
+		}
+		for i, it := range q.items {
+			if it.agg == "" {
+				continue
+			}
+			if it.x == nil { // count(*)
+				g.count[i]++
+				continue
+			}
+			cell := q.cell(e, it.x, fail)
+			if cell.key == nil {
+				continue
+			}
+			g.count[i]++
+			g.acc[i] = accumulate(it.agg, g.acc[i], cell.key)
+		}
+		return true
+	})
+
+	for _, k := range groupOrder {
+		g := groups[k]
+		for i, it := range q.items {
+			if it.agg == "" {
+				continue
+			}
+			v := g.acc[i]
+			switch it.agg {
+			case "count":
+				v = constant.MakeInt64(g.count[i])
+			case "avg":
+				if g.count[i] > 0 {
+					v = constant.BinaryOp(constant.ToFloat(v), token.QUO, constant.MakeInt64(g.count[i]))
+				}
+			}
+			if v == nil {
+				g.cells[i] = queryCell{s: "-"}
+				continue
+			}
+			s := v.String()
+			if f, ok := constant.Float64Val(v); ok && v.Kind() == constant.Float {
+				s = strconv.FormatFloat(f, 'f', 2, 64)
+			}
+			g.cells[i] = queryCell{s: s, key: v}
+		}
+		res.rows = append(res.rows, g.cells)
+	}
+
+	if q.orderBy >= 0 {
+		o := q.orderBy
+		sort.SliceStable(res.rows, func(i, j int) bool {
+			a, b := res.rows[i][o], res.rows[j][o]
+			if q.desc {
+				a, b = b, a
+			}
+			return cellLess(a, b)
+		})
+	}
+	if q.limit >= 0 && len(res.rows) > q.limit {
+		res.rows = res.rows[:q.limit]
+	}
+	for i, row := range res.rows {
+		res.rows[i] = row[:q.nShown]
+	}
+	return res
+}
+
+// cell evaluates x for the current object.
+func (q *query) cell(e *evaluator, x ast.Expr, fail func(error)) queryCell {
+	v, err := e.evalSafe(x)
+	if err != nil {
+		fail(err)
+		return queryCell{s: "?"}
+	}
+	var s string
+	if v.c != nil && v.c.Kind() == constant.String {
+		s = constant.StringVal(v.c) // like type
+	} else if s, err = e.formatValue(v, 1); err != nil {
+		fail(err)
+		return queryCell{s: "?"}
+	}
+	cell := queryCell{s: s}
+	if v.c != nil || v.typ.Kind <= gocore.KindFloat || v.typ.Kind == gocore.KindString || v.typ.Kind == gocore.KindPtr {
+		cell.key, _ = e.constant(v)
+	}
+	return cell
+}
+
+// evalSafe evaluates x, converting panics from reading memory into errors.
+func (e *evaluator) evalSafe(x ast.Expr) (v value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return e.evalExpr(x)
+}
+
+// truth returns the value of the boolean v.
+func (e *evaluator) truth(v value) (bool, error) {
+	c, err := e.constant(v)
+	if err != nil {
+		return false, err
+	}
+	if c.Kind() != constant.Bool {
+		return false, fmt.Errorf("condition is not a boolean: %s", c)
+	}
+	return constant.BoolVal(c), nil
+}
+
+// accumulate adds v to the aggregate agg with current value acc.
+func accumulate(agg string, acc, v constant.Value) constant.Value {
+	if acc == nil {
+		return v
+	}
+	if !comparable(acc, v) {
+		return acc
+	}
+	switch agg {
+	case "sum", "avg":
+		if v.Kind() == constant.String || v.Kind() == constant.Bool {
+			return acc
+		}
+		return constant.BinaryOp(acc, token.ADD, v)
+	case "min":
+		if constant.Compare(v, token.LSS, acc) {
+			return v
+		}
+	case "max":
+		if constant.Compare(v, token.GTR, acc) {
+			return v
+		}
+	}
+	return acc
+}
+
+// comparable reports whether constant.Compare can compare a and b.
+func comparable(a, b constant.Value) bool {
+	num := func(k constant.Kind) bool { return k == constant.Int || k == constant.Float }
+	return a.Kind() == b.Kind() || num(a.Kind()) && num(b.Kind())
+}
+
+// cellLess orders cells by value if possible, and by their text otherwise.
+func cellLess(a, b queryCell) bool {
+	if a.key != nil && b.key != nil && comparable(a.key, b.key) && a.key.Kind() != constant.Bool {
+		return constant.Compare(a.key, token.LSS, b.key)
+	}
+	return a.s < b.s
+}
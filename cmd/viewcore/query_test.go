@@ -0,0 +1,116 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		// want describes the parsed query: the items, from, order by
+		// and limit clauses, and the fields selected on objects.
+		want string
+		err  string
+	}{
+		{query: `select *`, want: `[addr size type] from "" order -1 limit -1 fields []`},
+		{query: `select .a, size from *main.T order by size desc limit 3`, want: `[.a size] from "main.T" order 1 desc limit 3 fields [[a]]`},
+		{query: `select .a.b where .c > 1 and not x.y`, want: `[.a.b] from "" order -1 limit -1 fields [[c] [a b]]`},
+		{query: `select .a, count(*) group by .a order by count(*)`, want: `[.a count(*)] from "" order 1 limit -1 fields [[a] [a]]`},
+		{query: `select .a order by .b`, want: `[.a .b] from "" order 1 limit -1 fields [[a] [b]]`},
+		{query: `select type, sum(size) from runtime.* group by type`, want: `[type sum(size)] from "runtime.*" order -1 limit -1 fields []`},
+		{query: `select .m[.k].v`, want: `[.m[.k].v] from "" order -1 limit -1 fields [[m] [k]]`},
+
+		{query: `from main.T`, err: `query must start with select`},
+		{query: `select .a select .b`, err: `duplicate select clause`},
+		{query: `select .a from`, err: `missing type in from clause`},
+		{query: `select .a from [`, err: `bad type pattern`},
+		{query: `select .a where (`, err: `bad where clause`},
+		{query: `select .a,`, err: `missing item`},
+		{query: `select count(*), .a`, err: `.a must be an aggregate or appear in the group by clause`},
+		{query: `select count(*) group by count(*)`, err: `can't group by aggregate`},
+		{query: `select sum(1, 2)`, err: `sum takes one argument`},
+		{query: `select .a order by .a, .b`, err: `can only order by a single item`},
+		{query: `select .a limit x`, err: `limit must be an integer`},
+	} {
+		q, err := parseQuery(&evaluator{}, test.query)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.query, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var items []string
+		for _, it := range q.items {
+			items = append(items, it.text)
+		}
+		got := fmt.Sprintf("%v from %q order %d", items, q.from, q.orderBy)
+		if q.desc {
+			got += " desc"
+		}
+		got += fmt.Sprintf(" limit %d fields %v", q.limit, q.fields)
+		if got != test.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.query, got, test.want)
+		}
+	}
+}
+
+func TestRunQuery(t *testing.T) {
+	p, c := loadTestCore(t)
+	e := newEvaluator(p, c)
+	for _, test := range []struct {
+		query string
+		want  string // rows, one per line, with cells separated by spaces
+	}{
+		{`select count(*) from runtime.g`, "12"},
+		{`select .goid from runtime.g where .goid > 0 order by .goid desc`, "3\n2\n1"},
+		{`select .goid from runtime.g where .goid > 0 order by .goid limit 2`, "1\n2"},
+		{`select max(.goid), min(size), avg(size) from runtime.g`, "3 384 384.00"},
+		{`select type, count(*) where type == "runtime.g" or type == "runtime.p" group by type order by type`, "runtime.g 12\nruntime.p 12"},
+		// Without a from clause, objects without the field are skipped.
+		{`select .goid where .goid > 1 order by .goid`, "2\n3"},
+		{`select count(*) where .sched.sp != 0 and .goid == 1`, "2"},
+	} {
+		q, err := parseQuery(e, test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		res := q.run(e)
+		if res.errs != 0 {
+			t.Errorf("%s: failed for %d objects: %v", test.query, res.errs, res.err)
+		}
+		var rows []string
+		for _, row := range res.rows {
+			var cells []string
+			for _, cell := range row {
+				cells = append(cells, cell.s)
+			}
+			rows = append(rows, strings.Join(cells, " "))
+		}
+		if got := strings.Join(rows, "\n"); got != test.want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", test.query, got, test.want)
+		}
+	}
+
+	// With a from clause, objects of the type that lack the field are
+	// errors.
+	q, err := parseQuery(e, `select .nosuchfield from runtime.g`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := q.run(e); res.errs != 12 || len(res.rows) != 12 {
+		t.Errorf("select .nosuchfield from runtime.g: got %d errors in %d rows, want 12 in 12", res.errs, len(res.rows))
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/debug/internal/core"
+	"golang.org/x/debug/internal/gocore"
+)
+
+// A valueOwner describes where a value of type t found by
+// forEachTypedValue lives: at offset off in the heap object x, or,
+// if r is non-nil, in the root r.
+type valueOwner struct {
+	x   gocore.Object
+	r   *gocore.Root
+	off int64
+	t   *gocore.Type
+}
+
+// String returns the owner as a type or variable name followed by a
+// field path, like "main.Session.name" or "main.cache[3].key".
+func (o valueOwner) String(c *gocore.Process) string {
+	if o.r != nil {
+		name := o.r.Name
+		if o.r.Frame != nil {
+			name = o.r.Frame.Func().Name() + "." + name
+		}
+		return name + o.field(c)
+	}
+	return typeName(c, o.x) + o.field(c)
+}
+
+// ownerType returns the type name of the owner, without the field path.
+func (o valueOwner) ownerType(c *gocore.Process) string {
+	if o.r != nil {
+		if o.r.Frame != nil {
+			return "stack " + o.r.Frame.Func().Name()
+		}
+		return "global " + o.r.Name
+	}
+	return typeName(c, o.x)
+}
+
+// field returns the field path of the owner, like ".name" or "[3].key".
+func (o valueOwner) field(c *gocore.Process) string {
+	var f string
+	if o.r != nil {
+		f = typeFieldName(o.r.Type, o.off)
+	} else {
+		f = fieldName(c, o.x, o.off)
+	}
+	// The field name found by offset descends into the value itself,
+	// as in .name.ptr for a string; cut that part.
+	return strings.TrimSuffix(f, typeFieldName(o.t, 0))
+}
+
+// referrerPath returns a path from a root to the value owned by o, like
+// "main.tab → main.table.entries.ptr → [16]*main.entry[3] → main.entry.name",
+// naming the root and, for each object on the way, the field pointing to
+// the next one.
+func referrerPath(c *gocore.Process, o valueOwner) string {
+	if o.r != nil {
+		return o.String(c)
+	}
+	r, i, y, depth := pathToRoot(c, o.x)
+	if r == nil {
+		return o.String(c)
+	}
+	name := r.Name
+	if r.Frame != nil {
+		name = r.Frame.Func().Name() + "." + r.Name
+	}
+	path := []string{name + typeFieldName(r.Type, i)}
+	for _, h := range pathHops(c, y, o.x, depth) {
+		if h.x != o.x {
+			path = append(path, typeName(c, h.x)+h.field)
+		}
+	}
+	path = append(path, o.String(c))
+	return strings.Join(path, " → ")
+}
+
+// pathToRoot searches backwards from obj for the root closest to it.
+// It returns the root r, the offset i in r of the pointer leading to
+// obj, the object y that pointer points to, and the distance from each
+// object searched to obj, which pathHops uses to get from y to obj.
+// It returns a nil root if no root reaches obj.
+func pathToRoot(c *gocore.Process, obj gocore.Object) (r *gocore.Root, i int64, y gocore.Object, depth map[gocore.Object]int) {
+	// Breadth-first search backwards until we reach a root.
+	depth = map[gocore.Object]int{obj: 0}
+	q := []gocore.Object{obj}
+	for len(q) > 0 && r == nil {
+		z := q[0]
+		q = q[1:]
+		c.ForEachReversePtr(z, func(x gocore.Object, root *gocore.Root, off, _ int64) bool {
+			if root != nil {
+				r, i, y = root, off, z
+				return false
+			}
+			if _, ok := depth[x]; ok {
+				// We already found a shorter path to this object.
+				return true
+			}
+			depth[x] = depth[z] + 1
+			q = append(q, x)
+			return true
+		})
+	}
+	return r, i, y, depth
+}
+
+// A pathHop is an object on a path found by pathToRoot, with the field
+// pointing to the next object and the region of the next object it
+// points to.
+type pathHop struct {
+	x         gocore.Object
+	field, to string
+}
+
+// pathHops returns the objects on the path from y to obj, following
+// objects of decreasing depth.
+func pathHops(c *gocore.Process, y, obj gocore.Object, depth map[gocore.Object]int) []pathHop {
+	var hops []pathHop
+	z := y
+	for {
+		h := pathHop{x: z}
+		if z == obj {
+			return append(hops, h)
+		}
+		// Find an edge out of z which goes to an object
+		// closer to obj.
+		next := z
+		c.ForEachPtr(z, func(i int64, w gocore.Object, j int64) bool {
+			if d, ok := depth[w]; ok && d < depth[z] {
+				h.field, h.to = objField(c, z, i), objRegion(c, w, j)
+				next = w
+				return false
+			}
+			return true
+		})
+		hops = append(hops, h)
+		z = next
+	}
+}
+
+// forEachTypedValue calls fn for each value in the typed memory of the
+// process: the heap objects whose type is known, and the global and stack
+// roots. Values are visited recursively through struct fields and array
+// elements, but pointers and interfaces are not followed. If fn returns
+// false, forEachTypedValue returns immediately.
+func forEachTypedValue(c *gocore.Process, fn func(t *gocore.Type, a core.Address, o valueOwner) bool) {
+	done := false
+	c.ForEachObject(func(x gocore.Object) bool {
+		t, repeat := c.Type(x)
+		if t == nil {
+			return true
+		}
+		base := c.Addr(x)
+		for i := int64(0); i < repeat && !done; i++ {
+			off := i * t.Size
+			done = !walkValue(t, base.Add(off), func(t *gocore.Type, a core.Address) bool {
+				return fn(t, a, valueOwner{x: x, off: a.Sub(base), t: t})
+			})
+		}
+		return !done
+	})
+	if done {
+		return
+	}
+	walkRoot := func(r *gocore.Root) bool {
+		return walkValue(r.Type, r.Addr, func(t *gocore.Type, a core.Address) bool {
+			return fn(t, a, valueOwner{r: r, off: a.Sub(r.Addr), t: t})
+		})
+	}
+	for _, r := range c.Globals() {
+		if !walkRoot(r) {
+			return
+		}
+	}
+	for _, g := range c.Goroutines() {
+		for _, f := range g.Frames() {
+			for _, r := range f.Roots() {
+				if !walkRoot(r) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// walkValue calls fn for the value of type t at a, and for each of its
+// fields and elements. It returns false if fn did.
+func walkValue(t *gocore.Type, a core.Address, fn func(t *gocore.Type, a core.Address) bool) bool {
+	if !fn(t, a) {
+		return false
+	}
+	switch t.Kind {
+	case gocore.KindStruct:
+		for _, f := range t.Fields {
+			if !walkValue(f.Type, a.Add(f.Off), fn) {
+				return false
+			}
+		}
+	case gocore.KindArray:
+		switch t.Elem.Kind {
+		case gocore.KindBool, gocore.KindInt, gocore.KindUint, gocore.KindFloat, gocore.KindComplex:
+			// Nothing interesting inside.
+			return true
+		}
+		for i := int64(0); i < t.Count; i++ {
+			if !walkValue(t.Elem, a.Add(i*t.Elem.Size), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isByteSlice reports whether t is a slice of bytes.
+func isByteSlice(t *gocore.Type) bool {
+	return t.Kind == gocore.KindSlice && t.Elem != nil && t.Elem.Kind == gocore.KindUint && t.Elem.Size == 1
+}
+
+// quoteTruncated returns b quoted as a Go string, truncated to n bytes.
+func quoteTruncated(b []byte, n int) string {
+	if len(b) <= n {
+		return fmt.Sprintf("%q", b)
+	}
+	return fmt.Sprintf("%q...", b[:n])
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An AuxvTag is the type of an entry of the auxiliary vector, the
+// information the kernel passes to a new process about itself and the
+// machine.
+type AuxvTag uint64
+
+// Auxiliary vector tags, from linux/auxvec.h.
+const (
+	AT_NULL          AuxvTag = 0  // end of the vector
+	AT_IGNORE        AuxvTag = 1  // entry to be ignored
+	AT_EXECFD        AuxvTag = 2  // file descriptor of the program
+	AT_PHDR          AuxvTag = 3  // address of the program headers of the executable
+	AT_PHENT         AuxvTag = 4  // size of a program header
+	AT_PHNUM         AuxvTag = 5  // number of program headers
+	AT_PAGESZ        AuxvTag = 6  // system page size
+	AT_BASE          AuxvTag = 7  // base address of the dynamic linker
+	AT_FLAGS         AuxvTag = 8  // flags
+	AT_ENTRY         AuxvTag = 9  // entry point of the executable
+	AT_NOTELF        AuxvTag = 10 // program is not ELF
+	AT_UID           AuxvTag = 11 // real user ID
+	AT_EUID          AuxvTag = 12 // effective user ID
+	AT_GID           AuxvTag = 13 // real group ID
+	AT_EGID          AuxvTag = 14 // effective group ID
+	AT_PLATFORM      AuxvTag = 15 // address of a string naming the platform
+	AT_HWCAP         AuxvTag = 16 // machine dependent hardware capabilities
+	AT_CLKTCK        AuxvTag = 17 // frequency of times()
+	AT_SECURE        AuxvTag = 23 // secure mode, for setuid programs and the like
+	AT_BASE_PLATFORM AuxvTag = 24 // address of a string naming the real platform
+	AT_RANDOM        AuxvTag = 25 // address of 16 random bytes
+	AT_HWCAP2        AuxvTag = 26 // more hardware capabilities
+	AT_EXECFN        AuxvTag = 31 // address of the file name of the program
+	AT_SYSINFO       AuxvTag = 32 // entry point of the vsyscall page
+	AT_SYSINFO_EHDR  AuxvTag = 33 // address of the vDSO
+	AT_MINSIGSTKSZ   AuxvTag = 51 // minimal stack size for signal delivery
+)
+
+var auxvTagNames = map[AuxvTag]string{
+	AT_NULL:          "AT_NULL",
+	AT_IGNORE:        "AT_IGNORE",
+	AT_EXECFD:        "AT_EXECFD",
+	AT_PHDR:          "AT_PHDR",
+	AT_PHENT:         "AT_PHENT",
+	AT_PHNUM:         "AT_PHNUM",
+	AT_PAGESZ:        "AT_PAGESZ",
+	AT_BASE:          "AT_BASE",
+	AT_FLAGS:         "AT_FLAGS",
+	AT_ENTRY:         "AT_ENTRY",
+	AT_NOTELF:        "AT_NOTELF",
+	AT_UID:           "AT_UID",
+	AT_EUID:          "AT_EUID",
+	AT_GID:           "AT_GID",
+	AT_EGID:          "AT_EGID",
+	AT_PLATFORM:      "AT_PLATFORM",
+	AT_HWCAP:         "AT_HWCAP",
+	AT_CLKTCK:        "AT_CLKTCK",
+	AT_SECURE:        "AT_SECURE",
+	AT_BASE_PLATFORM: "AT_BASE_PLATFORM",
+	AT_RANDOM:        "AT_RANDOM",
+	AT_HWCAP2:        "AT_HWCAP2",
+	AT_EXECFN:        "AT_EXECFN",
+	AT_SYSINFO:       "AT_SYSINFO",
+	AT_SYSINFO_EHDR:  "AT_SYSINFO_EHDR",
+	AT_MINSIGSTKSZ:   "AT_MINSIGSTKSZ",
+}
+
+func (t AuxvTag) String() string {
+	if s, ok := auxvTagNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("AT_%d", uint64(t))
+}
+
+// An AuxvEntry is an entry of the auxiliary vector.
+type AuxvEntry struct {
+	Tag AuxvTag
+	Val uint64 // a number or an address in the inferior, depending on Tag
+}
+
+// readAuxv decodes the NT_AUXV note: pairs of words, in the byte order
+// of the inferior, up to the AT_NULL entry. Entries cut off by the end
+// of the note are dropped.
+func readAuxv(meta metadata, notes noteMap) []AuxvEntry {
+	if len(notes[_NT_AUXV]) == 0 {
+		return nil
+	}
+	// We don't expect multiple NT_AUXV notes. Just use the first.
+	desc := notes[_NT_AUXV][0]
+	word := func(b []byte) uint64 {
+		if meta.ptrSize == 4 {
+			return uint64(meta.byteOrder.Uint32(b))
+		}
+		return meta.byteOrder.Uint64(b)
+	}
+	var auxv []AuxvEntry
+	for n := int(meta.ptrSize); len(desc) >= 2*n; desc = desc[2*n:] {
+		tag := AuxvTag(word(desc))
+		if tag == AT_NULL {
+			break
+		}
+		auxv = append(auxv, AuxvEntry{Tag: tag, Val: word(desc[n:])})
+	}
+	return auxv
+}
+
+// auxvValue returns the value of the first entry of auxv with the
+// given tag, and whether there is one.
+func auxvValue(auxv []AuxvEntry, tag AuxvTag) (uint64, bool) {
+	for _, e := range auxv {
+		if e.Tag == tag {
+			return e.Val, true
+		}
+	}
+	return 0, false
+}
+
+// Auxv returns the entries of the auxiliary vector of the inferior,
+// without the final AT_NULL. It is nil if the core has no NT_AUXV note.
+func (p *Process) Auxv() []AuxvEntry {
+	return p.auxv
+}
+
+// AuxvValue returns the value of the auxiliary vector entry with the
+// given tag, and whether there is one.
+func (p *Process) AuxvValue(tag AuxvTag) (uint64, bool) {
+	return auxvValue(p.auxv, tag)
+}
+
+// AuxvString returns the string that the auxiliary vector entry with
+// the given tag, such as AT_PLATFORM or AT_EXECFN, points to. It
+// returns "" if there is no such entry or the string isn't in the core.
+func (p *Process) AuxvString(tag AuxvTag) string {
+	v, ok := p.AuxvValue(tag)
+	if !ok || v == 0 {
+		return ""
+	}
+	a := Address(v)
+	for n := int64(0); ; n++ {
+		if !p.Readable(a.Add(n)) {
+			return ""
+		}
+		if p.ReadUint8(a.Add(n)) == 0 {
+			return p.ReadCString(a)
+		}
+	}
+}
+
+// HWCAP returns the names of the hardware capabilities in the
+// AT_HWCAP and AT_HWCAP2 entries of the auxiliary vector, as listed in
+// /proc/cpuinfo. Capabilities with no known name are named "hwcap:N"
+// or "hwcap2:N", for bit N. It returns nil for architectures whose
+// capabilities have no known names.
+func (p *Process) HWCAP() []string {
+	names := hwcapNames[p.meta.arch]
+	if names[0] == nil && names[1] == nil {
+		return nil
+	}
+	var caps []string
+	for i, tag := range []AuxvTag{AT_HWCAP, AT_HWCAP2} {
+		v, _ := p.AuxvValue(tag)
+		for bit := 0; bit < 64; bit++ {
+			if v&(1<<bit) == 0 {
+				continue
+			}
+			if bit < len(names[i]) && names[i][bit] != "" {
+				caps = append(caps, names[i][bit])
+			} else {
+				caps = append(caps, fmt.Sprintf("%s:%d", strings.ToLower(tag.String()[3:]), bit))
+			}
+		}
+	}
+	return caps
+}
+
+// x86HWCAP names the bits of AT_HWCAP on x86, which holds the EDX
+// register returned by CPUID leaf 1.
+var x86HWCAP = []string{
+	"fpu", "vme", "de", "pse", "tsc", "msr", "pae", "mce",
+	"cx8", "apic", "", "sep", "mtrr", "pge", "mca", "cmov",
+	"pat", "pse36", "pn", "clflush", "", "dts", "acpi", "mmx",
+	"fxsr", "sse", "sse2", "ss", "ht", "tm", "ia64", "pbe",
+}
+
+// hwcapNames holds, for each architecture, the names of the bits of
+// AT_HWCAP and AT_HWCAP2, from the kernel's asm/hwcap.h.
+var hwcapNames = map[string][2][]string{
+	"386":   {x86HWCAP, {"ring3mwait", "fsgsbase"}},
+	"amd64": {x86HWCAP, {"ring3mwait", "fsgsbase"}},
+	"arm": {{
+		"swp", "half", "thumb", "26bit", "fastmult", "fpa", "vfp", "edsp",
+		"java", "iwmmxt", "crunch", "thumbee", "neon", "vfpv3", "vfpv3d16", "tls",
+		"vfpv4", "idiva", "idivt", "vfpd32", "lpae", "evtstrm",
+	}, {
+		"aes", "pmull", "sha1", "sha2", "crc32",
+	}},
+	"arm64": {{
+		"fp", "asimd", "evtstrm", "aes", "pmull", "sha1", "sha2", "crc32",
+		"atomics", "fphp", "asimdhp", "cpuid", "asimdrdm", "jscvt", "fcma", "lrcpc",
+		"dcpop", "sha3", "sm3", "sm4", "asimddp", "sha512", "sve", "asimdfhm",
+		"dit", "uscat", "ilrcpc", "flagm", "ssbs", "sb", "paca", "pacg",
+	}, {
+		"dcpodp", "sve2", "sveaes", "svepmull", "svebitperm", "svesha3", "svesm4", "flagm2",
+		"frint", "svei8mm", "svef32mm", "svef64mm", "svebf16", "i8mm", "bf16", "dgh",
+		"rng", "bti", "mte",
+	}},
+}
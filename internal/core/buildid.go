@@ -0,0 +1,214 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Note types of build ID notes.
+const (
+	_NT_GNU_BUILD_ID = 3 // in notes named "GNU"
+	_NT_GO_BUILD_ID  = 4 // in notes named "Go"
+)
+
+// A BuildID holds the build IDs found in the notes of an executable.
+// Either may be empty: the Go linker writes only the Go build ID,
+// unless linking externally, and C toolchains write only the GNU one.
+type BuildID struct {
+	Go  string // from the Go build ID note, in .note.go.buildid
+	GNU string // from the NT_GNU_BUILD_ID note, in hex
+}
+
+// IsZero reports whether b holds no build ID.
+func (b BuildID) IsZero() bool {
+	return b.Go == "" && b.GNU == ""
+}
+
+func (b BuildID) String() string {
+	switch {
+	case b.Go != "" && b.GNU != "":
+		return fmt.Sprintf("go %s, gnu %s", b.Go, b.GNU)
+	case b.Go != "":
+		return "go " + b.Go
+	case b.GNU != "":
+		return "gnu " + b.GNU
+	}
+	return "none"
+}
+
+// mismatch returns the kind of build ID ("go" or "gnu") that is in
+// both b and c but differs, or "" if there is none.
+func (b BuildID) mismatch(c BuildID) string {
+	if b.Go != "" && c.Go != "" && b.Go != c.Go {
+		return "go"
+	}
+	if b.GNU != "" && c.GNU != "" && b.GNU != c.GNU {
+		return "gnu"
+	}
+	return ""
+}
+
+// A BuildIDError is returned by Core when the build ID of the
+// executable differs from the one found in the memory of the core,
+// which means the executable is not the one that dumped core.
+type BuildIDError struct {
+	Exe    string  // path of the executable or shared library
+	ExeID  BuildID // build ID of the executable
+	CoreID BuildID // build ID found in the core
+}
+
+func (e *BuildIDError) Error() string {
+	return fmt.Sprintf("%s doesn't match the core: its build ID is %s, but the core's is %s", e.Exe, e.ExeID, e.CoreID)
+}
+
+// exeBuildID returns the build IDs in the notes of the executable f.
+func exeBuildID(f *elf.File) BuildID {
+	var id BuildID
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		b, err := io.ReadAll(prog.Open())
+		if err != nil {
+			continue
+		}
+		parseBuildIDNotes(b, f.ByteOrder, &id)
+	}
+	return id
+}
+
+// coreBuildID returns the build IDs in the notes of the executable
+// whose ELF header is mapped at base in the inferior, as found in the
+// core file. It reads only data dumped in the core file: the
+// executable itself might be the wrong one. It returns the zero
+// BuildID if the core doesn't have the executable's headers.
+func coreBuildID(meta metadata, coreFile *os.File, coreElf *elf.File, base Address) BuildID {
+	var id BuildID
+	if base == 0 {
+		return id
+	}
+	// Find the data dumped for the page at base. Linux dumps the
+	// first page of mapped ELF files, to make build IDs available.
+	// The segment holding it may be much larger, so all reads below
+	// are bounded.
+	var r *io.SectionReader
+	for _, prog := range coreElf.Progs {
+		min := Address(prog.Vaddr)
+		if prog.Type != elf.PT_LOAD || base < min || base >= min.Add(int64(prog.Filesz)) {
+			continue
+		}
+		r = io.NewSectionReader(coreFile, int64(prog.Off)+base.Sub(min), int64(prog.Filesz)-base.Sub(min))
+		break
+	}
+	if r == nil {
+		return id
+	}
+	page := make([]byte, pageSize)
+	n, _ := r.ReadAt(page, 0)
+	page = page[:n]
+	if len(page) < elf.EI_NIDENT || string(page[:4]) != elf.ELFMAG {
+		return id
+	}
+
+	// Find the notes using the program headers.
+	hr := bytes.NewReader(page)
+	var phoff, phentsize, phnum int64
+	switch meta.ptrSize {
+	case 4:
+		var h elf.Header32
+		if binary.Read(hr, meta.byteOrder, &h) != nil {
+			return id
+		}
+		phoff, phentsize, phnum = int64(h.Phoff), int64(h.Phentsize), int64(h.Phnum)
+	case 8:
+		var h elf.Header64
+		if binary.Read(hr, meta.byteOrder, &h) != nil {
+			return id
+		}
+		phoff, phentsize, phnum = int64(h.Phoff), int64(h.Phentsize), int64(h.Phnum)
+	}
+	for i := int64(0); i < phnum; i++ {
+		var typ elf.ProgType
+		var off, size int64
+		pr := io.NewSectionReader(r, phoff+i*phentsize, phentsize)
+		switch meta.ptrSize {
+		case 4:
+			var ph elf.Prog32
+			if binary.Read(pr, meta.byteOrder, &ph) != nil {
+				return id
+			}
+			typ, off, size = elf.ProgType(ph.Type), int64(ph.Off), int64(ph.Filesz)
+		case 8:
+			var ph elf.Prog64
+			if binary.Read(pr, meta.byteOrder, &ph) != nil {
+				return id
+			}
+			typ, off, size = elf.ProgType(ph.Type), int64(ph.Off), int64(ph.Filesz)
+		}
+		if typ != elf.PT_NOTE || off < 0 || size < 0 || size > maxBuildIDNotes {
+			continue
+		}
+		b := make([]byte, size)
+		if _, err := r.ReadAt(b, off); err != nil {
+			continue
+		}
+		parseBuildIDNotes(b, meta.byteOrder, &id)
+	}
+	return id
+}
+
+// maxBuildIDNotes is the largest note segment coreBuildID reads.
+// Build ID notes are a few dozen bytes; a larger size is corrupt.
+const maxBuildIDNotes = 1 << 16
+
+// parseBuildIDNotes sets the fields of id from the build ID notes in b.
+func parseBuildIDNotes(b []byte, order binary.ByteOrder, id *BuildID) {
+	for len(b) >= 12 {
+		namesz := int(order.Uint32(b))
+		descsz := int(order.Uint32(b[4:]))
+		typ := order.Uint32(b[8:])
+		b = b[12:]
+		nameLen := (namesz + 3) &^ 3
+		descLen := (descsz + 3) &^ 3
+		if namesz < 0 || descsz < 0 || nameLen+descLen > len(b) {
+			return
+		}
+		name := string(bytes.TrimRight(b[:namesz], "\x00"))
+		desc := b[nameLen : nameLen+descsz]
+		b = b[nameLen+descLen:]
+
+		switch {
+		case name == "Go" && typ == _NT_GO_BUILD_ID:
+			id.Go = string(desc)
+		case name == "GNU" && typ == _NT_GNU_BUILD_ID:
+			id.GNU = hex.EncodeToString(desc)
+		}
+	}
+}
+
+// exeBase returns the address at which the executable named exePath
+// in the core is mapped, that is, the address of its ELF header.
+// bias is the load bias of the executable.
+func exeBase(fileMappings []namedMapping, exePath string, exeElf *elf.File, bias int64) Address {
+	for _, m := range fileMappings {
+		if m.f == exePath && m.off == 0 {
+			return m.min
+		}
+	}
+	// No NT_FILE note. Use the executable's own program headers.
+	for _, prog := range exeElf.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Off == 0 {
+			return Address(prog.Vaddr).Add(bias)
+		}
+	}
+	return 0
+}
@@ -7,7 +7,23 @@
 package core
 
 import (
+	"archive/zip"
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -122,6 +138,258 @@ func TestThread(t *testing.T) {
 	}
 }
 
+func TestRegisters(t *testing.T) {
+	p := loadExample(t, true)
+	for _, thr := range p.Threads() {
+		r := thr.Registers()
+		if r == nil {
+			t.Fatalf("thread %d has no registers", thr.Pid())
+		}
+		if rip, _ := thr.Reg("rip"); Address(rip) != thr.PC() || r.PC() != thr.PC() {
+			t.Errorf("thread %d: rip = %x, PC() = %x, want %x", thr.Pid(), rip, r.PC(), thr.PC())
+		}
+		if rsp, _ := r.DWARF(7); Address(rsp) != thr.SP() || r.SP() != thr.SP() {
+			t.Errorf("thread %d: DWARF register 7 = %x, SP() = %x, want %x", thr.Pid(), rsp, r.SP(), thr.SP())
+		}
+		if rbp, _ := thr.Reg("rbp"); Address(rbp) != r.FP() {
+			t.Errorf("thread %d: rbp = %x, FP() = %x", thr.Pid(), rbp, r.FP())
+		}
+		if _, ok := thr.Reg("x0"); ok {
+			t.Errorf("thread %d has arm64 register x0", thr.Pid())
+		}
+	}
+}
+
+func TestProcessInfo(t *testing.T) {
+	p := loadExample(t, true)
+	info := p.Info()
+	if info == nil {
+		t.Fatal("no process info")
+	}
+	if info.Fname != "test" || info.Args != "./test" {
+		t.Errorf("Fname, Args = %q, %q, want test, ./test", info.Fname, info.Args)
+	}
+	if uint64(info.PID) != p.Threads()[0].Pid() || info.PPID == 0 {
+		t.Errorf("PID = %d, PPID = %d, want %d and a parent", info.PID, info.PPID, p.Threads()[0].Pid())
+	}
+	// The runtime crashed the program with SIGABRT.
+	if sig := p.Threads()[0].Signal(); sig != 6 {
+		t.Errorf("Signal() = %d, want 6", sig)
+	}
+
+	// The sizes of the notes written by the kernel.
+	for _, x := range []struct {
+		v    interface{}
+		size int
+	}{
+		{linuxPrPsInfo{}, 0x88},
+		{linuxPrPsInfo32{}, 0x7c},
+		{linuxPrPsInfoMIPS{}, 0x80},
+	} {
+		if got := binary.Size(x.v); got != x.size {
+			t.Errorf("size of %T = %#x, want %#x", x.v, got, x.size)
+		}
+	}
+}
+
+func TestSignal(t *testing.T) {
+	p := loadExample(t, true)
+	s := p.Signal()
+	if s == nil {
+		t.Fatal("no signal")
+	}
+	// The runtime crashed the program with SIGABRT, sent with tgkill
+	// to the thread that handled the nil dereference.
+	if got, want := s.String(), fmt.Sprintf("SIGABRT (SI_TKILL) sent by pid %d, uid %d", p.Info().PID, p.Info().UID); got != want {
+		t.Errorf("Signal() = %q, want %q", got, want)
+	}
+	if s.Thread != p.Threads()[0] {
+		t.Errorf("signal taken by thread %d, want %d", s.Thread.Pid(), p.Threads()[0].Pid())
+	}
+
+	// A nil dereference on mips, where si_code and si_errno are swapped.
+	b := make([]byte, 128)
+	binary.BigEndian.PutUint32(b, 11)
+	binary.BigEndian.PutUint32(b[4:], 1)
+	binary.BigEndian.PutUint32(b[12:], 8)
+	thr := &Thread{}
+	meta := metadata{arch: "mips", ptrSize: 4, byteOrder: binary.BigEndian}
+	s = readSignal(meta, []noteMap{{}, {_NT_SIGINFO: {b}}}, []*Thread{{}, thr})
+	if s == nil || s.String() != "SIGSEGV (SEGV_MAPERR) at address 0x8" || s.Thread != thr {
+		t.Errorf("readSignal(mips) = %v, want SIGSEGV (SEGV_MAPERR) at address 0x8 in the second thread", s)
+	}
+
+	// Only signals sent by a process have a sender. The union of those
+	// sent by a timer holds the timer ID and overrun count instead.
+	for _, test := range []struct {
+		arch string
+		code int32
+		want string
+	}{
+		{"amd64", _SI_USER, "SIGALRM (SI_USER) sent by pid 7, uid 8"},
+		{"amd64", _SI_QUEUE, "SIGALRM (SI_QUEUE) sent by pid 7, uid 8"},
+		{"amd64", _SI_TKILL, "SIGALRM (SI_TKILL) sent by pid 7, uid 8"},
+		{"amd64", _SI_MESGQ, "SIGALRM (SI_MESGQ) sent by pid 7, uid 8"},
+		{"amd64", _SI_TIMER, "SIGALRM (SI_TIMER)"},
+		{"amd64", _SI_SIGIO, "SIGALRM (SI_SIGIO)"},
+		{"amd64", _SI_ASYNCIO, "SIGALRM (SI_ASYNCIO)"},
+		{"mipsle", -2, "SIGALRM (SI_ASYNCIO)"},
+		{"mipsle", -3, "SIGALRM (SI_TIMER)"},
+		{"mipsle", -4, "SIGALRM (SI_MESGQ) sent by pid 7, uid 8"},
+		{"mipsle", _SI_TKILL, "SIGALRM (SI_TKILL) sent by pid 7, uid 8"},
+	} {
+		meta := metadata{arch: test.arch, ptrSize: 8, byteOrder: binary.LittleEndian}
+		code, union := 8, 16
+		if test.arch == "mipsle" {
+			meta.ptrSize = 4
+			code, union = 4, 12
+		}
+		b := make([]byte, 128)
+		binary.LittleEndian.PutUint32(b, 14)
+		binary.LittleEndian.PutUint32(b[code:], uint32(test.code))
+		binary.LittleEndian.PutUint32(b[union:], 7)
+		binary.LittleEndian.PutUint32(b[union+4:], 8)
+		s := readSignal(meta, []noteMap{{_NT_SIGINFO: {b}}}, []*Thread{{}})
+		if got := s.String(); got != test.want {
+			t.Errorf("%s code %d: got %q, want %q", test.arch, test.code, got, test.want)
+		}
+	}
+}
+
+// TestReadFileMappings checks the decoding of NT_FILE notes of 32-bit cores.
+func TestReadFileMappings(t *testing.T) {
+	meta := metadata{arch: "arm", ptrSize: 4, byteOrder: binary.BigEndian}
+	var b []byte
+	for _, w := range []uint32{
+		2, 0x1000, // count, page size
+		0x10000, 0x11000, 0,
+		0x11000, 0x13000, 1,
+	} {
+		b = append(b, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], w)
+	}
+	b = append(b, "/bin/a\x00/bin/a\x00"...)
+	got := readFileMappings(meta, noteMap{_NT_FILE: {b}})
+	want := []namedMapping{
+		{min: 0x10000, max: 0x11000, f: "/bin/a", off: 0},
+		{min: 0x11000, max: 0x13000, f: "/bin/a", off: 0x1000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readFileMappings = %+v, want %+v", got, want)
+	}
+
+	// A count larger than the note must not panic.
+	if got := readFileMappings(meta, noteMap{_NT_FILE: {b[:20]}}); got != nil {
+		t.Errorf("readFileMappings of truncated note = %+v, want nil", got)
+	}
+}
+
+// TestReadThreads checks the decoding of NT_PRSTATUS notes on each
+// architecture, which have the sizes the kernel writes.
+func TestReadThreads(t *testing.T) {
+	for _, test := range []struct {
+		arch    string
+		ptrSize int64
+		size    int // of the note
+	}{
+		{"386", 4, 0x90},
+		{"arm", 4, 0x94},
+		{"mipsle", 4, 0x100},
+		{"amd64", 8, 0x150},
+		{"arm64", 8, 0x188},
+		{"ppc64le", 8, 0x1f8},
+		{"s390x", 8, 0x150},
+	} {
+		meta := metadata{arch: test.arch, ptrSize: test.ptrSize, byteOrder: binary.LittleEndian}
+		if test.arch == "s390x" {
+			meta.byteOrder = binary.BigEndian
+		}
+		layout := regLayouts[test.arch]
+
+		// Set pr_pid and each register to its index plus 1.
+		desc := make([]byte, test.size)
+		pid, off := 24, 72 // offsets of pr_pid and pr_reg
+		if test.ptrSize == 8 {
+			pid, off = 32, 112
+		}
+		meta.byteOrder.PutUint32(desc[pid:], 1234)
+		for i := range layout.names {
+			size := int(test.ptrSize)
+			if layout.sizes != nil {
+				size = layout.sizes[i]
+			}
+			if size == 4 {
+				meta.byteOrder.PutUint32(desc[off:], uint32(i+1))
+			} else {
+				meta.byteOrder.PutUint64(desc[off:], uint64(i+1))
+			}
+			off += size
+		}
+		// pr_fpvalid follows, and the padding to the alignment of long.
+		if want := (off + 4 + int(test.ptrSize) - 1) &^ (int(test.ptrSize) - 1); want != test.size {
+			t.Errorf("%s: registers end at %d, so the note would have %d bytes, want %d", test.arch, off, want, test.size)
+		}
+
+		threads, _ := readThreads(meta, []noteMap{{elf.NT_PRSTATUS: {desc}}})
+		thr := threads[0]
+		if thr.Pid() != 1234 {
+			t.Errorf("%s: Pid() = %d, want 1234", test.arch, thr.Pid())
+		}
+		index := func(name string) Address {
+			for i, n := range layout.names {
+				if n == name {
+					return Address(i + 1)
+				}
+			}
+			return 0
+		}
+		if thr.PC() != index(layout.pc) || thr.SP() != index(layout.sp) {
+			t.Errorf("%s: PC, SP = %d, %d, want %d, %d", test.arch, thr.PC(), thr.SP(), index(layout.pc), index(layout.sp))
+		}
+
+		// A thread whose note is cut short is dropped, and the signal
+		// is still taken by the thread whose notes hold NT_SIGINFO.
+		siginfo := make([]byte, 128)
+		meta.byteOrder.PutUint32(siginfo, 11)
+		notes := []noteMap{{elf.NT_PRSTATUS: {desc[:pid]}}, {elf.NT_PRSTATUS: {desc}, _NT_SIGINFO: {siginfo}}}
+		threads, byNote := readThreads(meta, notes)
+		if len(threads) != 1 || threads[0].Pid() != 1234 {
+			t.Errorf("%s: got %d threads from a short note and a full one, want just the full one", test.arch, len(threads))
+		}
+		if s := readSignal(meta, notes, byNote); s == nil || s.Thread != threads[0] {
+			t.Errorf("%s: signal %v not taken by the thread with NT_SIGINFO", test.arch, s)
+		}
+	}
+}
+
+// TestFPRegs checks the floating point registers of the threads.
+func TestFPRegs(t *testing.T) {
+	p := loadExample(t, true)
+	for _, thr := range p.Threads() {
+		r := thr.FPRegs()
+		if r == nil {
+			t.Fatalf("thread %d has no floating point registers", thr.Pid())
+		}
+		// The machine has AVX, so the vector registers are YMM registers.
+		if len(r.Vector) != 16 || len(r.Vector[0]) != 32 || len(r.X87) != 8 {
+			t.Errorf("thread %d has %d vector registers of %d bytes and %d x87 registers, want 16 of 32 and 8",
+				thr.Pid(), len(r.Vector), len(r.Vector[0]), len(r.X87))
+		}
+		if r.FCW != 0x37f || r.MXCSR != 0x1fa0 {
+			t.Errorf("thread %d: FCW = %#x, MXCSR = %#x, want 0x37f and 0x1fa0", thr.Pid(), r.FCW, r.MXCSR)
+		}
+	}
+
+	// arm64 has no XSAVE area, just the vector registers.
+	b := make([]byte, 32*16+8)
+	binary.LittleEndian.PutUint64(b[16:], math.Float64bits(1.5))
+	binary.LittleEndian.PutUint32(b[516:], 0x3000000)
+	r := readFPRegs(metadata{arch: "arm64"}, noteMap{elf.NT_FPREGSET: {b}})
+	if r == nil || len(r.Vector) != 32 || r.Float64(1) != 1.5 || r.FPCR != 0x3000000 {
+		t.Errorf("readFPRegs(arm64) = %+v, want V1 = 1.5 and FPCR = 0x3000000", r)
+	}
+}
+
 func TestArgs(t *testing.T) {
 	p := loadExample(t, true)
 	if got := p.Args(); got != "./test" {
@@ -129,3 +397,681 @@ func TestArgs(t *testing.T) {
 		t.Errorf("Args() = %q, want './test'", got)
 	}
 }
+
+func TestAuxv(t *testing.T) {
+	p := loadExample(t, true)
+	if len(p.Auxv()) == 0 {
+		t.Fatal("no auxiliary vector")
+	}
+	if v, _ := p.AuxvValue(AT_PAGESZ); v != 4096 {
+		t.Errorf("AT_PAGESZ = %d, want 4096", v)
+	}
+	if v, _ := p.AuxvValue(AT_ENTRY); Address(v) != p.entryPoint || v == 0 {
+		t.Errorf("AT_ENTRY = %x, want entry point %x", v, p.entryPoint)
+	}
+	if got := p.AuxvString(AT_PLATFORM); got != "x86_64" {
+		t.Errorf("AT_PLATFORM = %q, want x86_64", got)
+	}
+	if got := p.HWCAP(); len(got) == 0 || got[0] != "fpu" {
+		t.Errorf("HWCAP() = %q, want fpu first", got)
+	}
+}
+
+// TestReadAuxv checks the decoding of 32-bit and big-endian auxiliary vectors.
+func TestReadAuxv(t *testing.T) {
+	for _, test := range []struct {
+		meta metadata
+		desc []byte
+	}{
+		{
+			metadata{ptrSize: 4, byteOrder: binary.BigEndian},
+			[]byte{0, 0, 0, 6, 0, 0, 0x10, 0, 0, 0, 0, 9, 0x10, 0, 0, 0x40, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			metadata{ptrSize: 4, byteOrder: binary.LittleEndian},
+			// Truncated: no AT_NULL and half an entry at the end.
+			[]byte{6, 0, 0, 0, 0, 0x10, 0, 0, 9, 0, 0, 0, 0x40, 0, 0, 0x10, 1, 0, 0},
+		},
+		{
+			metadata{ptrSize: 8, byteOrder: binary.BigEndian},
+			[]byte{
+				0, 0, 0, 0, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0, 0x10, 0,
+				0, 0, 0, 0, 0, 0, 0, 9, 0, 0, 0, 0, 0x10, 0, 0, 0x40,
+			},
+		},
+	} {
+		got := readAuxv(test.meta, noteMap{_NT_AUXV: {test.desc}})
+		want := []AuxvEntry{{AT_PAGESZ, 0x1000}, {AT_ENTRY, 0x10000040}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("readAuxv(%d-bit %s) = %v, want %v", 8*test.meta.ptrSize, test.meta.byteOrder, got, want)
+		}
+	}
+}
+
+func TestReadError(t *testing.T) {
+	p := loadExample(t, true)
+	defer func() {
+		r := recover()
+		e, ok := r.(*ReadError)
+		if !ok {
+			t.Fatalf("ReadUint64(0) panicked with %v, want a *ReadError", r)
+		}
+		if e.Addr != 0 {
+			t.Errorf("ReadError.Addr = %x, want 0", e.Addr)
+		}
+	}()
+	p.ReadUint64(0)
+	t.Errorf("ReadUint64(0) didn't panic")
+}
+
+// TestTruncated makes sure we can load a core file that was cut off in
+// the middle of a mapping, and that the missing data isn't readable.
+func TestTruncated(t *testing.T) {
+	b, err := os.ReadFile("testdata/core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut the file in the middle of the page at 0x7fffe9aba000,
+	// in the mapping at 0x7fffe9aaa000, which starts at 0x1cf000 in
+	// the file. The mappings after it are lost entirely.
+	path := filepath.Join(t.TempDir(), "core")
+	if err := os.WriteFile(path, b[:0x1cf000+0x10800], 0666); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Core(path, "", "testdata/tmp/test")
+	if err != nil {
+		t.Fatalf("can't load truncated core file: %v", err)
+	}
+
+	var truncated bool
+	for _, w := range p.Warnings() {
+		if strings.Contains(w, "truncated") {
+			truncated = true
+		}
+	}
+	if !truncated {
+		t.Errorf("warnings %q don't mention truncation", p.Warnings())
+	}
+
+	var missing []*Mapping
+	for _, m := range p.Mappings() {
+		if m.Missing() {
+			missing = append(missing, m)
+		}
+	}
+	if len(missing) == 0 || missing[0].Min() != 0x7fffe9aba000 {
+		t.Fatalf("got missing mappings %v, want one starting at 0x7fffe9aba000", missing)
+	}
+	if !p.Readable(0x7fffe9ab9ff8) {
+		t.Errorf("address 7fffe9ab9ff8, before the end of the file, is not readable")
+	}
+	if p.Readable(0x7fffe9aba000) {
+		t.Errorf("address 7fffe9aba000, after the end of the file, is readable")
+	}
+}
+
+func TestBuildID(t *testing.T) {
+	const id = "75a0a5715372acf6d28da9b94b720b8835f16ef6"
+	p := loadExample(t, true)
+	if got := p.BuildID().Go; got != id {
+		t.Errorf("BuildID().Go = %q, want %q", got, id)
+	}
+	if got := p.CoreBuildID().Go; got != id {
+		t.Errorf("CoreBuildID().Go = %q, want %q", got, id)
+	}
+
+	// Make an executable with a different build ID.
+	b, err := os.ReadFile("testdata/tmp/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = bytes.Replace(b, []byte(id), bytes.Repeat([]byte("x"), len(id)), -1)
+	exe := filepath.Join(t.TempDir(), "test")
+	if err := os.WriteFile(exe, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Core("testdata/core", "", exe)
+	var idErr *BuildIDError
+	if !errors.As(err, &idErr) {
+		t.Fatalf("loading core with the wrong executable: got error %v, want a *BuildIDError", err)
+	}
+	if idErr.CoreID.Go != id {
+		t.Errorf("BuildIDError.CoreID.Go = %q, want %q", idErr.CoreID.Go, id)
+	}
+	p, err = CoreWithOptions("testdata/core", "", exe, Options{IgnoreBuildID: true})
+	if err != nil {
+		t.Fatalf("loading core with IgnoreBuildID: %v", err)
+	}
+	if len(p.Warnings()) == 0 || !strings.Contains(p.Warnings()[0], "build ID") {
+		t.Errorf("warnings %q don't mention the build ID", p.Warnings())
+	}
+}
+
+// TestFindByBuildID makes sure the executable is found by the build ID
+// recorded in the core in a .build-id directory, when it can't be found
+// by name.
+func TestFindByBuildID(t *testing.T) {
+	t.Run("SearchPath", func(t *testing.T) {
+		// The cgo executable is linked externally, so it has a GNU
+		// build ID. It was run as /tmp/cgocore/test, which isn't in
+		// dir.
+		dir := unzip(t, "cgo.zip")
+		core := filepath.Join(dir, "core")
+		const key = "8d61ef584690a1b4a843eaec1ee703ea9bae4334"
+		searchPath := func(file string) []string {
+			b, err := os.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sp := t.TempDir()
+			p := filepath.Join(sp, ".build-id", key[:2], key[2:])
+			if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, b, 0666); err != nil {
+				t.Fatal(err)
+			}
+			return []string{sp}
+		}
+		if _, err := Core(core, dir, ""); err == nil {
+			t.Fatalf("loaded core without the executable")
+		}
+		if _, err := CoreWithOptions(core, dir, "", Options{SearchPath: searchPath("test")}); err != nil {
+			t.Errorf("can't load core with executable in search path: %v", err)
+		}
+		// A file with another build ID isn't taken for the executable.
+		ff := &fileFinder{
+			base: dir,
+			opts: Options{SearchPath: searchPath("usr/lib/x86_64-linux-gnu/libc.so.6")},
+			ids:  map[string]BuildID{"/tmp/cgocore/test": {GNU: key}},
+		}
+		if f, err := ff.open("/tmp/cgocore/test"); err == nil {
+			t.Errorf("found %s for the executable, which has another build ID", f.Name())
+			f.Close()
+		}
+	})
+
+	// The executable of testdata/core only has a Go build ID, so it
+	// can't be found by build ID.
+	exe, err := os.ReadFile("testdata/tmp/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noBase := t.TempDir()
+
+	t.Run("GoBuildID", func(t *testing.T) {
+		dir := t.TempDir()
+		key := hex.EncodeToString([]byte("75a0a5715372acf6d28da9b94b720b8835f16ef6"))
+		p := filepath.Join(dir, ".build-id", key[:2], key[2:])
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, exe, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := CoreWithOptions("testdata/core", noBase, "", Options{SearchPath: []string{dir}}); err == nil {
+			t.Errorf("loaded core with an executable found by its Go build ID")
+		}
+	})
+
+	t.Run("Debuginfod", func(t *testing.T) {
+		// Debuginfod servers only know files by their GNU build ID,
+		// so they aren't asked for this executable.
+		var requests []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.Path)
+			http.NotFound(w, r)
+		}))
+		defer srv.Close()
+		opts := Options{DebuginfodURLs: []string{srv.URL}, CacheDir: t.TempDir()}
+		if _, err := CoreWithOptions("testdata/core", noBase, "", opts); err == nil {
+			t.Errorf("loaded core without the executable")
+		}
+		if len(requests) != 0 {
+			t.Errorf("got requests %q for an executable without a GNU build ID", requests)
+		}
+	})
+}
+
+// TestDebuginfod makes sure an executable with a GNU build ID is
+// downloaded from a debuginfod server if it isn't found locally.
+func TestDebuginfod(t *testing.T) {
+	// The cgo executable is linked externally, so it has a GNU build ID.
+	dir := unzip(t, "cgo.zip")
+	exe, err := os.ReadFile(filepath.Join(dir, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const key = "8d61ef584690a1b4a843eaec1ee703ea9bae4334"
+	var requests []string
+	served := exe
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path != "/buildid/"+key+"/executable" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(served)
+	}))
+	defer srv.Close()
+	opts := Options{DebuginfodURLs: []string{srv.URL}, CacheDir: t.TempDir()}
+
+	// The executable found by name comes first. It was run as
+	// /tmp/cgocore/test.
+	exePath := filepath.Join(dir, "tmp", "cgocore", "test")
+	if err := os.MkdirAll(filepath.Dir(exePath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "test"), exePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CoreWithOptions(filepath.Join(dir, "core"), dir, "", opts); err != nil {
+		t.Fatalf("can't load core: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("got requests %q, want none with the executable at hand", requests)
+	}
+
+	if err := os.Remove(exePath); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := CoreWithOptions(filepath.Join(dir, "core"), dir, "", opts); err != nil {
+			t.Fatalf("can't load core with executable from debuginfod: %v", err)
+		}
+	}
+	// The second load uses the cached file.
+	if len(requests) != 1 {
+		t.Errorf("got requests %q, want one", requests)
+	}
+
+	// A file with another build ID isn't taken for the executable.
+	served, err = os.ReadFile(filepath.Join(dir, "usr/lib/x86_64-linux-gnu/libc.so.6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.CacheDir = t.TempDir()
+	ff := &fileFinder{base: dir, opts: opts, ids: map[string]BuildID{"/tmp/cgocore/test": {GNU: key}}}
+	if f, err := ff.open("/tmp/cgocore/test"); err == nil {
+		t.Errorf("got %s from debuginfod for the executable, which has another build ID", f.Name())
+		f.Close()
+	}
+}
+
+// TestDebugFile makes sure DWARF and symbols are read from a separate
+// debug file for a stripped executable.
+func TestDebugFile(t *testing.T) {
+	objcopy, err := exec.LookPath("objcopy")
+	if err != nil {
+		t.Skip("objcopy not found")
+	}
+	dir := t.TempDir()
+	debug := filepath.Join(dir, "test.debug")
+	stripped := filepath.Join(dir, "test")
+	for _, args := range [][]string{
+		{"--only-keep-debug", "testdata/tmp/test", debug},
+		{"--strip-all", "--add-gnu-debuglink=" + debug, "testdata/tmp/test", stripped},
+	} {
+		if out, err := exec.Command(objcopy, args...).CombinedOutput(); err != nil {
+			t.Fatalf("objcopy %v: %v\n%s", args, err, out)
+		}
+	}
+
+	check := func(t *testing.T, p *Process) {
+		t.Helper()
+		if _, err := p.DWARF(); err != nil {
+			t.Errorf("can't read DWARF: %v", err)
+		}
+		syms, err := p.Symbols()
+		if err != nil {
+			t.Errorf("can't read symbols: %v", err)
+		}
+		if syms["main.main"] == 0 {
+			t.Errorf("symbol main.main missing")
+		}
+		if p.DebugFile() != debug {
+			t.Errorf("DebugFile() = %q, want %q", p.DebugFile(), debug)
+		}
+	}
+	t.Run("DebugLink", func(t *testing.T) {
+		p, err := Core("testdata/core", "", stripped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, p)
+	})
+	t.Run("DebugFile", func(t *testing.T) {
+		// Without the debug link, the debug file must be given.
+		if out, err := exec.Command(objcopy, "--remove-section=.gnu_debuglink", stripped).CombinedOutput(); err != nil {
+			t.Fatalf("objcopy: %v\n%s", err, out)
+		}
+		p, err := Core("testdata/core", "", stripped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.DWARF(); err == nil {
+			t.Errorf("read DWARF from stripped executable")
+		}
+		p, err = CoreWithOptions("testdata/core", "", stripped, Options{DebugFile: debug})
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, p)
+	})
+}
+
+// unzip extracts the zip file testdata/name into a temporary directory,
+// and returns the directory.
+func unzip(t *testing.T, name string) string {
+	dir := t.TempDir()
+	r, err := zip.OpenReader(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		rf, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rf)
+		rf.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, f.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			err = os.Symlink(string(b), path)
+		} else {
+			err = os.WriteFile(path, b, 0666)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestPIE checks that a position independent executable is mapped
+// where it was loaded, not where it was linked.
+func TestPIE(t *testing.T) {
+	dir := unzip(t, "pie.zip")
+
+	// Use dir as the base, so that the dynamic linker of the host,
+	// which may differ from the inferior's, isn't used.
+	exe := filepath.Join(dir, "test")
+	p, err := Core(filepath.Join(dir, "core"), dir, exe)
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	const bias = 0x5592217a4000 - 0x400000
+	if p.LoadBias() != bias {
+		t.Errorf("LoadBias() = %#x, want %#x", p.LoadBias(), bias)
+	}
+
+	syms, err := p.Symbols()
+	if err != nil {
+		t.Fatalf("can't read symbols: %v", err)
+	}
+	a := syms["main.main"]
+	m := p.pageTable.findMapping(a)
+	if m == nil || m.Perm()&Exec == 0 {
+		t.Fatalf("main.main at %x is not in a text mapping", a)
+	}
+
+	// The code at main.main must be the code of main.main in the executable.
+	exeElf, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exeElf.Close()
+	text := exeElf.Section(".text")
+	off := uint64(a) - bias - text.Addr
+	want := make([]byte, 16)
+	if _, err := text.ReadAt(want, int64(off)); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(want))
+	p.ReadAt(got, a)
+	if !bytes.Equal(got, want) {
+		t.Errorf("code at main.main = %x, want %x", got, want)
+	}
+
+	// DWARF has link-time addresses.
+	d, err := p.DWARF()
+	if err != nil {
+		t.Fatalf("can't read DWARF: %v", err)
+	}
+	dr := d.Reader()
+	for e, err := dr.Next(); e != nil && err == nil; e, err = dr.Next() {
+		if e.Tag != dwarf.TagSubprogram || e.Val(dwarf.AttrName) != "main.main" {
+			continue
+		}
+		lowpc, _ := e.Val(dwarf.AttrLowpc).(uint64)
+		if got := Address(lowpc).Add(p.LoadBias()); got != a {
+			t.Errorf("DWARF address of main.main %x + load bias = %x, want %x", lowpc, got, a)
+		}
+		return
+	}
+	t.Errorf("main.main not found in DWARF")
+}
+
+// disableNote rewrites the core file at path so that its notes of type
+// typ have an unknown type instead.
+func disableNote(t *testing.T, path string, typ elf.NType) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	align := func(n uint32) int { return int((n + 3) &^ 3) }
+	for _, prog := range e.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		for off := int(prog.Off); off+12 <= int(prog.Off+prog.Filesz); {
+			namesz := e.ByteOrder.Uint32(b[off:])
+			descsz := e.ByteOrder.Uint32(b[off+4:])
+			if elf.NType(e.ByteOrder.Uint32(b[off+8:])) == typ {
+				e.ByteOrder.PutUint32(b[off+8:], 0xdead)
+			}
+			off += 12 + align(namesz) + align(descsz)
+		}
+	}
+	if err := os.WriteFile(path, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkMap(t *testing.T) {
+	// cgo.zip holds a core of a cgo program, and the shared libraries
+	// it loaded, under the paths they had.
+	dir := unzip(t, "cgo.zip")
+	exe := filepath.Join(dir, "test")
+	p, err := Core(filepath.Join(dir, "core"), dir, exe)
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	want := map[string]bool{
+		"/lib/x86_64-linux-gnu/libc.so.6": true,
+		"/lib64/ld-linux-x86-64.so.2":     true,
+	}
+	libs := p.Libraries()
+	if len(libs) != len(want) {
+		t.Fatalf("got %d libraries, want %d", len(libs), len(want))
+	}
+	for _, l := range libs {
+		if !want[l.Name] {
+			t.Errorf("unexpected library %s", l.Name)
+		}
+		// The file is the one mapped in the NT_FILE note, which
+		// may be named differently through symlinks.
+		fi1, err1 := os.Stat(l.Path())
+		fi2, err2 := os.Stat(filepath.Join(dir, l.Name))
+		if err1 != nil || err2 != nil || !os.SameFile(fi1, fi2) {
+			t.Errorf("library %s found at %s, want %s", l.Name, l.Path(), filepath.Join(dir, l.Name))
+		}
+		// The ELF header of a library is at its load bias, at the
+		// start of the first mapping of its file in the NT_FILE note.
+		if m := p.pageTable.findMapping(Address(l.Bias)); m == nil || m.Min() != Address(l.Bias) {
+			t.Errorf("no mapping starting at the load bias %#x of %s", l.Bias, l.Name)
+		}
+		// The libraries are stripped, but have dynamic symbols.
+		if len(l.Symbols()) == 0 {
+			t.Errorf("no symbols in %s", l.Name)
+		}
+		for _, sym := range l.Symbols() {
+			if sym.File != l.Path() {
+				t.Errorf("symbol %s of %s is from %s", sym.Name, l.Name, sym.File)
+				break
+			}
+		}
+		if _, err := l.DWARF(); err == nil {
+			t.Errorf("got DWARF for the stripped %s", l.Name)
+		}
+	}
+
+	// Without the NT_FILE note, the libraries are found from link_map.
+	disableNote(t, filepath.Join(dir, "core"), _NT_FILE)
+	p2, err := Core(filepath.Join(dir, "core"), dir, exe)
+	if err != nil {
+		t.Fatalf("can't load test core file without NT_FILE: %s", err)
+	}
+	for _, w := range p2.Warnings() {
+		t.Errorf("unexpected warning: %s", w)
+	}
+	libs2 := p2.Libraries()
+	if len(libs2) != len(libs) {
+		t.Fatalf("got %d libraries without NT_FILE, want %d", len(libs2), len(libs))
+	}
+	for i, l := range libs2 {
+		if l.Name != libs[i].Name || l.Bias != libs[i].Bias || l.Dynamic != libs[i].Dynamic {
+			t.Errorf("library %d = %+v without NT_FILE, want %+v", i, *l, *libs[i])
+		}
+		m := p2.pageTable.findMapping(Address(l.Bias))
+		if m == nil {
+			t.Errorf("no mapping at the load bias %#x of %s", l.Bias, l.Name)
+			continue
+		}
+		// The page with the ELF header is also in the core.
+		name, off := m.OrigSource()
+		if name != l.Path() || off != 0 {
+			t.Errorf("mapping at %#x of %s is from %s at offset %#x, want %s at 0", l.Bias, l.Name, name, off, l.Path())
+		}
+		// The rest of the file isn't.
+		if m := p2.pageTable.findMapping(Address(l.Bias).Add(int64(pageSize))); m == nil {
+			t.Errorf("no mapping after the first page of %s", l.Name)
+		} else if name, _ := m.Source(); name != l.Path() {
+			t.Errorf("mapping after the first page of %s is from %s, want %s", l.Name, name, l.Path())
+		}
+		b := make([]byte, 4)
+		p2.ReadAt(b, Address(l.Bias))
+		if string(b) != "\x7fELF" {
+			t.Errorf("%s at %#x starts with %q, want an ELF header", l.Name, l.Bias, b)
+		}
+	}
+}
+
+func TestSymbolAt(t *testing.T) {
+	p := loadExample(t, true)
+	syms, err := p.Symbols()
+	if err != nil {
+		t.Fatalf("can't read symbols: %v", err)
+	}
+	a := syms["main.main"]
+	s, off := p.SymbolAt(a.Add(3))
+	if s == nil || s.Name != "main.main" || off != 3 || !s.Func || s.Section != ".text" {
+		t.Errorf("SymbolAt(main.main+3) = %+v, %d, want main.main, 3", s, off)
+	}
+	a = syms["runtime.allgs"]
+	if s, off := p.SymbolAt(a); s == nil || s.Name != "runtime.allgs" || off != 0 || s.Func {
+		t.Errorf("SymbolAt(runtime.allgs) = %+v, %d, want runtime.allgs, 0", s, off)
+	}
+	if s, _ := p.SymbolAt(0x10); s != nil {
+		t.Errorf("SymbolAt(0x10) = %+v, want nil", s)
+	}
+
+	// The shared libraries of a cgo program are stripped, but have
+	// dynamic symbols.
+	dir := unzip(t, "cgo.zip")
+	p, err = Core(filepath.Join(dir, "core"), dir, filepath.Join(dir, "test"))
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	libc := filepath.Join(dir, "usr/lib/x86_64-linux-gnu/libc.so.6")
+	syms, _ = p.Symbols()
+	a, ok := syms["abort"]
+	if !ok {
+		t.Fatalf("no symbol abort")
+	}
+	if s, off := p.SymbolAt(a.Add(1)); s == nil || s.Name != "abort" || off != 1 || s.File != libc {
+		t.Errorf("SymbolAt(abort+1) = %+v, %d, want abort+1 in %s", s, off, libc)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	p := loadExample(t, true)
+	search := func(pattern []byte, align int) []Address {
+		var hits []Address
+		p.Search(pattern, align, func(a Address) bool {
+			hits = append(hits, a)
+			return true
+		})
+		return hits
+	}
+
+	// The ELF header of the executable.
+	if hits := search([]byte("\x7fELF"), 8); len(hits) == 0 || hits[0] != 0x400000 {
+		t.Errorf("ELF header found at %x, want 400000 first", hits)
+	}
+
+	// The code of main.main, which is only in the executable's text.
+	syms, _ := p.Symbols()
+	main := syms["main.main"]
+	code := make([]byte, 16)
+	p.ReadAt(code, main)
+	if hits := search(code, 1); !reflect.DeepEqual(hits, []Address{main}) {
+		t.Errorf("main.main found at %x, want %x", hits, main)
+	}
+	if hits := search(code[1:], 16); len(hits) != 0 {
+		t.Errorf("main.main+1 found at %x with alignment 16", hits)
+	}
+
+	// Occurrences across two adjacent mappings.
+	var boundary Address
+	ms := p.Mappings()
+	for i := 1; i < len(ms); i++ {
+		if ms[i].Min() == ms[i-1].Max() && ms[i-1].Perm()&Read != 0 && ms[i].Perm()&Read != 0 && ms[i-1].Size() >= 8 {
+			boundary = ms[i].Min()
+			break
+		}
+	}
+	if boundary == 0 {
+		t.Fatal("no adjacent readable mappings")
+	}
+	b := make([]byte, 8)
+	p.ReadAt(b, boundary.Add(-4))
+	found := false
+	for _, a := range search(b, 1) {
+		found = found || a == boundary.Add(-4)
+	}
+	if !found {
+		t.Errorf("%x at %x, across two mappings, not found", b, boundary.Add(-4))
+	}
+
+	// Stopping early.
+	n := 0
+	p.Search([]byte{0}, 1, func(a Address) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("Search called fn %d times, want 3", n)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A fileFinder finds the files mapped by the inferior: first by their
+// build IDs, as recorded in the core, in the directories of the search
+// path, then by their names, under base, and last by their GNU build
+// IDs on debuginfod servers.
+type fileFinder struct {
+	base string
+	opts Options
+	ids  map[string]BuildID // build IDs of mapped files, from the core
+}
+
+// debuginfodClient is the client used to download files from debuginfod
+// servers. The timeout keeps an unresponsive server from hanging the
+// load of the core.
+var debuginfodClient = &http.Client{Timeout: 2 * time.Minute}
+
+// open opens the file named name in the inferior. If the file's build
+// ID is known from the core, files found must have the same build ID,
+// unless the IgnoreBuildID option is set. Search path directories and
+// debuginfod servers know files only by their GNU build ID.
+func (ff *fileFinder) open(name string) (*os.File, error) {
+	id := ff.ids[name]
+	if id.GNU != "" {
+		for _, dir := range ff.opts.SearchPath {
+			// The layout of /usr/lib/debug/.build-id and friends.
+			p := filepath.Join(dir, ".build-id", id.GNU[:2], id.GNU[2:])
+			for _, p := range []string{p, p + ".debug"} {
+				f, err := os.Open(p)
+				if err != nil {
+					continue
+				}
+				// A .debug file may be a separate debug file,
+				// which has no code or data.
+				if e, err := elf.NewFile(f); err != nil || isDebugOnly(e) {
+					f.Close()
+					continue
+				}
+				if f, err := ff.checkBuildID(f, id); err == nil {
+					return f, nil
+				}
+			}
+		}
+	}
+
+	f, err := ff.openByName(name, id)
+	if err == nil {
+		return f, nil
+	}
+	if id.GNU != "" {
+		for _, server := range ff.opts.DebuginfodURLs {
+			if f, err := ff.openFetched(server, id, "executable"); err == nil {
+				return f, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// openByName opens the file named name under base, checking that it
+// has the build ID id.
+func (ff *fileFinder) openByName(name string, id BuildID) (*os.File, error) {
+	f, err := os.Open(filepath.Join(ff.base, name))
+	if err != nil {
+		return nil, err
+	}
+	return ff.checkBuildID(f, id)
+}
+
+// checkBuildID returns f if it has the build ID id. Otherwise it closes
+// f and returns an error. Build IDs aren't checked if id is zero or the
+// IgnoreBuildID option is set.
+func (ff *fileFinder) checkBuildID(f *os.File, id BuildID) (*os.File, error) {
+	if id.IsZero() || ff.opts.IgnoreBuildID {
+		return f, nil
+	}
+	e, err := elf.NewFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fid := exeBuildID(e); fid.mismatch(id) != "" {
+		f.Close()
+		return nil, &BuildIDError{Exe: f.Name(), ExeID: fid, CoreID: id}
+	}
+	return f, nil
+}
+
+// openFetched opens the file of the given type with build ID id,
+// downloaded from the debuginfod server, checking that the server
+// returned the right file.
+func (ff *fileFinder) openFetched(server string, id BuildID, typ string) (*os.File, error) {
+	p, err := ff.fetch(server, id.GNU, typ)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return ff.checkBuildID(f, id)
+}
+
+// fetch downloads the file of the given type ("executable" or
+// "debuginfo") with the build ID key from the debuginfod server, unless
+// it is cached already, and returns its path in the cache.
+// The cache has the same layout as that of the debuginfod client library.
+func (ff *fileFinder) fetch(server, key, typ string) (string, error) {
+	dir := ff.opts.CacheDir
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cache, "debuginfod_client")
+	}
+	p := filepath.Join(dir, key, typ)
+	if _, err := os.Stat(p); err == nil {
+		return p, nil
+	}
+
+	u := strings.TrimSuffix(server, "/") + "/buildid/" + url.PathEscape(key) + "/" + typ
+	resp, err := debuginfodClient.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+
+	// Download to a temporary file, so that an interrupted download
+	// doesn't leave a partial file in the cache.
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), typ+".tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return p, nil
+}
+
+// openDebugFile returns the file with the DWARF and symbol table of
+// the executable exe, which has the build ID id, if they are in a
+// separate file: the DebugFile option, or else the file found by build
+// ID in the search path, by the executable's .gnu_debuglink section, or
+// on a debuginfod server. It returns nil if there is no
+// separate debug file and exe has its own debug information.
+func (ff *fileFinder) openDebugFile(exe *os.File, exeElf *elf.File, id BuildID) (*os.File, error) {
+	if ff.opts.DebugFile != "" {
+		f, err := os.Open(ff.opts.DebugFile)
+		if err != nil {
+			return nil, err
+		}
+		return ff.checkBuildID(f, id)
+	}
+	if hasDebugInfo(exeElf) {
+		return nil, nil
+	}
+
+	if id.GNU != "" {
+		for _, dir := range ff.opts.SearchPath {
+			p := filepath.Join(dir, ".build-id", id.GNU[:2], id.GNU[2:]+".debug")
+			f, err := os.Open(p)
+			if err != nil {
+				continue
+			}
+			if f, err := ff.checkBuildID(f, id); err == nil {
+				return f, nil
+			}
+		}
+	}
+	if f := openDebugLink(exe, exeElf, ff.opts.SearchPath); f != nil {
+		return f, nil
+	}
+	if id.GNU != "" {
+		for _, server := range ff.opts.DebuginfodURLs {
+			if f, err := ff.openFetched(server, id, "debuginfo"); err == nil {
+				return f, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// openDebugLink opens the debug file named by the .gnu_debuglink section
+// of the executable exe, if any.
+func openDebugLink(exe *os.File, exeElf *elf.File, searchPath []string) *os.File {
+	// The .gnu_debuglink section holds the name of the debug file,
+	// padded to 4 bytes, and its CRC-32 checksum.
+	s := exeElf.Section(".gnu_debuglink")
+	if s == nil {
+		return nil
+	}
+	b, err := s.Data()
+	if err != nil {
+		return nil
+	}
+	i := bytes.IndexByte(b, 0)
+	if i <= 0 || (i+4)&^3+4 > len(b) {
+		return nil
+	}
+	name := string(b[:i])
+	crc := exeElf.ByteOrder.Uint32(b[(i+4)&^3:])
+
+	// Look where gdb does: next to the executable, in its .debug
+	// directory, and under the global debug directories.
+	dir, err := filepath.Abs(filepath.Dir(exe.Name()))
+	if err != nil {
+		return nil
+	}
+	paths := []string{
+		filepath.Join(dir, name),
+		filepath.Join(dir, ".debug", name),
+	}
+	dirs := append(append([]string(nil), searchPath...), "/usr/lib/debug")
+	for _, d := range dirs {
+		paths = append(paths, filepath.Join(d, dir, name))
+	}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		h := crc32.NewIEEE()
+		if _, err := io.Copy(h, f); err == nil && h.Sum32() == crc {
+			return f
+		}
+		f.Close()
+	}
+	return nil
+}
+
+// hasDebugInfo reports whether the executable has DWARF and a symbol table.
+func hasDebugInfo(e *elf.File) bool {
+	hasDWARF := e.Section(".debug_info") != nil || e.Section(".zdebug_info") != nil
+	return hasDWARF && e.Section(".symtab") != nil
+}
+
+// isDebugOnly reports whether e is a separate debug file, made by
+// objcopy --only-keep-debug, which has the sections but not the
+// contents of the executable.
+func isDebugOnly(e *elf.File) bool {
+	s := e.Section(".text")
+	return s != nil && s.Type == elf.SHT_NOBITS
+}
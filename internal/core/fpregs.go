@@ -0,0 +1,202 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"math"
+)
+
+// Types of the notes with floating point registers, other than
+// NT_FPREGSET. They are named "LINUX" rather than "CORE".
+const (
+	_NT_PRXFPREG         elf.NType = 0x46e62b7f // FXSAVE area, on 386
+	_NT_X86_XSTATE       elf.NType = 0x202      // XSAVE area, on 386 and amd64
+	_NT_X86_XSAVE_LAYOUT elf.NType = 0x205      // offsets of the XSAVE components
+)
+
+// FPRegs holds the floating point and vector registers of a thread.
+type FPRegs struct {
+	// Vector holds the vector registers, least significant byte
+	// first. On amd64, they are XMM0-XMM15, or YMM0-YMM15 if the
+	// processor has AVX, or ZMM0-ZMM31 if it has AVX-512. On 386,
+	// they are the first 8 of those. On arm64, they are V0-V31.
+	Vector [][]byte
+
+	// X87 holds the x87 registers ST0-ST7, 10 bytes each, on 386
+	// and amd64.
+	X87 [][]byte
+
+	// Mask holds the AVX-512 opmask registers K0-K7, if the
+	// processor has AVX-512.
+	Mask []uint64
+
+	FCW, FSW   uint16 // x87 control and status words, on 386 and amd64
+	MXCSR      uint32 // SSE control and status register, on 386 and amd64
+	FPSR, FPCR uint32 // status and control registers, on arm64
+}
+
+// Float64 returns the float64 in the low 8 bytes of vector register i,
+// where the Go register ABI passes float arguments and results.
+func (r *FPRegs) Float64(i int) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(r.Vector[i]))
+}
+
+// Float32 returns the float32 in the low 4 bytes of vector register i.
+func (r *FPRegs) Float32(i int) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(r.Vector[i]))
+}
+
+// readFPRegs decodes the floating point registers in the notes of a
+// thread. It returns nil if there are none, or they aren't decoded
+// for the architecture.
+func readFPRegs(meta metadata, notes noteMap) *FPRegs {
+	switch meta.arch {
+	case "386", "amd64":
+		nvec := 16
+		if meta.arch == "386" {
+			nvec = 8
+		}
+		if d := notes[_NT_X86_XSTATE]; len(d) > 0 {
+			return readXSAVE(d[0], notes[_NT_X86_XSAVE_LAYOUT], nvec)
+		}
+		if d := notes[_NT_PRXFPREG]; len(d) > 0 {
+			return readXSAVE(d[0], nil, nvec)
+		}
+		if d := notes[elf.NT_FPREGSET]; len(d) > 0 && meta.arch == "amd64" {
+			// On amd64, NT_FPREGSET has the FXSAVE format. On 386,
+			// it has the older FSAVE one, without the SSE registers.
+			return readXSAVE(d[0], nil, nvec)
+		}
+	case "arm64":
+		// struct user_fpsimd_state {
+		//	__uint128_t vregs[32];
+		//	__u32 fpsr;
+		//	__u32 fpcr;
+		// };
+		d := notes[elf.NT_FPREGSET]
+		if len(d) == 0 || len(d[0]) < 32*16+8 {
+			return nil
+		}
+		b := d[0]
+		r := &FPRegs{
+			FPSR: binary.LittleEndian.Uint32(b[512:]),
+			FPCR: binary.LittleEndian.Uint32(b[516:]),
+		}
+		for i := 0; i < 32; i++ {
+			r.Vector = append(r.Vector, append([]byte(nil), b[16*i:16*i+16]...))
+		}
+		return r
+	}
+	return nil
+}
+
+// XSAVE state components.
+const (
+	xsaveYMM     = 2 // upper halves of YMM0-YMM15
+	xsaveOpmask  = 5 // K0-K7
+	xsaveZMMHi   = 6 // upper halves of ZMM0-ZMM15
+	xsaveZMMHi16 = 7 // ZMM16-ZMM31
+)
+
+// xsaveOffsets are the offsets of the components in the standard
+// format of the XSAVE area, as Intel processors lay it out. The kernel
+// records the actual offsets in an NT_X86_XSAVE_LAYOUT note since
+// Linux 6.11, as they may differ on other processors.
+var xsaveOffsets = map[uint32]int{
+	xsaveYMM:     576,
+	xsaveOpmask:  1088,
+	xsaveZMMHi:   1152,
+	xsaveZMMHi16: 1664,
+}
+
+// readXSAVE decodes an XSAVE area, or an FXSAVE area, which is its
+// first 512 bytes. layout is the NT_X86_XSAVE_LAYOUT note, if any.
+// nvec is the number of XMM registers.
+func readXSAVE(b []byte, layout [][]byte, nvec int) *FPRegs {
+	// The legacy region, with the FXSAVE layout:
+	//	0   fcw, fsw uint16
+	//	24  mxcsr uint32
+	//	32  st0-st7, 16 bytes each
+	//	160 xmm0-xmm15, 16 bytes each
+	//	464 the software reserved bytes, where the kernel puts XCR0
+	//	512 the XSAVE header, starting with the XSTATE_BV bitmap
+	if len(b) < 512 {
+		return nil
+	}
+	order := binary.LittleEndian
+	r := &FPRegs{
+		FCW:   order.Uint16(b[0:]),
+		FSW:   order.Uint16(b[2:]),
+		MXCSR: order.Uint32(b[24:]),
+	}
+	for i := 0; i < 8; i++ {
+		r.X87 = append(r.X87, append([]byte(nil), b[32+16*i:32+16*i+10]...))
+	}
+
+	var xcr0, xstateBV uint64
+	if len(b) >= 576 {
+		xcr0 = order.Uint64(b[464:])
+		xstateBV = order.Uint64(b[512:])
+	}
+	offsets := xsaveOffsets
+	if len(layout) > 0 {
+		// An array of struct { type, size, offset, flags uint32 }.
+		offsets = map[uint32]int{}
+		for l := layout[0]; len(l) >= 16; l = l[16:] {
+			offsets[order.Uint32(l)] = int(order.Uint32(l[8:]))
+		}
+	}
+	// component returns the data of component c, of n bytes. It
+	// returns nil if the processor doesn't have it. Components in
+	// their initial state, which is all zeros, aren't saved.
+	component := func(c uint32, n int) []byte {
+		off, ok := offsets[c]
+		if xcr0&(1<<c) == 0 || !ok || off+n > len(b) {
+			return nil
+		}
+		if xstateBV&(1<<c) == 0 {
+			return make([]byte, n)
+		}
+		return b[off : off+n]
+	}
+
+	width := 16
+	ymm := component(xsaveYMM, 16*16)
+	zmmHi := component(xsaveZMMHi, 16*32)
+	var zmmHi16 []byte
+	if ymm != nil {
+		width = 32
+	}
+	if ymm != nil && zmmHi != nil {
+		width = 64
+		if nvec == 16 {
+			zmmHi16 = component(xsaveZMMHi16, 16*64)
+		}
+		if k := component(xsaveOpmask, 8*8); k != nil {
+			for i := 0; i < 8; i++ {
+				r.Mask = append(r.Mask, order.Uint64(k[8*i:]))
+			}
+		}
+	}
+	for i := 0; i < nvec; i++ {
+		v := make([]byte, width)
+		copy(v, b[160+16*i:160+16*i+16])
+		if width >= 32 {
+			copy(v[16:], ymm[16*i:16*i+16])
+		}
+		if width == 64 {
+			copy(v[32:], zmmHi[32*i:32*i+32])
+		}
+		r.Vector = append(r.Vector, v)
+	}
+	if zmmHi16 != nil {
+		for i := 0; i < 16; i++ {
+			r.Vector = append(r.Vector, append([]byte(nil), zmmHi16[64*i:64*i+64]...))
+		}
+	}
+	return r
+}
@@ -0,0 +1,285 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// A Library is a shared library loaded by the dynamic linker.
+type Library struct {
+	Name    string  // path of the library in the inferior
+	Bias    int64   // load bias: addresses in the file plus Bias are addresses in the inferior
+	Dynamic Address // address of the library's dynamic section
+
+	path     string // path of the file found for the library, if any
+	syms     []Symbol
+	dwarf    *dwarf.Data
+	dwarfErr error
+}
+
+// Path returns the path of the file used for the library, or "" if it
+// wasn't found.
+func (l *Library) Path() string {
+	return l.path
+}
+
+// Symbols returns the symbols of the library, from its symbol table
+// and dynamic symbol table, sorted by address. Their addresses are
+// those in the inferior.
+func (l *Library) Symbols() []Symbol {
+	return l.syms
+}
+
+// DWARF returns the debugging information of the library. Its
+// addresses must be adjusted by the library's Bias.
+func (l *Library) DWARF() (*dwarf.Data, error) {
+	if l.path == "" {
+		return nil, fmt.Errorf("library %s not found", l.Name)
+	}
+	return l.dwarf, l.dwarfErr
+}
+
+// Libraries returns the shared libraries loaded by the dynamic linker,
+// in load order, from the dynamic linker's list of loaded objects in
+// the memory of the inferior. It is empty for statically linked
+// executables.
+func (p *Process) Libraries() []*Library {
+	return p.libs
+}
+
+// An earlyMemory reads the memory of the inferior before the mappings
+// are set up: the data dumped in the core, or else the contents of
+// the executable.
+type earlyMemory struct {
+	meta metadata
+	segs []earlySegment
+}
+
+type earlySegment struct {
+	min, max Address
+	r        io.ReaderAt
+	off      int64 // of min in r
+}
+
+func newEarlyMemory(meta metadata, coreFile *os.File, coreElf *elf.File, exeFile *os.File, exeElf *elf.File, bias int64) *earlyMemory {
+	m := &earlyMemory{meta: meta}
+	add := func(progs []*elf.Prog, r io.ReaderAt, bias int64) {
+		for _, prog := range progs {
+			if prog.Type == elf.PT_LOAD && prog.Filesz > 0 {
+				min := Address(prog.Vaddr).Add(bias)
+				m.segs = append(m.segs, earlySegment{min: min, max: min.Add(int64(prog.Filesz)), r: r, off: int64(prog.Off)})
+			}
+		}
+	}
+	add(coreElf.Progs, coreFile, 0)
+	add(exeElf.Progs, exeFile, bias)
+	return m
+}
+
+// read reads len(b) bytes at a, and reports whether it could.
+func (m *earlyMemory) read(a Address, b []byte) bool {
+	for _, s := range m.segs {
+		if a >= s.min && a.Add(int64(len(b))) <= s.max {
+			n, _ := s.r.ReadAt(b, s.off+a.Sub(s.min))
+			return n == len(b)
+		}
+	}
+	return false
+}
+
+// ptr reads a pointer at a.
+func (m *earlyMemory) ptr(a Address) (Address, bool) {
+	b := make([]byte, m.meta.ptrSize)
+	if !m.read(a, b) {
+		return 0, false
+	}
+	if m.meta.ptrSize == 4 {
+		return Address(m.meta.byteOrder.Uint32(b)), true
+	}
+	return Address(m.meta.byteOrder.Uint64(b)), true
+}
+
+// cString reads a NUL-terminated string of at most 4096 bytes at a.
+func (m *earlyMemory) cString(a Address) (string, bool) {
+	var s []byte
+	b := make([]byte, 1)
+	for len(s) < 4096 {
+		if !m.read(a.Add(int64(len(s))), b) {
+			return "", false
+		}
+		if b[0] == 0 {
+			return string(s), true
+		}
+		s = append(s, b[0])
+	}
+	return "", false
+}
+
+// Dynamic section tags.
+const (
+	_DT_NULL  = 0
+	_DT_DEBUG = 21
+)
+
+// readLinkMap returns the shared libraries loaded by the dynamic
+// linker. The DT_DEBUG entry of the executable's dynamic section
+// points to the dynamic linker's r_debug structure, which heads the
+// list of link_map structures of the loaded objects:
+//
+//	struct r_debug {
+//		int r_version;
+//		struct link_map *r_map;
+//		...
+//	};
+//
+//	struct link_map {
+//		ElfW(Addr) l_addr;	/* load bias */
+//		char *l_name;
+//		ElfW(Dyn) *l_ld;	/* dynamic section */
+//		struct link_map *l_next, *l_prev;
+//	};
+//
+// The list starts with the executable itself, which has no name.
+func readLinkMap(mem *earlyMemory, exeElf *elf.File, bias int64) []*Library {
+	var dynamic *elf.Prog
+	for _, prog := range exeElf.Progs {
+		if prog.Type == elf.PT_DYNAMIC {
+			dynamic = prog
+		}
+	}
+	if dynamic == nil {
+		return nil // statically linked
+	}
+	ptrSize := mem.meta.ptrSize
+	var rDebug Address
+	for a, end := Address(dynamic.Vaddr).Add(bias), Address(dynamic.Vaddr+dynamic.Memsz).Add(bias); a < end; a = a.Add(2 * ptrSize) {
+		tag, ok := mem.ptr(a)
+		if !ok || tag == _DT_NULL {
+			break
+		}
+		if tag == _DT_DEBUG {
+			rDebug, _ = mem.ptr(a.Add(ptrSize))
+			break
+		}
+	}
+	if rDebug == 0 {
+		// No DT_DEBUG, or the dynamic linker hadn't set it yet.
+		return nil
+	}
+
+	var libs []*Library
+	lm, _ := mem.ptr(rDebug.Add(ptrSize))
+	for n := 0; lm != 0 && n < 10000; n++ {
+		addr, ok1 := mem.ptr(lm)
+		namep, ok2 := mem.ptr(lm.Add(ptrSize))
+		ld, ok3 := mem.ptr(lm.Add(2 * ptrSize))
+		next, ok4 := mem.ptr(lm.Add(3 * ptrSize))
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			break
+		}
+		name, _ := mem.cString(namep)
+		// Skip the executable, and the vDSO, which has no file.
+		if strings.HasPrefix(name, "/") {
+			libs = append(libs, &Library{Name: name, Bias: int64(addr), Dynamic: ld})
+		}
+		lm = next
+	}
+	return libs
+}
+
+// libraryMappings finds the files of libs with ff, and returns the
+// mappings of their loadable segments, in the form of the NT_FILE note,
+// for cores that don't have one.
+func libraryMappings(meta metadata, coreFile *os.File, coreElf *elf.File, libs []*Library, ff *fileFinder) ([]namedMapping, []string) {
+	var mappings []namedMapping
+	var warnings []string
+	for _, l := range libs {
+		// Libraries are linked at 0, so their ELF header is at the
+		// load bias.
+		if id := coreBuildID(meta, coreFile, coreElf, Address(l.Bias)); !id.IsZero() {
+			ff.ids[l.Name] = id
+		}
+		f, err := ff.open(l.Name)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Can't find shared library %s: %v.", l.Name, err))
+			continue
+		}
+		e, err := elf.NewFile(f)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Can't read shared library %s: %v.", l.Name, err))
+		} else {
+			mappings = append(mappings, elfMappings(e, l.Name, l.Bias)...)
+		}
+		f.Close()
+	}
+	return mappings, warnings
+}
+
+// attachLibraries sets the file of each library in libs to the file
+// mapped at its load bias, where its ELF header is, and reads the
+// library's DWARF from it. The library's symbols are those of symTabs
+// read from that file.
+func attachLibraries(libs []*Library, mem *splicedMemory, coreFile *os.File, symTabs []*symTable) {
+	for _, l := range libs {
+		var f *os.File
+		for _, m := range mem.mappings {
+			if m.min <= Address(l.Bias) && Address(l.Bias) < m.max {
+				// The page with the ELF header is usually in the
+				// core too.
+				if f = m.origF; f == nil && m.f != coreFile {
+					f = m.f
+				}
+				break
+			}
+		}
+		if f == nil {
+			continue
+		}
+		l.path = f.Name()
+		for _, t := range symTabs {
+			if t.file == l.path {
+				l.syms = t.syms
+			}
+		}
+		e, err := elf.NewFile(f)
+		if err != nil {
+			l.dwarfErr = err
+			continue
+		}
+		l.dwarf, l.dwarfErr = e.DWARF()
+	}
+}
+
+// elfMappings returns the mappings of the loadable segments of the ELF
+// file e, named name, loaded bias bytes above the addresses it was
+// linked at.
+func elfMappings(e *elf.File, name string, bias int64) []namedMapping {
+	var mappings []namedMapping
+	for _, prog := range e.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Filesz == 0 {
+			continue
+		}
+		// The file is mapped in whole pages.
+		min := Address(prog.Vaddr).Add(bias)
+		max := min.Add(int64(prog.Filesz))
+		off := int64(prog.Off) - int64(min%pageSize)
+		min -= min % pageSize
+		if max%pageSize != 0 {
+			max += pageSize - max%pageSize
+		}
+		if n := len(mappings); n > 0 && mappings[n-1].max > min {
+			// Segments sharing a page. The later mapping wins.
+			mappings[n-1].max = min
+		}
+		mappings = append(mappings, namedMapping{min: min, max: max, f: name, off: off})
+	}
+	return mappings
+}
@@ -27,6 +27,10 @@ type Mapping struct {
 
 	// Contents of f at offset off. Length=max-min.
 	contents []byte
+
+	// The contents of the mapping are missing from a truncated core
+	// file. The mapping has no contents and isn't readable.
+	missing bool
 }
 
 // namedMapping is equivalent to Mapping, just using the filename rather than
@@ -67,6 +71,13 @@ func (m *Mapping) Source() (string, int64) {
 	return m.f.Name(), m.off
 }
 
+// Missing reports whether the contents of the mapping should be in the
+// core file but are missing, because the core file is truncated.
+// Missing mappings can't be read.
+func (m *Mapping) Missing() bool {
+	return m.missing
+}
+
 // CopyOnWrite reports whether the mapping is a copy-on-write region, i.e.
 // it started as a mapped file and is now writeable.
 // TODO: is this distinguishable from a write-back region?
@@ -255,6 +266,16 @@ func (s *splicedMemory) Add(min, max Address, perm Perm, f *os.File, off int64)
 	s.mappings = newMappings
 }
 
+// markMissing marks the mappings in [min, max) that have no backing file
+// as missing from the core file.
+func (s *splicedMemory) markMissing(min, max Address) {
+	for _, m := range s.mappings {
+		if m.min >= min && m.max <= max && m.f == nil {
+			m.missing = true
+		}
+	}
+}
+
 // splitMappingsAt ensures that a is not in the middle of any mapping.
 // Splits mappings as necessary.
 func (s *splicedMemory) splitMappingsAt(a Address) {
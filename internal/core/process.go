@@ -12,7 +12,7 @@
 // just as easily be used to read a C++ core dump. See ../gocore
 // for the next layer up, a Go-specific core dump reader.
 //
-// The Read* operations all panic with an error (the builtin Go type)
+// The Read* operations all panic with a *ReadError
 // if the inferior is not readable at the address requested.
 package core
 
@@ -23,10 +23,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // TODO: add these to debug/elf?
@@ -40,18 +40,28 @@ type Process struct {
 	meta metadata // basic metadata about the core
 
 	entryPoint Address
-	args       string    // first part of args retrieved from NT_PRPSINFO
-	threads    []*Thread // os threads (TODO: map from pid?)
+	auxv       []AuxvEntry  // auxiliary vector, from NT_AUXV
+	args       string       // first part of args retrieved from NT_PRPSINFO
+	info       *ProcessInfo // from NT_PRPSINFO, if any
+	signal     *SignalInfo  // from NT_SIGINFO, if any
+	threads    []*Thread    // os threads (TODO: map from pid?)
+	libs       []*Library   // shared libraries, from the dynamic linker's link_map
 
 	memory    splicedMemory // virtual address mappings
 	pageTable pageTable4    // for fast address->mapping lookups
 
 	syms     map[string]Address // symbols (could be empty if executable is stripped)
+	symTabs  []*symTable        // symbols of each file, sorted by address
 	symErr   error              // an error encountered while reading symbols
 	dwarf    *dwarf.Data        // debugging info (could be nil)
 	dwarfErr error              // an error encountered while reading DWARF
 
 	warnings []string // warnings generated during loading
+
+	exeID     BuildID // build ID of the executable
+	coreID    BuildID // build ID of the executable, as found in the core
+	debugPath string  // path of the separate debug file, if any
+	loadBias  int64   // where the executable is loaded, minus where it was linked
 }
 
 type metadata struct {
@@ -195,6 +205,42 @@ var mapFile = func(fd int, offset int64, length int) (data []byte, err error) {
 // exePath is the path of the main executable. If "", the path will be
 // determined from the core itself.
 func Core(corePath, base, exePath string) (*Process, error) {
+	return CoreWithOptions(corePath, base, exePath, Options{})
+}
+
+// Options controls how CoreWithOptions finds and checks the files
+// that make up the state of the inferior.
+type Options struct {
+	// IgnoreBuildID makes CoreWithOptions use the executable even if
+	// its build ID doesn't match the one found in the core.
+	IgnoreBuildID bool
+
+	// DebugFile is the path of a file with the DWARF and symbol table
+	// of the executable, for executables stripped of them. If "", such
+	// a file is looked for by build ID and the executable's
+	// .gnu_debuglink section, if the executable has no debug info.
+	DebugFile string
+
+	// SearchPath lists directories in which to look for the executable
+	// and shared libraries by their GNU build IDs, as found in the core,
+	// before looking for them by name. The directories have the
+	// .build-id/ab/cdef... layout of /usr/lib/debug. Executables with
+	// only a Go build ID are looked for by name only.
+	SearchPath []string
+
+	// DebuginfodURLs lists the URLs of debuginfod servers from which
+	// to download the executable and shared libraries by GNU build ID,
+	// and separate debug files, if they aren't found locally.
+	DebuginfodURLs []string
+
+	// CacheDir is the directory in which downloaded files are kept.
+	// The default is the debuginfod_client directory in the user's
+	// cache directory.
+	CacheDir string
+}
+
+// CoreWithOptions is like Core, with options.
+func CoreWithOptions(corePath, base, exePath string, opts Options) (*Process, error) {
 	coreFile, err := os.Open(corePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open core file: %v", err)
@@ -210,16 +256,28 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		return nil, fmt.Errorf("error reading metadata: %v", err)
 	}
 
-	notes, err := readCoreNotes(coreFile, coreElf)
+	notes, threadNotes, err := readCoreNotes(coreFile, coreElf)
 	if err != nil {
 		return nil, err
 	}
 
-	entryPoint := readEntryPoint(meta, notes)
+	auxv := readAuxv(meta, notes)
+	entry, _ := auxvValue(auxv, AT_ENTRY)
+	entryPoint := Address(entry)
 	fileMappings := readFileMappings(meta, notes)
 
 	origExePath := findExe(fileMappings, entryPoint)
 
+	// Find the build IDs of the mapped files, to look them up by.
+	ff := &fileFinder{base: base, opts: opts, ids: map[string]BuildID{}}
+	for _, m := range fileMappings {
+		if m.off == 0 {
+			if id := coreBuildID(meta, coreFile, coreElf, m.min); !id.IsZero() {
+				ff.ids[m.f] = id
+			}
+		}
+	}
+
 	var exeFile *os.File
 	if exePath != "" {
 		var err error
@@ -229,7 +287,10 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		}
 	} else {
 		var err error
-		exeFile, err = os.Open(filepath.Join(base, origExePath))
+		exeFile, err = ff.open(origExePath)
+		if _, ok := err.(*BuildIDError); ok {
+			return nil, err
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to open executable file: %v", err)
 		}
@@ -241,26 +302,82 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		return nil, fmt.Errorf("failed to parse executable: %v", err)
 	}
 
+	var warnings []string
+	bias, ok := loadBias(auxv, exeElf)
+	if !ok {
+		warnings = append(warnings,
+			"Can't find where the position independent executable is loaded: no AT_ENTRY or AT_PHDR in the core. Assuming it is loaded where it was linked.")
+	}
+
+	// Check that the executable is the one that dumped core.
+	exeID := exeBuildID(exeElf)
+	coreID := coreBuildID(meta, coreFile, coreElf, exeBase(fileMappings, origExePath, exeElf, bias))
+	if exeID.mismatch(coreID) != "" {
+		err := &BuildIDError{Exe: exeFile.Name(), ExeID: exeID, CoreID: coreID}
+		if !opts.IgnoreBuildID {
+			return nil, err
+		}
+		warnings = append(warnings, err.Error()+". Using it anyway.")
+	} else if coreID.IsZero() && !exeID.IsZero() {
+		warnings = append(warnings,
+			fmt.Sprintf("Can't check that %s is the executable that dumped core: no build ID in the core.", exeFile.Name()))
+	}
+
+	// Find the shared libraries. Without an NT_FILE note, which cores
+	// written by older kernels or some dumpers lack, they tell which
+	// files are mapped where.
+	libs := readLinkMap(newEarlyMemory(meta, coreFile, coreElf, exeFile, exeElf, bias), exeElf, bias)
+	if len(fileMappings) == 0 {
+		libMappings, libWarnings := libraryMappings(meta, coreFile, coreElf, libs, ff)
+		fileMappings = append(elfMappings(exeElf, origExePath, bias), libMappings...)
+		warnings = append(warnings, libWarnings...)
+	}
+
 	// The base memory layout is defined by the binary itself. Additional
 	// mappings from the core layer on top. This ordering is important to
 	// ensure that dirty data/bss pages from the core take priority over
 	// the initial state from the binary.
-	mem := readExecMappings(exeFile, exeElf)
+	mem := readExecMappings(exeFile, exeElf, bias)
 	addCoreMappings(&mem, coreFile, coreElf)
 	// Add os.File references to mappings of files.
-	warnings := updateMappingFiles(&mem, fileMappings, base, exeFile, origExePath)
+	warnings = append(warnings, updateMappingFiles(&mem, fileMappings, ff, exeFile, origExePath)...)
 
-	threads := readThreads(meta, notes)
-	args, err := readArgs(meta, notes)
+	threads, byNote := readThreads(meta, threadNotes)
+	signal := readSignal(meta, threadNotes, byNote)
+	info, err := readProcessInfo(meta, notes)
 	if err != nil {
 		return nil, fmt.Errorf("error reading args: %v", err)
 	}
+	var args string
+	if info != nil {
+		args = info.Args
+	}
+
+	// The DWARF and symbol table may be in a separate file.
+	// The memory layout always comes from the executable.
+	debugFile, err := ff.openDebugFile(exeFile, exeElf, exeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open debug file: %w", err)
+	}
+	debugElf := exeElf
+	if debugFile != nil {
+		defer debugFile.Close()
+		debugElf, err = elf.NewFile(debugFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse debug file: %v", err)
+		}
+	}
 
-	syms, symErr := readSymbols(&mem, coreFile)
+	syms, symTabs, symErr := readSymbols(&mem, coreFile, exeFile, debugFile)
+	attachLibraries(libs, &mem, coreFile, symTabs)
 
-	dwarf, dwarfErr := exeElf.DWARF()
+	dwarf, dwarfErr := debugElf.DWARF()
 	if dwarfErr != nil {
-		dwarfErr = fmt.Errorf("error reading DWARF info from %s: %v", exeFile.Name(), dwarfErr)
+		name := exeFile.Name()
+		if debugFile != nil {
+			name = debugFile.Name()
+		}
+		dwarfErr = fmt.Errorf("error reading DWARF info from %s: %v", name, dwarfErr)
 	}
 
 	// Sort then merge mappings, just to clean up a bit.
@@ -275,6 +392,7 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		if m.min == k.max &&
 			m.perm == k.perm &&
 			m.f == k.f &&
+			m.missing == k.missing &&
 			m.off == k.off+k.Size() {
 			k.max = m.max
 			// TODO: also check origF?
@@ -286,8 +404,20 @@ func Core(corePath, base, exePath string) (*Process, error) {
 
 	// Memory map all the mappings.
 	hostPageSize := int64(syscall.Getpagesize())
+	var missing, missingSize int64
 	for _, m := range mem.mappings {
 		size := m.max.Sub(m.min)
+		if m.f != nil {
+			// A mapped file provides the data missing from the core.
+			m.missing = false
+		}
+		if m.missing {
+			// Leave the mapping without contents,
+			// so reads from it fail.
+			missing++
+			missingSize += size
+			continue
+		}
 		if m.f == nil {
 			// We don't have any source for this data.
 			// Could be a mapped file that we couldn't find.
@@ -330,9 +460,17 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		m.contents = data
 	}
 
+	if missing > 0 {
+		warnings = append(warnings,
+			fmt.Sprintf("Core file is truncated: %d bytes in %d mappings are missing. Reads from them will fail.", missingSize, missing))
+	}
+
 	// Build page table for mapping lookup.
 	var pageTable pageTable4
 	for _, m := range mem.mappings {
+		if m.missing {
+			continue
+		}
 		err := pageTable.addMapping(m)
 		if err != nil {
 			return nil, err
@@ -342,46 +480,99 @@ func Core(corePath, base, exePath string) (*Process, error) {
 	p := &Process{
 		meta:       meta,
 		entryPoint: entryPoint,
+		auxv:       auxv,
 		args:       args,
+		info:       info,
+		signal:     signal,
 		threads:    threads,
+		libs:       libs,
 		memory:     mem,
 		pageTable:  pageTable,
 		syms:       syms,
+		symTabs:    symTabs,
 		symErr:     symErr,
 		dwarf:      dwarf,
 		dwarfErr:   dwarfErr,
 		warnings:   warnings,
+		exeID:      exeID,
+		coreID:     coreID,
+		loadBias:   bias,
+	}
+	if debugFile != nil {
+		p.debugPath = debugFile.Name()
 	}
 
 	return p, nil
 }
 
 // readExecMappings returns the memory mappings defined by the executable
-// itself.
-func readExecMappings(exeFile *os.File, exeElf *elf.File) splicedMemory {
+// itself, loaded bias bytes above the addresses it was linked at.
+func readExecMappings(exeFile *os.File, exeElf *elf.File, bias int64) splicedMemory {
 	// Load virtual memory mappings.
 	var mem splicedMemory
 	for _, prog := range exeElf.Progs {
 		if prog.Type == elf.PT_LOAD {
-			addProgMappings(&mem, prog, exeFile)
+			addProgMappings(&mem, prog, exeFile, bias)
 		}
 	}
 	return mem
 }
 
+// loadBias returns the difference between the address at which the
+// executable is loaded in the inferior and the address it was linked
+// at. It is 0 unless the executable is position independent (ET_DYN),
+// in which case the kernel chose where to load it. The entry point and
+// the program headers in the auxiliary vector tell where that is.
+// It reports false if they don't.
+func loadBias(auxv []AuxvEntry, exeElf *elf.File) (int64, bool) {
+	if exeElf.Type != elf.ET_DYN {
+		return 0, true
+	}
+	if entry, ok := auxvValue(auxv, AT_ENTRY); ok && exeElf.Entry != 0 {
+		return int64(entry - exeElf.Entry), true
+	}
+	if phdr, ok := auxvValue(auxv, AT_PHDR); ok {
+		for _, prog := range exeElf.Progs {
+			if prog.Type == elf.PT_PHDR {
+				return int64(phdr - prog.Vaddr), true
+			}
+		}
+	}
+	return 0, false
+}
+
 // addCoreMappings adds memory mappings from the core file to mem.
 func addCoreMappings(mem *splicedMemory, coreFile *os.File, coreElf *elf.File) {
+	size := int64(-1)
+	if fi, err := coreFile.Stat(); err == nil {
+		size = fi.Size()
+	}
 	for _, prog := range coreElf.Progs {
-		if prog.Type == elf.PT_LOAD {
-			addProgMappings(mem, prog, coreFile)
+		if prog.Type != elf.PT_LOAD {
+			continue
+		}
+		addProgMappings(mem, prog, coreFile, 0)
+		if size < 0 || prog.Filesz == 0 || int64(prog.Off+prog.Filesz) <= size {
+			continue
+		}
+		// The core file is truncated, probably because the process
+		// was killed while dumping core. Keep the whole pages that made
+		// it into the file, and mark the rest as missing. Mapping the
+		// file past its end would fault when the data is read.
+		avail := size - int64(prog.Off)
+		if avail < 0 {
+			avail = 0
 		}
+		avail -= avail % int64(pageSize)
+		min := Address(prog.Vaddr).Add(avail)
+		max := Address(prog.Vaddr).Add(int64(prog.Filesz))
+		mem.Add(min, max, progPerm(prog), nil, 0)
+		mem.markMissing(min, max)
 	}
 }
 
-// addProgMappings adds memory mappings for prog (from file f) to mem.
-func addProgMappings(mem *splicedMemory, prog *elf.Prog, f *os.File) {
-	min := Address(prog.Vaddr)
-	max := min.Add(int64(prog.Memsz))
+// progPerm returns the permissions of the memory mapped for prog.
+func progPerm(prog *elf.Prog) Perm {
 	var perm Perm
 	if prog.Flags&elf.PF_R != 0 {
 		perm |= Read
@@ -392,6 +583,15 @@ func addProgMappings(mem *splicedMemory, prog *elf.Prog, f *os.File) {
 	if prog.Flags&elf.PF_X != 0 {
 		perm |= Exec
 	}
+	return perm
+}
+
+// addProgMappings adds memory mappings for prog (from file f) to mem,
+// bias bytes above the address prog asks for.
+func addProgMappings(mem *splicedMemory, prog *elf.Prog, f *os.File, bias int64) {
+	min := Address(prog.Vaddr).Add(bias)
+	max := min.Add(int64(prog.Memsz))
+	perm := progPerm(prog)
 	if perm == 0 {
 		// TODO: keep these nothing-mapped mappings?
 		return
@@ -415,9 +615,15 @@ func addProgMappings(mem *splicedMemory, prog *elf.Prog, f *os.File) {
 // appear in the ELF.
 type noteMap map[elf.NType][][]byte
 
-// readNotes returns contents of all CORE ELF notes from the core file.
-func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, error) {
+// readCoreNotes returns contents of all CORE ELF notes from the core file,
+// and the notes of each thread.
+//
+// The notes of a thread are those from its NT_PRSTATUS note up to the
+// next thread's, with any name: notes such as NT_FPREGSET don't say
+// which thread they belong to.
+func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, []noteMap, error) {
 	notes := make(noteMap)
+	var threadNotes []noteMap
 
 	for _, prog := range coreElf.Progs {
 		if prog.Type != elf.PT_NOTE {
@@ -427,7 +633,7 @@ func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, error) {
 		b := make([]byte, prog.Filesz)
 		_, err := coreFile.ReadAt(b, int64(prog.Off))
 		if err != nil {
-			return nil, fmt.Errorf("error reading notes at offset %d: %v", prog.Off, err)
+			return nil, nil, fmt.Errorf("error reading notes at offset %d: %v", prog.Off, err)
 		}
 		for len(b) > 0 {
 			namesz := coreElf.ByteOrder.Uint32(b)
@@ -441,6 +647,14 @@ func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, error) {
 			desc := b[:descsz]
 			b = b[(descsz+3)/4*4:]
 
+			if name == "CORE" && typ == elf.NT_PRSTATUS {
+				threadNotes = append(threadNotes, make(noteMap))
+			}
+			if len(threadNotes) > 0 {
+				tn := threadNotes[len(threadNotes)-1]
+				tn[typ] = append(tn[typ], desc)
+			}
+
 			if name != "CORE" {
 				continue
 			}
@@ -449,34 +663,7 @@ func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, error) {
 		}
 	}
 
-	return notes, nil
-}
-
-func readEntryPoint(meta metadata, notes noteMap) Address {
-	// amd64 only?
-	const _AT_ENTRY_AMD64 = 9
-
-	if len(notes[_NT_AUXV]) == 0 {
-		return 0
-	}
-
-	// We don't expect multiple NT_AUXV notes. Just use the first.
-	desc := notes[_NT_AUXV][0]
-
-	buf := bytes.NewBuffer(desc)
-	for {
-		var tag, val uint64
-		if err := binary.Read(buf, meta.byteOrder, &tag); err != nil {
-			panic(err)
-		}
-		if err := binary.Read(buf, meta.byteOrder, &val); err != nil {
-			panic(err)
-		}
-		if tag == _AT_ENTRY_AMD64 {
-			return Address(val)
-		}
-	}
-	return 0
+	return notes, threadNotes, nil
 }
 
 func readFileMappings(meta metadata, notes noteMap) []namedMapping {
@@ -487,22 +674,37 @@ func readFileMappings(meta metadata, notes noteMap) []namedMapping {
 	// We don't expect multiple NT_FILE notes. Just use the first.
 	desc := notes[_NT_FILE][0]
 
-	// TODO: 4 instead of 8 for 32-bit machines?
-	count := meta.byteOrder.Uint64(desc)
-	desc = desc[8:]
-	pagesize := meta.byteOrder.Uint64(desc)
-	desc = desc[8:]
-	filenames := string(desc[3*8*count:])
-	desc = desc[:3*8*count]
+	// The note has words of the inferior's size:
+	//	count, page size
+	//	count times: start, end, offset in pages
+	//	count NUL-terminated file names
+	n := int(meta.ptrSize)
+	word := func() uint64 {
+		var w uint64
+		if n == 4 {
+			w = uint64(meta.byteOrder.Uint32(desc))
+		} else {
+			w = meta.byteOrder.Uint64(desc)
+		}
+		desc = desc[n:]
+		return w
+	}
+	if len(desc) < 2*n {
+		return nil
+	}
+	count := word()
+	pagesize := word()
+	if count > uint64(len(desc)/(3*n)) {
+		return nil
+	}
+	filenames := string(desc[3*n*int(count):])
+	desc = desc[:3*n*int(count)]
 
 	var mappings []namedMapping
 	for i := uint64(0); i < count; i++ {
-		min := Address(meta.byteOrder.Uint64(desc))
-		desc = desc[8:]
-		max := Address(meta.byteOrder.Uint64(desc))
-		desc = desc[8:]
-		off := int64(meta.byteOrder.Uint64(desc) * pagesize)
-		desc = desc[8:]
+		min := Address(word())
+		max := Address(word())
+		off := int64(word() * pagesize)
 
 		var name string
 		j := strings.IndexByte(filenames, 0)
@@ -541,11 +743,11 @@ func findExe(mappings []namedMapping, entryPoint Address) string {
 // updateMappingsFiles adds os.File references to mappings in mem of files in
 // fileMappings.
 //
-// base is the base directory from which files in fileMappings can be found.
+// ff finds the files in fileMappings.
 //
 // exeFile is the reference to the executable, which is named origExePath in
 // fileMappings.
-func updateMappingFiles(mem *splicedMemory, fileMappings []namedMapping, base string, exeFile *os.File, origExePath string) []string {
+func updateMappingFiles(mem *splicedMemory, fileMappings []namedMapping, ff *fileFinder, exeFile *os.File, origExePath string) []string {
 	type file struct {
 		f   *os.File
 		err error
@@ -559,7 +761,7 @@ func updateMappingFiles(mem *splicedMemory, fileMappings []namedMapping, base st
 			return f.f, f.err
 		}
 
-		f, err := os.Open(filepath.Join(base, name))
+		f, err := ff.open(name)
 		file := &file{f: f, err: err}
 		files[name] = file
 		return f, err
@@ -585,7 +787,11 @@ func updateMappingFiles(mem *splicedMemory, fileMappings []namedMapping, base st
 				// We don't want to make this a hard error because there are
 				// lots of possible missing files that probably aren't critical,
 				// like a random shared library.
-				warnings = append(warnings, fmt.Sprintf("Missing data for addresses [%x %x] because of failure to %s. Assuming all zero.", m.min, m.max, err))
+				if _, ok := err.(*BuildIDError); ok {
+					warnings = append(warnings, fmt.Sprintf("Missing data for addresses [%x %x]: %s. Assuming all zero.", m.min, m.max, err))
+				} else {
+					warnings = append(warnings, fmt.Sprintf("Missing data for addresses [%x %x] because of failure to %s. Assuming all zero.", m.min, m.max, err))
+				}
 			}
 
 			if m.f == nil {
@@ -604,146 +810,189 @@ func updateMappingFiles(mem *splicedMemory, fileMappings []namedMapping, base st
 	return warnings
 }
 
-func readArgs(meta metadata, notes noteMap) (string, error) {
+// readProcessInfo decodes the NT_PRPSINFO note. It returns nil if
+// there is none.
+func readProcessInfo(meta metadata, notes noteMap) (*ProcessInfo, error) {
 	if len(notes[elf.NT_PRPSINFO]) == 0 {
-		return "", nil
+		return nil, nil
 	}
 
 	// We don't expect multiple NT_PRPSINFO notes. Just use the first.
 	desc := notes[elf.NT_PRPSINFO][0]
 
-	var args string
-
 	r := bytes.NewReader(desc)
-	switch meta.arch {
+	var info *ProcessInfo
+	var fname, args []byte
+	switch {
+	case meta.ptrSize == 8:
+		var ps linuxPrPsInfo
+		if err := binary.Read(r, meta.byteOrder, &ps); err != nil {
+			return nil, fmt.Errorf("error decoding prpsinfo: %v", err)
+		}
+		info = &ProcessInfo{
+			State: ps.State, StateName: byte(ps.Sname), Zombie: ps.Zomb != 0, Nice: ps.Nice,
+			Flags: ps.Flag, UID: ps.Uid, GID: ps.Gid,
+			PID: int(ps.Pid), PPID: int(ps.Ppid), PGRP: int(ps.Pgrp), SID: int(ps.Sid),
+		}
+		fname, args = ps.Fname[:], ps.Args[:]
+	case meta.arch == "386" || meta.arch == "arm":
+		// These have 16-bit user and group IDs.
+		var ps linuxPrPsInfo32
+		if err := binary.Read(r, meta.byteOrder, &ps); err != nil {
+			return nil, fmt.Errorf("error decoding prpsinfo: %v", err)
+		}
+		info = &ProcessInfo{
+			State: ps.State, StateName: byte(ps.Sname), Zombie: ps.Zomb != 0, Nice: ps.Nice,
+			Flags: uint64(ps.Flag), UID: uint32(ps.Uid), GID: uint32(ps.Gid),
+			PID: int(ps.Pid), PPID: int(ps.Ppid), PGRP: int(ps.Pgrp), SID: int(ps.Sid),
+		}
+		fname, args = ps.Fname[:], ps.Args[:]
 	default:
-		// TODO: return error?
-	case "amd64":
-		prpsinfo := &linuxPrPsInfo{}
-		if err := binary.Read(r, binary.LittleEndian, prpsinfo); err != nil {
-			return "", fmt.Errorf("error decoding prpsinfo: %v", err)
+		var ps linuxPrPsInfoMIPS
+		if err := binary.Read(r, meta.byteOrder, &ps); err != nil {
+			return nil, fmt.Errorf("error decoding prpsinfo: %v", err)
+		}
+		info = &ProcessInfo{
+			State: ps.State, StateName: byte(ps.Sname), Zombie: ps.Zomb != 0, Nice: ps.Nice,
+			Flags: uint64(ps.Flag), UID: ps.Uid, GID: ps.Gid,
+			PID: int(ps.Pid), PPID: int(ps.Ppid), PGRP: int(ps.Pgrp), SID: int(ps.Sid),
 		}
-		args = strings.Trim(string(prpsinfo.Args[:]), "\x00 ")
+		fname, args = ps.Fname[:], ps.Args[:]
 	}
-
-	return args, nil
+	if i := bytes.IndexByte(fname, 0); i >= 0 {
+		fname = fname[:i]
+	}
+	info.Fname = string(fname)
+	info.Args = strings.Trim(string(args), "\x00 ")
+	return info, nil
 }
 
-func readThreads(meta metadata, notes noteMap) []*Thread {
-	var threads []*Thread
+// readThreads returns the threads described by the notes of each thread.
+// Threads whose NT_PRSTATUS note can't be decoded are dropped. byNote
+// holds the thread of each element of threadNotes, or nil if it was
+// dropped.
+func readThreads(meta metadata, threadNotes []noteMap) (threads, byNote []*Thread) {
+	byNote = make([]*Thread, len(threadNotes))
 
-	for _, desc := range notes[elf.NT_PRSTATUS] {
-		t := &Thread{}
-		threads = append(threads, t)
+	for i, notes := range threadNotes {
+		desc := notes[elf.NT_PRSTATUS][0]
+		t := &Thread{fpregs: readFPRegs(meta, notes)}
 		// Linux
-		//   sys/procfs.h:
+		//   linux/elfcore.h:
 		//     struct elf_prstatus {
-		//       ...
-		//       pid_t	pr_pid;
-		//       ...
+		//       struct elf_siginfo pr_info;
+		//       short pr_cursig;
+		//       unsigned long pr_sigpend, pr_sighold;
+		//       pid_t pr_pid, pr_ppid, pr_pgrp, pr_sid;
+		//       struct timeval pr_utime, pr_stime, pr_cutime, pr_cstime;
 		//       elf_gregset_t pr_reg;	/* GP registers */
-		//       ...
+		//       int pr_fpvalid;
 		//     };
-		//   typedef struct elf_prstatus prstatus_t;
-		// Register numberings are listed in sys/user.h.
-		// prstatus layout will probably be different for each arch/os combo.
-		switch meta.arch {
-		default:
-			// TODO: return error here?
-		case "amd64":
-			// 32 = offsetof(prstatus_t, pr_pid), 4 = sizeof(pid_t)
-			t.pid = uint64(meta.byteOrder.Uint32(desc[32 : 32+4]))
-			// 112 = offsetof(prstatus_t, pr_reg), 216 = sizeof(elf_gregset_t)
-			reg := desc[112 : 112+216]
-			for i := 0; i < len(reg); i += 8 {
-				t.regs = append(t.regs, meta.byteOrder.Uint64(reg[i:]))
+		// The layout is the same on all architectures, except for the
+		// size of long. Register numberings are listed in asm/ptrace.h,
+		// and in regLayouts.
+		r := bytes.NewReader(desc)
+		switch meta.ptrSize {
+		case 8:
+			var st linuxPrStatus
+			if binary.Read(r, meta.byteOrder, &st) != nil {
+				continue
 			}
-			// Registers are:
-			//  0: r15
-			//  1: r14
-			//  2: r13
-			//  3: r12
-			//  4: rbp
-			//  5: rbx
-			//  6: r11
-			//  7: r10
-			//  8: r9
-			//  9: r8
-			// 10: rax
-			// 11: rcx
-			// 12: rdx
-			// 13: rsi
-			// 14: rdi
-			// 15: orig_rax
-			// 16: rip
-			// 17: cs
-			// 18: eflags
-			// 19: rsp
-			// 20: ss
-			// 21: fs_base
-			// 22: gs_base
-			// 23: ds
-			// 24: es
-			// 25: fs
-			// 26: gs
-			t.pc = Address(t.regs[16])
-			t.sp = Address(t.regs[19])
-
-			// TODO: NT_FPREGSET for floating-point registers.
-			//
-			// This will be a bit awkward with the notes map, as
-			// the NT_FPREGSET notes are implicitly associated with
-			// the thread described by the previous NT_PRSTATUS
-			// rather than directly denoting which thread they
-			// belong to.
-		}
-	}
-
-	return threads
-}
-
-func readSymbols(mem *splicedMemory, coreFile *os.File) (map[string]Address, error) {
-	seen := map[*os.File]struct{}{
-		// Don't bother trying to read symbols from the core itself.
-		coreFile: struct{}{},
-	}
-
-	allSyms := make(map[string]Address)
-	var symErr error
-
-	// Read symbols from all available files.
-	for _, m := range mem.mappings {
-		if m.f == nil {
-			continue
+			t.pid = uint64(st.Pid)
+			t.signal = int(st.Cursig)
+			t.sigPending, t.sigHeld = st.Sigpend, st.Sighold
+			t.userTime, t.sysTime = st.Utime.duration(), st.Stime.duration()
+		case 4:
+			var st linuxPrStatus32
+			if binary.Read(r, meta.byteOrder, &st) != nil {
+				continue
+			}
+			t.pid = uint64(st.Pid)
+			t.signal = int(st.Cursig)
+			t.sigPending, t.sigHeld = uint64(st.Sigpend), uint64(st.Sighold)
+			t.userTime, t.sysTime = st.Utime.duration(), st.Stime.duration()
 		}
-		if _, ok := seen[m.f]; ok {
-			continue
+		reg := desc[len(desc)-r.Len():]
+		if t.registers = readRegisters(meta, reg); t.registers != nil {
+			t.regs = t.registers.values
+			t.pc = t.registers.PC()
+			t.sp = t.registers.SP()
 		}
-		seen[m.f] = struct{}{}
+		threads = append(threads, t)
+		byNote[i] = t
+	}
 
-		e, err := elf.NewFile(m.f)
-		if err != nil {
-			symErr = fmt.Errorf("can't read symbols from %s: %v", m.f.Name(), err)
-			continue
-		}
+	return threads, byNote
+}
 
-		syms, err := e.Symbols()
-		if err != nil {
-			symErr = fmt.Errorf("can't read symbols from %s: %v", m.f.Name(), err)
+// fileBias returns the load bias of the ELF file e, which is mapped by
+// m: the difference between the addresses at which its contents are
+// mapped and the addresses in its program headers and symbols.
+// A separate debug file has the same program headers as its executable.
+func fileBias(m *Mapping, e *elf.File) int64 {
+	for _, prog := range e.Progs {
+		// The mapping may start at the page boundary before the segment.
+		start := prog.Off &^ uint64(pageSize-1)
+		if prog.Type != elf.PT_LOAD || uint64(m.off) < start || uint64(m.off) >= prog.Off+prog.Filesz {
 			continue
 		}
-		for _, s := range syms {
-			allSyms[s.Name] = Address(s.Value)
-		}
+		return m.min.Sub(Address(prog.Vaddr - prog.Off + uint64(m.off)))
 	}
+	return 0
+}
+
+// LoadBias returns the difference between the address at which the
+// executable is loaded and the address it was linked at. It is
+// non-zero only for position independent executables. Addresses in
+// the DWARF info of the executable must be adjusted by it.
+func (p *Process) LoadBias() int64 {
+	return p.loadBias
+}
+
+// DebugFile returns the path of the file the DWARF and symbols were
+// read from, if not from the executable itself.
+func (p *Process) DebugFile() string {
+	return p.debugPath
+}
 
-	return allSyms, symErr
+// BuildID returns the build ID of the executable.
+func (p *Process) BuildID() BuildID {
+	return p.exeID
+}
+
+// CoreBuildID returns the build ID of the executable that dumped core,
+// as found in the executable's headers in the memory of the core.
+// It is zero if the core doesn't have them.
+func (p *Process) CoreBuildID() BuildID {
+	return p.coreID
 }
 
 func (p *Process) Warnings() []string {
 	return p.warnings
 }
 
+// Info returns the information about the process in the NT_PRPSINFO
+// note of the core. It returns nil if there is none.
+func (p *Process) Info() *ProcessInfo {
+	return p.info
+}
+
+// A ProcessInfo holds the information about a process that the kernel
+// records in the NT_PRPSINFO note of its core.
+type ProcessInfo struct {
+	State     uint8 // numeric state
+	StateName byte  // state as shown by ps: R, S, D, T, Z, ...
+	Zombie    bool
+	Nice      int8
+	Flags     uint64 // kernel flags of the process
+
+	UID, GID             uint32
+	PID, PPID, PGRP, SID int
+
+	Fname string // name of the executable, at most 15 bytes
+	Args  string // the first 80 bytes of the command line
+}
+
 // Args returns the initial part of the program arguments.
 func (p *Process) Args() string {
 	return p.args
@@ -764,3 +1013,68 @@ type linuxPrPsInfo struct {
 	Fname                [16]uint8 // filename of executables
 	Args                 [80]uint8 // first part of program args
 }
+
+// linuxPrPsInfo32 is the info embedded in NT_PRPSINFO on 386 and arm,
+// which have 16-bit user and group IDs.
+type linuxPrPsInfo32 struct {
+	State                uint8
+	Sname                int8
+	Zomb                 uint8
+	Nice                 int8
+	Flag                 uint32
+	Uid, Gid             uint16
+	Pid, Ppid, Pgrp, Sid int32
+	Fname                [16]uint8
+	Args                 [80]uint8
+}
+
+// linuxPrPsInfoMIPS is the info embedded in NT_PRPSINFO on 32-bit mips.
+type linuxPrPsInfoMIPS struct {
+	State                uint8
+	Sname                int8
+	Zomb                 uint8
+	Nice                 int8
+	Flag                 uint32
+	Uid, Gid             uint32
+	Pid, Ppid, Pgrp, Sid int32
+	Fname                [16]uint8
+	Args                 [80]uint8
+}
+
+// linuxPrStatus is the part of the NT_PRSTATUS info before the
+// registers, on 64-bit architectures.
+type linuxPrStatus struct {
+	Signo, Code, Errno           int32 // struct elf_siginfo
+	Cursig                       int16
+	_                            [2]uint8
+	Sigpend, Sighold             uint64
+	Pid, Ppid, Pgrp, Sid         int32
+	Utime, Stime, Cutime, Cstime linuxTimeval
+}
+
+// linuxPrStatus32 is the part of the NT_PRSTATUS info before the
+// registers, on 32-bit architectures.
+type linuxPrStatus32 struct {
+	Signo, Code, Errno           int32
+	Cursig                       int16
+	_                            [2]uint8
+	Sigpend, Sighold             uint32
+	Pid, Ppid, Pgrp, Sid         int32
+	Utime, Stime, Cutime, Cstime linuxTimeval32
+}
+
+type linuxTimeval struct {
+	Sec, Usec int64
+}
+
+func (t linuxTimeval) duration() time.Duration {
+	return time.Duration(t.Sec)*time.Second + time.Duration(t.Usec)*time.Microsecond
+}
+
+type linuxTimeval32 struct {
+	Sec, Usec int32
+}
+
+func (t linuxTimeval32) duration() time.Duration {
+	return time.Duration(t.Sec)*time.Second + time.Duration(t.Usec)*time.Microsecond
+}
@@ -9,7 +9,18 @@ import (
 	"fmt"
 )
 
-// All the Read* functions below will panic if something goes wrong.
+// A ReadError is the error the Read* functions panic with when the
+// inferior is not readable at the address requested.
+type ReadError struct {
+	Addr Address // the first unreadable address
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("address %x is not mapped in the core file", e.Addr)
+}
+
+// All the Read* functions below will panic with a *ReadError if the
+// address is not readable.
 
 // ReadAt reads len(b) bytes at address a in the inferior
 // and stores them in b.
@@ -17,7 +28,7 @@ func (p *Process) ReadAt(b []byte, a Address) {
 	for {
 		m := p.pageTable.findMapping(a)
 		if m == nil {
-			panic(fmt.Errorf("address %x is not mapped in the core file", a))
+			panic(&ReadError{Addr: a})
 		}
 		n := copy(b, m.contents[a.Sub(m.min):])
 		if n == len(b) {
@@ -33,7 +44,7 @@ func (p *Process) ReadAt(b []byte, a Address) {
 func (p *Process) ReadUint8(a Address) uint8 {
 	m := p.pageTable.findMapping(a)
 	if m == nil {
-		panic(fmt.Errorf("address %x is not mapped in the core file", a))
+		panic(&ReadError{Addr: a})
 	}
 	return m.contents[a.Sub(m.min)]
 }
@@ -42,7 +53,7 @@ func (p *Process) ReadUint8(a Address) uint8 {
 func (p *Process) ReadUint16(a Address) uint16 {
 	m := p.pageTable.findMapping(a)
 	if m == nil {
-		panic(fmt.Errorf("address %x is not mapped in the core file", a))
+		panic(&ReadError{Addr: a})
 	}
 	b := m.contents[a.Sub(m.min):]
 	if len(b) < 2 {
@@ -60,7 +71,7 @@ func (p *Process) ReadUint16(a Address) uint16 {
 func (p *Process) ReadUint32(a Address) uint32 {
 	m := p.pageTable.findMapping(a)
 	if m == nil {
-		panic(fmt.Errorf("address %x is not mapped in the core file", a))
+		panic(&ReadError{Addr: a})
 	}
 	b := m.contents[a.Sub(m.min):]
 	if len(b) < 4 {
@@ -78,7 +89,7 @@ func (p *Process) ReadUint32(a Address) uint32 {
 func (p *Process) ReadUint64(a Address) uint64 {
 	m := p.pageTable.findMapping(a)
 	if m == nil {
-		panic(fmt.Errorf("address %x is not mapped in the core file", a))
+		panic(&ReadError{Addr: a})
 	}
 	b := m.contents[a.Sub(m.min):]
 	if len(b) < 8 {
@@ -0,0 +1,248 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import "fmt"
+
+// A regLayout describes the general purpose registers of an
+// architecture, in the order the kernel saves them in NT_PRSTATUS.
+type regLayout struct {
+	names []string       // register names, as in the kernel's struct user_regs_struct
+	sizes []int          // register sizes in bytes, if not all of pointer size
+	dwarf map[int]string // DWARF register numbers, from the ABI supplement
+	pc    string         // program counter
+	sp    string         // stack pointer
+	fp    string         // frame pointer, if the architecture has one
+	lr    string         // link register, if the architecture has one
+}
+
+// regLayouts holds the register layouts of the architectures whose
+// NT_PRSTATUS notes are decoded.
+var regLayouts = map[string]*regLayout{
+	"amd64": {
+		names: []string{
+			"r15", "r14", "r13", "r12", "rbp", "rbx", "r11", "r10",
+			"r9", "r8", "rax", "rcx", "rdx", "rsi", "rdi", "orig_rax",
+			"rip", "cs", "eflags", "rsp", "ss", "fs_base", "gs_base", "ds",
+			"es", "fs", "gs",
+		},
+		dwarf: map[int]string{
+			0: "rax", 1: "rdx", 2: "rcx", 3: "rbx", 4: "rsi", 5: "rdi", 6: "rbp", 7: "rsp",
+			8: "r8", 9: "r9", 10: "r10", 11: "r11", 12: "r12", 13: "r13", 14: "r14", 15: "r15",
+			16: "rip", 49: "eflags", 50: "es", 51: "cs", 52: "ss", 53: "ds", 54: "fs", 55: "gs",
+			58: "fs_base", 59: "gs_base",
+		},
+		pc: "rip",
+		sp: "rsp",
+		fp: "rbp",
+	},
+	"386": {
+		names: []string{
+			"ebx", "ecx", "edx", "esi", "edi", "ebp", "eax", "ds",
+			"es", "fs", "gs", "orig_eax", "eip", "cs", "eflags", "esp",
+			"ss",
+		},
+		dwarf: map[int]string{
+			0: "eax", 1: "ecx", 2: "edx", 3: "ebx", 4: "esp", 5: "ebp", 6: "esi", 7: "edi",
+			8: "eip", 9: "eflags", 40: "es", 41: "cs", 42: "ss", 43: "ds", 44: "fs", 45: "gs",
+		},
+		pc: "eip",
+		sp: "esp",
+		fp: "ebp",
+	},
+	"arm": {
+		names: []string{
+			"r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7",
+			"r8", "r9", "r10", "r11", "r12", "sp", "lr", "pc",
+			"cpsr", "orig_r0",
+		},
+		dwarf: numbered(0, "r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7",
+			"r8", "r9", "r10", "r11", "r12", "sp", "lr", "pc"),
+		pc: "pc",
+		sp: "sp",
+		fp: "r11",
+		lr: "lr",
+	},
+	"arm64": {
+		names: append(regNames("x", 31), "sp", "pc", "pstate"),
+		dwarf: numbered(0, append(regNames("x", 31), "sp", "pc")...),
+		pc:    "pc",
+		sp:    "sp",
+		fp:    "x29",
+		lr:    "x30",
+	},
+	"mips":    mipsRegs,
+	"mipsle":  mipsRegs,
+	"ppc64":   ppc64Regs,
+	"ppc64le": ppc64Regs,
+	"s390x": {
+		// The access registers are 4 bytes.
+		names: append(append(append([]string{"pswm", "pswa"}, regNames("r", 16)...), regNames("a", 16)...), "orig_r2"),
+		sizes: append(append(repeat(8, 18), repeat(4, 16)...), 8),
+		dwarf: mergeNumbered(numbered(0, regNames("r", 16)...), numbered(48, regNames("a", 16)...), numbered(64, "pswm", "pswa")),
+		pc:    "pswa",
+		sp:    "r15",
+		fp:    "r11",
+		lr:    "r14",
+	},
+}
+
+// mipsRegs is the layout of the o32 ABI. The first six words of
+// the register set are unused.
+var mipsRegs = &regLayout{
+	names: append(append([]string{"pad0", "pad1", "pad2", "pad3", "pad4", "pad5"}, regNames("r", 32)...),
+		"lo", "hi", "epc", "badvaddr", "status", "cause", "pad6"),
+	dwarf: mergeNumbered(numbered(0, regNames("r", 32)...), numbered(64, "hi", "lo")),
+	pc:    "epc",
+	sp:    "r29",
+	fp:    "r30",
+	lr:    "r31",
+}
+
+// ppc64Regs is the layout of ppc64 and ppc64le. The last four words
+// of the register set are unused.
+var ppc64Regs = &regLayout{
+	names: append(regNames("r", 32),
+		"nip", "msr", "orig_r3", "ctr", "link", "xer", "ccr", "softe",
+		"trap", "dar", "dsisr", "result", "pad0", "pad1", "pad2", "pad3"),
+	dwarf: mergeNumbered(numbered(0, regNames("r", 32)...), map[int]string{65: "link", 66: "ctr", 76: "xer"}),
+	pc:    "nip",
+	sp:    "r1",
+	fp:    "r31",
+	lr:    "link",
+}
+
+// regNames returns the names prefix0 to prefix(n-1).
+func regNames(prefix string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return names
+}
+
+// numbered returns a map from first, first+1, ... to names.
+func numbered(first int, names ...string) map[int]string {
+	m := map[int]string{}
+	for i, name := range names {
+		m[first+i] = name
+	}
+	return m
+}
+
+// mergeNumbered returns the union of the maps.
+func mergeNumbered(maps ...map[int]string) map[int]string {
+	m := map[int]string{}
+	for _, mm := range maps {
+		for n, name := range mm {
+			m[n] = name
+		}
+	}
+	return m
+}
+
+// repeat returns a slice of n copies of size.
+func repeat(size, n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = size
+	}
+	return s
+}
+
+// readRegisters decodes the register set b of an NT_PRSTATUS note,
+// with the layout of the architecture. It returns nil if the layout
+// isn't known or b is too short.
+func readRegisters(meta metadata, b []byte) *Registers {
+	layout := regLayouts[meta.arch]
+	if layout == nil {
+		return nil
+	}
+	r := &Registers{layout: layout}
+	for i := range layout.names {
+		size := int(meta.ptrSize)
+		if layout.sizes != nil {
+			size = layout.sizes[i]
+		}
+		if len(b) < size {
+			return nil
+		}
+		switch size {
+		case 4:
+			r.values = append(r.values, uint64(meta.byteOrder.Uint32(b)))
+		case 8:
+			r.values = append(r.values, meta.byteOrder.Uint64(b))
+		}
+		b = b[size:]
+	}
+	return r
+}
+
+// Registers holds the general purpose registers of a thread.
+type Registers struct {
+	layout *regLayout
+	values []uint64 // in the order of layout.names
+}
+
+// Names returns the names of the registers, in the order the kernel
+// saves them.
+func (r *Registers) Names() []string {
+	return r.layout.names
+}
+
+// Get returns the value of the register with the given name, such as
+// "rbx", and whether there is such a register.
+func (r *Registers) Get(name string) (uint64, bool) {
+	for i, n := range r.layout.names {
+		if n == name && i < len(r.values) {
+			return r.values[i], true
+		}
+	}
+	return 0, false
+}
+
+// DWARF returns the value of the register with DWARF register number n,
+// as used in DWARF location expressions and call frame information,
+// and whether it is a general purpose register.
+func (r *Registers) DWARF(n int) (uint64, bool) {
+	name, ok := r.layout.dwarf[n]
+	if !ok {
+		return 0, false
+	}
+	return r.Get(name)
+}
+
+// DWARFName returns the name of the register with DWARF register number
+// n, or "" if it isn't a general purpose register.
+func (r *Registers) DWARFName(n int) string {
+	return r.layout.dwarf[n]
+}
+
+func (r *Registers) get(name string) Address {
+	v, _ := r.Get(name)
+	return Address(v)
+}
+
+// PC returns the program counter.
+func (r *Registers) PC() Address {
+	return r.get(r.layout.pc)
+}
+
+// SP returns the stack pointer.
+func (r *Registers) SP() Address {
+	return r.get(r.layout.sp)
+}
+
+// FP returns the frame pointer register. It is 0 on architectures
+// without one.
+func (r *Registers) FP() Address {
+	return r.get(r.layout.fp)
+}
+
+// LR returns the link register, which holds the return address in
+// leaf functions. It is 0 on architectures without one, like amd64.
+func (r *Registers) LR() Address {
+	return r.get(r.layout.lr)
+}
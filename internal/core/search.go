@@ -0,0 +1,57 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import "bytes"
+
+// Search calls fn with the address of each occurrence of pattern in the
+// readable memory of the inferior, in increasing address order, until
+// fn returns false. Only occurrences at multiples of align are
+// reported; an align of 0 or 1 reports them all. Occurrences may span
+// adjacent mappings. Occurrences may overlap.
+func (p *Process) Search(pattern []byte, align int, fn func(a Address) bool) {
+	if len(pattern) == 0 {
+		return
+	}
+	if align < 1 {
+		align = 1
+	}
+	// search reports the occurrences in b, which is at address a,
+	// starting before limit. It returns false if fn did.
+	search := func(b []byte, a Address, limit int) bool {
+		for i := 0; i < limit; {
+			j := bytes.Index(b[i:], pattern)
+			if j < 0 || i+j >= limit {
+				return true
+			}
+			i += j
+			if h := a.Add(int64(i)); int64(h)%int64(align) == 0 && !fn(h) {
+				return false
+			}
+			i++
+		}
+		return true
+	}
+	n := len(pattern) - 1
+	for _, m := range p.memory.mappings {
+		if m.perm&Read == 0 || m.contents == nil {
+			continue
+		}
+		if !search(m.contents, m.min, len(m.contents)) {
+			return
+		}
+		// Occurrences starting in the last n bytes of m and ending
+		// in the mapping after it, which the page table finds.
+		if n == 0 || len(m.contents) < n || !p.ReadableN(m.max, int64(n)) {
+			continue
+		}
+		b := make([]byte, 2*n)
+		copy(b, m.contents[len(m.contents)-n:])
+		p.ReadAt(b[n:], m.max)
+		if !search(b, m.max.Add(int64(-n)), n) {
+			return
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"debug/elf"
+	"fmt"
+)
+
+// _NT_SIGINFO is the type of the note with the siginfo_t of the signal
+// that made the process dump core.
+const _NT_SIGINFO elf.NType = 0x53494749
+
+// A SignalInfo describes the signal that made the process dump core.
+type SignalInfo struct {
+	Signo int // signal number
+	Code  int // why the signal was sent, such as SEGV_MAPERR or SI_TKILL
+	Errno int
+
+	// Addr is the faulting address, for signals raised by the
+	// processor: SIGSEGV, SIGBUS, SIGILL, SIGFPE and SIGTRAP.
+	Addr Address
+
+	// PID and UID identify the sender of signals sent by kill, tkill,
+	// tgkill, sigqueue or mq_notify.
+	PID int
+	UID uint32
+
+	// Thread is the thread that took the signal, or nil if its notes
+	// couldn't be decoded.
+	Thread *Thread
+
+	arch string
+}
+
+// Signal returns the signal that made the process dump core, from
+// the NT_SIGINFO note of the core. It returns nil if there is none.
+func (p *Process) Signal() *SignalInfo {
+	return p.signal
+}
+
+// Signal codes, from asm-generic/siginfo.h.
+const (
+	_SI_USER    = 0
+	_SI_KERNEL  = 0x80
+	_SI_QUEUE   = -1
+	_SI_TIMER   = -2
+	_SI_MESGQ   = -3
+	_SI_ASYNCIO = -4
+	_SI_SIGIO   = -5
+	_SI_TKILL   = -6
+)
+
+// Signal codes that differ on mips, from mips/include/uapi/asm/siginfo.h.
+const (
+	_SI_ASYNCIO_mips = -2
+	_SI_TIMER_mips   = -3
+	_SI_MESGQ_mips   = -4
+)
+
+// readSignal decodes the NT_SIGINFO note, which is among the notes of
+// the thread that took the signal. threads holds the thread of each
+// element of threadNotes, as returned by readThreads.
+func readSignal(meta metadata, threadNotes []noteMap, threads []*Thread) *SignalInfo {
+	for i, notes := range threadNotes {
+		if len(notes[_NT_SIGINFO]) == 0 {
+			continue
+		}
+		// typedef struct {
+		//	int si_signo;
+		//	int si_errno;	/* si_code on mips */
+		//	int si_code;	/* si_errno on mips */
+		//	union {		/* aligned to the size of a pointer */
+		//		struct { pid_t si_pid; uid_t si_uid; ... };
+		//		struct { void *si_addr; ... };
+		//		...
+		//	};
+		// } siginfo_t;
+		b := notes[_NT_SIGINFO][0]
+		if len(b) < 12+2*int(meta.ptrSize) {
+			return nil
+		}
+		word := func(off int) int {
+			return int(int32(meta.byteOrder.Uint32(b[off:])))
+		}
+		s := &SignalInfo{
+			Signo:  word(0),
+			Errno:  word(4),
+			Code:   word(8),
+			Thread: threads[i],
+			arch:   meta.arch,
+		}
+		if meta.arch == "mips" || meta.arch == "mipsle" {
+			s.Errno, s.Code = s.Code, s.Errno
+		}
+		union := 12
+		if meta.ptrSize == 8 {
+			union = 16
+		}
+		switch {
+		case s.sent():
+			s.PID = word(union)
+			s.UID = uint32(word(union + 4))
+		case s.Code != _SI_KERNEL && s.isFault():
+			if meta.ptrSize == 8 {
+				s.Addr = Address(meta.byteOrder.Uint64(b[union:]))
+			} else {
+				s.Addr = Address(meta.byteOrder.Uint32(b[union:]))
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// code returns the signal code, with the codes that differ on mips
+// mapped to their generic values.
+func (s *SignalInfo) code() int {
+	if s.arch == "mips" || s.arch == "mipsle" {
+		switch s.Code {
+		case _SI_ASYNCIO_mips:
+			return _SI_ASYNCIO
+		case _SI_TIMER_mips:
+			return _SI_TIMER
+		case _SI_MESGQ_mips:
+			return _SI_MESGQ
+		}
+	}
+	return s.Code
+}
+
+// sent reports whether the signal was sent by a process, by kill,
+// tkill, tgkill, sigqueue or mq_notify, in which case the union of the
+// siginfo_t holds the pid and uid of the sender.
+func (s *SignalInfo) sent() bool {
+	switch s.code() {
+	case _SI_USER, _SI_QUEUE, _SI_TKILL, _SI_MESGQ:
+		return true
+	}
+	return false
+}
+
+// isFault reports whether the signal is one raised by the processor,
+// with a faulting address.
+func (s *SignalInfo) isFault() bool {
+	switch s.Name() {
+	case "SIGSEGV", "SIGBUS", "SIGILL", "SIGFPE", "SIGTRAP":
+		return true
+	}
+	return false
+}
+
+// Name returns the name of the signal, such as "SIGSEGV".
+func (s *SignalInfo) Name() string {
+	names := signalNames
+	if s.arch == "mips" || s.arch == "mipsle" {
+		names = mipsSignalNames
+	}
+	if s.Signo > 0 && s.Signo < len(names) {
+		return names[s.Signo]
+	}
+	return fmt.Sprintf("signal %d", s.Signo)
+}
+
+// CodeName returns the name of the signal code, such as "SEGV_MAPERR"
+// or "SI_TKILL".
+func (s *SignalInfo) CodeName() string {
+	switch s.code() {
+	case _SI_USER:
+		return "SI_USER"
+	case _SI_KERNEL:
+		return "SI_KERNEL"
+	case _SI_QUEUE:
+		return "SI_QUEUE"
+	case _SI_TIMER:
+		return "SI_TIMER"
+	case _SI_MESGQ:
+		return "SI_MESGQ"
+	case _SI_ASYNCIO:
+		return "SI_ASYNCIO"
+	case _SI_SIGIO:
+		return "SI_SIGIO"
+	case _SI_TKILL:
+		return "SI_TKILL"
+	}
+	if codes := faultCodes[s.Name()]; s.Code > 0 && s.Code <= len(codes) {
+		return codes[s.Code-1]
+	}
+	return fmt.Sprintf("code %d", s.Code)
+}
+
+// String describes the signal, such as
+// "SIGSEGV (SEGV_MAPERR) at address 0x0" or
+// "SIGABRT (SI_TKILL) sent by pid 1234, uid 1000".
+func (s *SignalInfo) String() string {
+	str := fmt.Sprintf("%s (%s)", s.Name(), s.CodeName())
+	switch {
+	case s.sent() && s.PID != 0:
+		str += fmt.Sprintf(" sent by pid %d, uid %d", s.PID, s.UID)
+	case s.Code > 0 && s.Code != _SI_KERNEL && s.isFault():
+		str += fmt.Sprintf(" at address %#x", uint64(s.Addr))
+	}
+	return str
+}
+
+// signalNames are the names of signals on all Linux architectures but mips.
+var signalNames = []string{
+	1: "SIGHUP", 2: "SIGINT", 3: "SIGQUIT", 4: "SIGILL", 5: "SIGTRAP", 6: "SIGABRT", 7: "SIGBUS", 8: "SIGFPE",
+	9: "SIGKILL", 10: "SIGUSR1", 11: "SIGSEGV", 12: "SIGUSR2", 13: "SIGPIPE", 14: "SIGALRM", 15: "SIGTERM", 16: "SIGSTKFLT",
+	17: "SIGCHLD", 18: "SIGCONT", 19: "SIGSTOP", 20: "SIGTSTP", 21: "SIGTTIN", 22: "SIGTTOU", 23: "SIGURG", 24: "SIGXCPU",
+	25: "SIGXFSZ", 26: "SIGVTALRM", 27: "SIGPROF", 28: "SIGWINCH", 29: "SIGIO", 30: "SIGPWR", 31: "SIGSYS",
+}
+
+var mipsSignalNames = []string{
+	1: "SIGHUP", 2: "SIGINT", 3: "SIGQUIT", 4: "SIGILL", 5: "SIGTRAP", 6: "SIGABRT", 7: "SIGEMT", 8: "SIGFPE",
+	9: "SIGKILL", 10: "SIGBUS", 11: "SIGSEGV", 12: "SIGSYS", 13: "SIGPIPE", 14: "SIGALRM", 15: "SIGTERM", 16: "SIGUSR1",
+	17: "SIGUSR2", 18: "SIGCHLD", 19: "SIGPWR", 20: "SIGWINCH", 21: "SIGURG", 22: "SIGIO", 23: "SIGSTOP", 24: "SIGTSTP",
+	25: "SIGCONT", 26: "SIGTTIN", 27: "SIGTTOU", 28: "SIGVTALRM", 29: "SIGPROF", 30: "SIGXCPU", 31: "SIGXFSZ",
+}
+
+// faultCodes are the names of the codes of signals raised by the
+// processor, starting at 1.
+var faultCodes = map[string][]string{
+	"SIGSEGV": {"SEGV_MAPERR", "SEGV_ACCERR", "SEGV_BNDERR", "SEGV_PKUERR", "SEGV_ACCADI", "SEGV_ADIDERR", "SEGV_ADIPERR", "SEGV_MTEAERR", "SEGV_MTESERR", "SEGV_CPERR"},
+	"SIGBUS":  {"BUS_ADRALN", "BUS_ADRERR", "BUS_OBJERR", "BUS_MCEERR_AR", "BUS_MCEERR_AO"},
+	"SIGILL":  {"ILL_ILLOPC", "ILL_ILLOPN", "ILL_ILLADR", "ILL_ILLTRP", "ILL_PRVOPC", "ILL_PRVREG", "ILL_COPROC", "ILL_BADSTK", "ILL_BADIADDR"},
+	"SIGFPE":  {"FPE_INTDIV", "FPE_INTOVF", "FPE_FLTDIV", "FPE_FLTOVF", "FPE_FLTUND", "FPE_FLTRES", "FPE_FLTINV", "FPE_FLTSUB"},
+	"SIGTRAP": {"TRAP_BRKPT", "TRAP_TRACE", "TRAP_BRANCH", "TRAP_HWBKPT", "TRAP_UNK", "TRAP_PERF"},
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A Symbol is a symbol of one of the files mapped in the inferior.
+type Symbol struct {
+	Name    string
+	Addr    Address // address in the inferior
+	Size    int64   // 0 if unknown
+	Section string  // name of the section the symbol is in, such as ".text"
+	File    string  // path of the file the symbol is from
+	Func    bool    // whether the symbol is a function
+}
+
+// A symTable holds the symbols of one file, sorted by address.
+type symTable struct {
+	file     string
+	syms     []Symbol
+	min, max Address // extent of the symbols
+}
+
+// SymbolAt returns the symbol containing the address a, and the
+// offset of a in it. It returns nil if there is none. Symbols of
+// unknown size contain only their own address.
+func (p *Process) SymbolAt(a Address) (*Symbol, int64) {
+	for _, t := range p.symTabs {
+		if a < t.min || a > t.max {
+			continue
+		}
+		if s := t.find(a); s != nil {
+			return s, a.Sub(s.Addr)
+		}
+	}
+	return nil, 0
+}
+
+// find returns the symbol of t containing a, or nil.
+func (t *symTable) find(a Address) *Symbol {
+	// The symbols at or below a, nearest first.
+	i := sort.Search(len(t.syms), func(i int) bool { return t.syms[i].Addr > a })
+	for j := i - 1; j >= 0; j-- {
+		s := &t.syms[j]
+		if a < s.Addr.Add(s.Size) || a == s.Addr {
+			return s
+		}
+		if s.Addr != t.syms[i-1].Addr {
+			// Check the symbols at the nearest address, and the
+			// one below them, which may be a function with labels
+			// of unknown size in it.
+			break
+		}
+	}
+	return nil
+}
+
+// readSymbols reads the symbols of the files mapped in mem. Those of
+// exeFile are read from debugFile instead, if it isn't nil. It returns
+// the address of each symbol by name, and a table of the symbols of
+// each file for lookups by address. Shared libraries, which are usually
+// stripped, contribute their dynamic symbols.
+func readSymbols(mem *splicedMemory, coreFile, exeFile, debugFile *os.File) (map[string]Address, []*symTable, error) {
+	seen := map[*os.File]struct{}{
+		// Don't bother trying to read symbols from the core itself.
+		coreFile: struct{}{},
+	}
+
+	allSyms := make(map[string]Address)
+	undefined := map[string]bool{}
+	var tabs []*symTable
+	var symErr error
+
+	// Read symbols from all available files.
+	for _, m := range mem.mappings {
+		if m.f == nil {
+			continue
+		}
+		if _, ok := seen[m.f]; ok {
+			continue
+		}
+		seen[m.f] = struct{}{}
+
+		f := m.f
+		if f == exeFile && debugFile != nil {
+			f = debugFile
+		}
+		e, err := elf.NewFile(f)
+		if err != nil {
+			symErr = fmt.Errorf("can't read symbols from %s: %v", f.Name(), err)
+			continue
+		}
+
+		syms, err := e.Symbols()
+		dynSyms, dynErr := e.DynamicSymbols()
+		if err != nil && dynErr != nil {
+			symErr = fmt.Errorf("can't read symbols from %s: %v", f.Name(), err)
+			continue
+		}
+		bias := fileBias(m, e)
+		t := &symTable{file: m.f.Name()}
+		add := func(s elf.Symbol, dynamic bool) {
+			a := Address(s.Value)
+			if s.Section != elf.SHN_ABS && s.Section != elf.SHN_UNDEF {
+				a = a.Add(bias)
+			}
+			// Prefer symbols of the symbol tables to dynamic ones,
+			// and definitions to references.
+			if _, ok := allSyms[s.Name]; !ok || undefined[s.Name] || !dynamic && s.Section != elf.SHN_UNDEF {
+				allSyms[s.Name] = a
+				undefined[s.Name] = s.Section == elf.SHN_UNDEF
+			}
+			switch elf.ST_TYPE(s.Info) {
+			case elf.STT_FUNC, elf.STT_OBJECT, elf.STT_NOTYPE, elf.STT_GNU_IFUNC:
+			default:
+				return
+			}
+			if s.Name == "" || s.Section == elf.SHN_UNDEF || s.Section >= elf.SHN_LORESERVE || int(s.Section) >= len(e.Sections) {
+				return
+			}
+			t.syms = append(t.syms, Symbol{
+				Name:    s.Name,
+				Addr:    a,
+				Size:    int64(s.Size),
+				Section: e.Sections[s.Section].Name,
+				File:    t.file,
+				Func:    elf.ST_TYPE(s.Info) == elf.STT_FUNC || elf.ST_TYPE(s.Info) == elf.STT_GNU_IFUNC,
+			})
+		}
+		for _, s := range syms {
+			add(s, false)
+		}
+		for _, s := range dynSyms {
+			add(s, true)
+		}
+		if len(t.syms) == 0 {
+			continue
+		}
+
+		// Sort by address, then by name, and drop the dynamic
+		// symbols that are also in the symbol table.
+		sort.Slice(t.syms, func(i, j int) bool {
+			si, sj := &t.syms[i], &t.syms[j]
+			if si.Addr != sj.Addr {
+				return si.Addr < sj.Addr
+			}
+			return si.Name < sj.Name
+		})
+		n := 0
+		for _, s := range t.syms {
+			if n > 0 && t.syms[n-1].Addr == s.Addr && t.syms[n-1].Name == s.Name {
+				continue
+			}
+			t.syms[n] = s
+			n++
+		}
+		t.syms = t.syms[:n]
+		t.min = t.syms[0].Addr
+		for _, s := range t.syms {
+			if end := s.Addr.Add(s.Size); end > t.max {
+				t.max = end
+			}
+		}
+		tabs = append(tabs, t)
+	}
+
+	return allSyms, tabs, symErr
+}
@@ -4,12 +4,21 @@
 
 package core
 
+import "time"
+
 // A Thread represents an operating system thread.
 type Thread struct {
 	pid  uint64   // thread/process ID
 	regs []uint64 // set depends on arch
 	pc   Address  // program counter
 	sp   Address  // stack pointer
+
+	registers *Registers // named general purpose registers, if known
+	fpregs    *FPRegs    // floating point registers, if known
+
+	signal              int           // current signal
+	sigPending, sigHeld uint64        // signal masks
+	userTime, sysTime   time.Duration // CPU time used
 }
 
 func (t *Thread) Pid() uint64 {
@@ -17,13 +26,28 @@ func (t *Thread) Pid() uint64 {
 }
 
 // Regs returns the set of register values for the thread.
-// What registers go where is architecture-dependent.
-// TODO: document for each architecture.
-// TODO: do this in some arch-independent way?
+// What registers go where is architecture-dependent: they are in the
+// order of Registers().Names().
 func (t *Thread) Regs() []uint64 {
 	return t.regs
 }
 
+// Registers returns the general purpose registers of the thread, by
+// name. It returns nil if the registers of the architecture aren't
+// decoded.
+func (t *Thread) Registers() *Registers {
+	return t.registers
+}
+
+// Reg returns the value of the register with the given name, such as
+// "rbx" on amd64, and whether the thread has such a register.
+func (t *Thread) Reg(name string) (uint64, bool) {
+	if t.registers == nil {
+		return 0, false
+	}
+	return t.registers.Get(name)
+}
+
 func (t *Thread) PC() Address {
 	return t.pc
 }
@@ -32,4 +56,37 @@ func (t *Thread) SP() Address {
 	return t.sp
 }
 
-// TODO: link register?
+// FPRegs returns the floating point and vector registers of the
+// thread. It returns nil if the core doesn't have them, or they aren't
+// decoded for the architecture (only 386, amd64 and arm64 are).
+func (t *Thread) FPRegs() *FPRegs {
+	return t.fpregs
+}
+
+// Signal returns the number of the signal the thread was handling
+// when the process dumped core, or 0.
+func (t *Thread) Signal() int {
+	return t.signal
+}
+
+// PendingSignals returns the set of signals pending for the thread,
+// with bit n-1 set for signal n.
+func (t *Thread) PendingSignals() uint64 {
+	return t.sigPending
+}
+
+// HeldSignals returns the set of signals blocked by the thread, with
+// bit n-1 set for signal n.
+func (t *Thread) HeldSignals() uint64 {
+	return t.sigHeld
+}
+
+// UserTime returns the CPU time the thread spent in user mode.
+func (t *Thread) UserTime() time.Duration {
+	return t.userTime
+}
+
+// SystemTime returns the CPU time the thread spent in the kernel.
+func (t *Thread) SystemTime() time.Duration {
+	return t.sysTime
+}
@@ -0,0 +1,260 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"sort"
+	"time"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// A Context is a context.Context implementation found in the Go heap.
+type Context struct {
+	Object Object
+	Type   *Type // context.cancelCtx, context.timerCtx, ...
+
+	// Parent is the context this one was derived from, or nil if the
+	// parent is not in the heap (context.Background, context.TODO, or a
+	// user-defined implementation). In that case ParentType holds the
+	// dynamic type of the parent, if known.
+	Parent     *Context
+	ParentType *Type
+	Children   []*Context
+
+	// Canceled reports whether the context has been canceled.
+	// Contexts that can't be canceled themselves (like those made
+	// by context.WithValue) report the state of their parent.
+	Canceled bool
+
+	// Deadline is the deadline of a context made by
+	// context.WithDeadline or context.WithTimeout. It is the zero
+	// time for all other contexts.
+	Deadline time.Time
+
+	// KeyType and ValueType are the dynamic types of the key and
+	// value stored by context.WithValue, if any.
+	KeyType, ValueType *Type
+
+	// Retained is the number of bytes retained by the subtree
+	// rooted at this context: the contexts themselves plus all
+	// objects reachable only through them.
+	Retained int64
+}
+
+// contextTypes is the set of context package types that implement
+// context.Context and refer to a parent context.
+var contextTypes = map[string]bool{
+	"context.cancelCtx":        true,
+	"context.timerCtx":         true,
+	"context.valueCtx":         true,
+	"context.afterFuncCtx":     true,
+	"context.stopCtx":          true,
+	"context.withoutCancelCtx": true,
+}
+
+// Contexts returns all the context.Context implementations from the
+// context package found in the heap, sorted by address.
+// The parent/child links between them form a forest; contexts with
+// a nil Parent are the roots.
+func (p *Process) Contexts() []*Context {
+	p.initContexts.Do(p.readContexts)
+	return p.contexts
+}
+
+func (p *Process) readContexts() {
+	byAddr := map[core.Address]*Context{}
+
+	// In go 1.19 and 1.20, a timerCtx points to its cancelCtx instead of
+	// embedding it. Those cancelCtx objects are part of their timerCtx,
+	// not contexts of their own.
+	embedded := map[core.Address]bool{}
+	var found []Object
+	p.ForEachObject(func(x Object) bool {
+		t, r := p.Type(x)
+		if t != nil && r == 1 && contextTypes[t.Name] {
+			found = append(found, x)
+			if f := t.field("cancelCtx"); f != nil && f.Type.Kind == KindPtr {
+				embedded[p.proc.ReadPtr(p.Addr(x).Add(f.Off))] = true
+			}
+		}
+		return true
+	})
+
+	// lookup returns the Context for the object of type t at address a,
+	// creating it if needed. It returns nil if a is not the start of a
+	// live heap object.
+	lookup := func(a core.Address, t *Type) *Context {
+		if c := byAddr[a]; c != nil {
+			return c
+		}
+		if embedded[a] {
+			return nil
+		}
+		x, off := p.FindObject(a)
+		if x == 0 || off != 0 {
+			return nil
+		}
+		c := &Context{Object: x, Type: t}
+		byAddr[a] = c
+		p.contexts = append(p.contexts, c)
+		return c
+	}
+
+	// ctxPointer returns the Context referenced by the interface at a,
+	// along with the dynamic type of the interface.
+	ctxPointer := func(t *Type, a core.Address) (*Context, *Type) {
+		dt := p.DynamicType(t, a)
+		if dt == nil {
+			return nil, nil
+		}
+		if dt.Kind != KindPtr || dt.Elem == nil || !contextTypes[dt.Elem.Name] {
+			return nil, dt
+		}
+		return lookup(p.proc.ReadPtr(a.Add(p.proc.PtrSize())), dt.Elem), dt
+	}
+
+	for _, x := range found {
+		t, _ := p.Type(x)
+		lookup(p.Addr(x), t)
+	}
+
+	// cancelCtx returns the cancelCtx embedded in, or pointed to by, r.
+	cancelCtx := func(r region) region {
+		c := r.Field("cancelCtx")
+		if c.typ.Kind == KindPtr {
+			return c.Deref()
+		}
+		return c
+	}
+
+	link := func(parent, child *Context) {
+		child.Parent = parent
+		parent.Children = append(parent.Children, child)
+	}
+
+	// Note: lookup may append to p.contexts while we iterate.
+	for i := 0; i < len(p.contexts); i++ {
+		c := p.contexts[i]
+		r := region{p: p, a: p.Addr(c.Object), typ: c.Type}
+
+		// Find the parent through the embedded Context.
+		var parent region
+		switch {
+		case r.HasField("Context"):
+			parent = r.Field("Context")
+		case r.HasField("cancelCtx"):
+			parent = cancelCtx(r).Field("Context")
+		case r.HasField("c"):
+			parent = r.Field("c")
+		}
+		if parent.typ != nil {
+			pc, pt := ctxPointer(parent.typ, parent.a)
+			if pc != nil {
+				link(pc, c)
+			} else {
+				c.ParentType = pt
+			}
+		}
+
+		// Decode the type-specific state.
+		cr := r
+		if r.HasField("cancelCtx") {
+			cr = cancelCtx(r)
+		}
+		if cr.HasField("err") {
+			err := cr.Field("err")
+			if err.IsStruct() && err.HasField("v") { // atomic.Value, go1.23+
+				err = err.Field("v")
+			}
+			c.Canceled = p.proc.ReadPtr(err.a) != 0
+		}
+		if cr.HasField("children") {
+			if h, ok := p.mapHeaderAt(cr.Field("children").typ, cr.Field("children").a); ok {
+				// The children registered for cancelation are
+				// derived from c or from its descendants. Only
+				// look them up, so that they are found even if
+				// their type is unknown; their parent is the
+				// context they embed.
+				p.forEachMapEntry(h, func(k, _ region) bool {
+					ctxPointer(k.typ, k.a)
+					return true
+				})
+			}
+		}
+		if r.HasField("deadline") {
+			c.Deadline = readTime(r.Field("deadline"))
+		}
+		if r.HasField("key") {
+			c.KeyType = p.DynamicType(r.Field("key").typ, r.Field("key").a)
+			c.ValueType = p.DynamicType(r.Field("val").typ, r.Field("val").a)
+		}
+	}
+
+	sort.Slice(p.contexts, func(i, j int) bool {
+		return p.contexts[i].Object < p.contexts[j].Object
+	})
+
+	// Propagate cancelation to contexts that can't be canceled on their
+	// own, and compute retained sizes, walking down from the roots.
+	d := p.dominatorTree()
+	var walk func(c *Context, canceled bool, ancestors []vName) int64
+	walk = func(c *Context, canceled bool, ancestors []vName) int64 {
+		switch c.Type.Name {
+		case "context.valueCtx", "context.stopCtx":
+			c.Canceled = canceled
+		}
+		v := d.vertex(c.Object)
+		var size int64
+		dominated := false
+		for _, a := range ancestors {
+			if d.dominates(a, v) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			// Not already counted as part of an ancestor.
+			size = d.size[v]
+		}
+		ancestors = append(ancestors, v)
+		for _, child := range c.Children {
+			size += walk(child, c.Canceled, ancestors)
+		}
+		c.Retained = size
+		return size
+	}
+	for _, c := range p.contexts {
+		if c.Parent == nil {
+			walk(c, false, nil)
+		}
+	}
+}
+
+// readTime decodes the time.Time stored in r.
+func readTime(r region) time.Time {
+	const (
+		hasMonotonic = 1 << 63
+		nsecMask     = 1<<30 - 1
+		nsecShift    = 30
+
+		secondsPerDay  = 86400
+		wallToInternal = (1884*365 + 1884/4 - 1884/100 + 1884/400) * secondsPerDay
+		unixToInternal = (1969*365 + 1969/4 - 1969/100 + 1969/400) * secondsPerDay
+	)
+	wall := r.Field("wall").Uint64()
+	ext := r.p.proc.ReadInt64(r.Field("ext").a)
+	nsec := int64(wall & nsecMask)
+	var sec int64
+	if wall&hasMonotonic != 0 {
+		sec = wallToInternal + int64(wall<<1>>(nsecShift+1))
+	} else {
+		sec = ext
+	}
+	if sec == 0 && nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec-unixToInternal, nsec).UTC()
+}
@@ -143,6 +143,7 @@ func (d *ltDom) initialize() {
 	}
 
 	n := vNumber(1) // 0 was the pseudo-root.
+	orphan := 0     // objects below this index have been numbered
 
 	// Build the spanning tree, assigning vertex numbers to each object
 	// and initializing semi and parent.
@@ -172,6 +173,19 @@ func (d *ltDom) initialize() {
 			d.p.ForEachPtr(object, visitChild)
 		}
 
+		if len(work) == 0 {
+			// Objects are marked live from the roots the runtime
+			// uses, which DWARF doesn't always describe. Make
+			// any object not reached from the DWARF roots a child
+			// of the pseudo-root, so that every object is numbered.
+			for ; orphan < len(d.objs); orphan++ {
+				name := vName(orphan + d.nRoots + 1)
+				if d.semis[name] == -1 {
+					work = append(work, workItem{name: name, parentName: pseudoRoot})
+					break
+				}
+			}
+		}
 	}
 }
 
@@ -219,7 +233,7 @@ func (d *ltDom) calculate() {
 		// Step 2. Compute the semidominators of all nodes.
 		root, obj := d.findVertexByName(w)
 		// This loop never visits the pseudo-root.
-		if root != nil {
+		if root != nil || d.parents[w] == pseudoRoot {
 			u := d.eval(pseudoRoot)
 			if d.semis[u] < d.semis[w] {
 				d.semis[w] = d.semis[u]
@@ -383,6 +397,44 @@ func (d *dominators) calcSize(p *Process) {
 	}
 }
 
+// vertex returns the vertex name of object x.
+func (d *dominators) vertex(x Object) vName {
+	idx, _ := d.p.findObjectIndex(d.p.Addr(x))
+	return vName(idx + len(d.p.rootIdx) + 1)
+}
+
+// dominates reports whether every path from the roots to v passes through u.
+func (d *dominators) dominates(u, v vName) bool {
+	for v != pseudoRoot {
+		v = d.idom[v]
+		if v == u {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Process) dominatorTree() *dominators {
+	p.initDominators.Do(func() {
+		p.dominators = p.calculateDominators()
+	})
+	return p.dominators
+}
+
+// Retained returns the number of bytes retained by x: the size of x plus
+// the sizes of all objects that are reachable only through x.
+func (p *Process) Retained(x Object) int64 {
+	d := p.dominatorTree()
+	return d.size[d.vertex(x)]
+}
+
+// RootRetained returns the number of bytes retained by the root r: the
+// size of r plus the sizes of all objects that are reachable only through r.
+func (p *Process) RootRetained(r *Root) int64 {
+	d := p.dominatorTree()
+	return d.size[p.findRootIndex(r)+1]
+}
+
 func (d *ltDom) dot(w io.Writer) {
 	fmt.Fprintf(w, "digraph %s {\nrankdir=\"LR\"\n", "dominators")
 	for number, name := range d.vertices {
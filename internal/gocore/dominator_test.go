@@ -198,3 +198,100 @@ func (v *sanityVertex) String(p *Process) string {
 		return "pseudo-root"
 	}
 }
+
+func TestRetained(t *testing.T) {
+	for _, p := range []*Process{loadExample(t), loadExampleVersion(t, "1.20-context.zip")} {
+		p.ForEachObject(func(x Object) bool {
+			if r, s := p.Retained(x), p.Size(x); r < s {
+				t.Errorf("retained(%x)=%d, less than its size %d", p.Addr(x), r, s)
+			}
+			return true
+		})
+		var total int64
+		p.ForEachRoot(func(r *Root) bool {
+			total += p.RootRetained(r)
+			return true
+		})
+		if max := p.dominatorTree().size[pseudoRoot]; total > max {
+			t.Errorf("sum of root retained sizes = %d, more than the whole heap graph (%d)", total, max)
+		}
+	}
+}
+
+// TestRetainedMap checks the sizes retained by the global map
+// main.contexts of testdata/context/test.go, which nothing else refers to.
+func TestRetainedMap(t *testing.T) {
+	p := loadExampleVersion(t, "1.20-context.zip")
+	var global *Root
+	for _, r := range p.Globals() {
+		if r.Name == "main.contexts" {
+			global = r
+		}
+	}
+	if global == nil {
+		t.Fatalf("no global main.contexts")
+	}
+	h, ok := p.mapHeaderAt(global.Type, global.Addr)
+	if !ok {
+		t.Fatalf("main.contexts is a nil map")
+	}
+	hdr, _ := p.FindObject(h.a)
+	buckets, _ := p.FindObject(h.Field("buckets").Address())
+	if hdr == 0 || buckets == 0 {
+		t.Fatalf("map header or buckets not in the heap")
+	}
+	d := p.dominatorTree()
+	if !d.dominates(d.vertex(hdr), d.vertex(buckets)) {
+		t.Errorf("map header doesn't dominate its buckets")
+	}
+	if r, want := p.Retained(hdr), p.Size(hdr)+p.Size(buckets); r < want {
+		t.Errorf("retained(header)=%d, want at least %d", r, want)
+	}
+	if r, want := p.RootRetained(global), global.Type.Size+p.Retained(hdr); r != want {
+		t.Errorf("RootRetained(main.contexts)=%d, want %d", r, want)
+	}
+}
+
+// TestDominatorOrphans checks that objects marked live from the
+// runtime's roots, but not reachable from the roots DWARF describes,
+// become children of the pseudo-root. testdata/1.20-context.zip has one.
+func TestDominatorOrphans(t *testing.T) {
+	p := loadExampleVersion(t, "1.20-context.zip")
+	reached := map[Object]bool{}
+	var q []Object
+	visit := func(_ int64, y Object, _ int64) bool {
+		if !reached[y] {
+			reached[y] = true
+			q = append(q, y)
+		}
+		return true
+	}
+	p.ForEachRoot(func(r *Root) bool {
+		p.ForEachRootPtr(r, visit)
+		return true
+	})
+	for len(q) > 0 {
+		x := q[len(q)-1]
+		q = q[:len(q)-1]
+		p.ForEachPtr(x, visit)
+	}
+
+	d := p.dominatorTree()
+	orphans := 0
+	p.ForEachObject(func(x Object) bool {
+		if reached[x] {
+			return true
+		}
+		orphans++
+		if v := d.vertex(x); d.idom[v] != pseudoRoot {
+			t.Errorf("orphan %x is dominated by %v, not the pseudo-root", p.Addr(x), d.idom[v])
+		}
+		if r, s := p.Retained(x), p.Size(x); r < s {
+			t.Errorf("retained(orphan %x)=%d, less than its size %d", p.Addr(x), r, s)
+		}
+		return true
+	})
+	if orphans == 0 {
+		t.Fatal("no orphan objects in the core")
+	}
+}
@@ -378,6 +378,7 @@ func (p *Process) readRuntimeConstants() {
 	m["_Gsyscall"] = 3
 	m["_Gwaiting"] = 4
 	m["_Gdead"] = 6
+	m["_Gcopystack"] = 8
 	m["_Gscan"] = 0x1000
 	m["_PCDATA_StackMapIndex"] = 0
 	m["_FUNCDATA_LocalsPointerMaps"] = 1
@@ -387,6 +388,8 @@ func (p *Process) readRuntimeConstants() {
 	m["kindDirectIface"] = 1 << 5
 	m["_PageSize"] = 1 << 13
 	m["_KindSpecialFinalizer"] = 1
+	m["minTopHash"] = 4
+	m["sameSizeGrow"] = 8
 
 	// From 1.10, these constants are recorded in DWARF records.
 	d, _ := p.proc.DWARF()
@@ -449,6 +452,8 @@ func (p *Process) readGlobals() {
 		} else {
 			a = core.Address(p.proc.ByteOrder().Uint32(loc[1:]))
 		}
+		// DWARF has link-time addresses.
+		a = a.Add(p.proc.LoadBias())
 		if !p.proc.Writeable(a) {
 			// Read-only globals can't have heap pointers.
 			// TODO: keep roots around anyway?
@@ -500,8 +505,8 @@ func (p *Process) readStackVars() {
 			if lowpc == nil || highpc == nil {
 				continue
 			}
-			min := core.Address(lowpc.Val.(uint64))
-			max := core.Address(highpc.Val.(uint64))
+			min := core.Address(lowpc.Val.(uint64)).Add(p.proc.LoadBias())
+			max := core.Address(highpc.Val.(uint64)).Add(p.proc.LoadBias())
 			f := p.funcTab.find(min)
 			if f == nil {
 				// some func Go doesn't know about. C?
@@ -17,8 +17,10 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/debug/internal/core"
 	"golang.org/x/debug/internal/testenv"
@@ -500,3 +502,339 @@ func TestRuntimeTypes(t *testing.T) {
 		}
 	}
 }
+
+// TestContexts checks the context tree of testdata/context/test.go.
+// The program keeps each context in the global map main.contexts.
+func TestContexts(t *testing.T) {
+	p := loadExampleVersion(t, "1.20-context.zip")
+	var global *Root
+	for _, r := range p.Globals() {
+		if r.Name == "main.contexts" {
+			global = r
+		}
+	}
+	if global == nil {
+		t.Fatalf("no global main.contexts")
+	}
+	h, ok := p.mapHeaderAt(global.Type, global.Addr)
+	if !ok {
+		t.Fatalf("main.contexts is a nil map")
+	}
+	if n := mapLen(h); n != 5 {
+		t.Fatalf("len(main.contexts) = %d, want 5", n)
+	}
+	byAddr := map[core.Address]*Context{}
+	for _, c := range p.Contexts() {
+		byAddr[p.Addr(c.Object)] = c
+	}
+	ctx := map[string]*Context{}
+	p.forEachMapEntry(h, func(k, v region) bool {
+		// The data word of the context.Context interface.
+		a := p.proc.ReadPtr(v.a.Add(p.proc.PtrSize()))
+		if ctx[k.String()] = byAddr[a]; ctx[k.String()] == nil {
+			t.Errorf("main.contexts[%q] = %x, not a context", k.String(), a)
+		}
+		return true
+	})
+	if t.Failed() {
+		t.FailNow()
+	}
+	// The cancelCtx a timerCtx points to is not a context of its own.
+	if n := len(p.Contexts()); n != len(ctx) {
+		t.Errorf("got %d contexts, want %d", n, len(ctx))
+	}
+
+	for _, c := range []struct {
+		name, typ, parent string
+		children          []string
+		canceled          bool
+		valueType         string
+	}{
+		{name: "root", typ: "context.cancelCtx", children: []string{"value", "canceled"}},
+		{name: "value", typ: "context.valueCtx", parent: "root", children: []string{"deadline"}, valueType: "string"},
+		{name: "deadline", typ: "context.timerCtx", parent: "value"},
+		{name: "canceled", typ: "context.cancelCtx", parent: "root", children: []string{"canceledValue"}, canceled: true},
+		{name: "canceledValue", typ: "context.valueCtx", parent: "canceled", canceled: true, valueType: "int"},
+	} {
+		x := ctx[c.name]
+		if x.Type.Name != c.typ {
+			t.Errorf("%s: type %s, want %s", c.name, x.Type.Name, c.typ)
+		}
+		if x.Parent != ctx[c.parent] {
+			t.Errorf("%s: wrong parent", c.name)
+		}
+		var children []string
+		for _, y := range x.Children {
+			for name, z := range ctx {
+				if y == z {
+					children = append(children, name)
+				}
+			}
+		}
+		sort.Strings(children)
+		sort.Strings(c.children)
+		if strings.Join(children, " ") != strings.Join(c.children, " ") {
+			t.Errorf("%s: children %v, want %v", c.name, children, c.children)
+		}
+		if x.Canceled != c.canceled {
+			t.Errorf("%s: canceled %v, want %v", c.name, x.Canceled, c.canceled)
+		}
+		if c.valueType != "" {
+			if x.KeyType == nil || x.KeyType.Name != "main.key" || x.ValueType == nil || x.ValueType.Name != c.valueType {
+				t.Errorf("%s: key and value types %v, %v, want main.key, %s", c.name, x.KeyType, x.ValueType, c.valueType)
+			}
+		}
+		// All the contexts are referenced from main.contexts, so none
+		// retains another: each retains what it alone retains, plus
+		// what its children do.
+		want := p.Retained(x.Object)
+		for _, y := range x.Children {
+			want += y.Retained
+		}
+		if x.Retained != want {
+			t.Errorf("%s: retained %d, want %d", c.name, x.Retained, want)
+		}
+	}
+	if pt := ctx["root"].ParentType; pt == nil || pt.Name != "*context.emptyCtx" {
+		t.Errorf("root: parent type %v, want *context.emptyCtx", pt)
+	}
+	want := time.Date(2030, 1, 2, 3, 4, 5, 6, time.UTC)
+	if d := ctx["deadline"].Deadline; !d.Equal(want) {
+		t.Errorf("deadline: %v, want %v", d, want)
+	}
+	if d := ctx["value"].Deadline; !d.IsZero() {
+		t.Errorf("value: deadline %v, want none", d)
+	}
+	// The timerCtx retains the cancelCtx it points to.
+	if r, s := ctx["deadline"].Retained, p.Size(ctx["deadline"].Object); r <= s {
+		t.Errorf("deadline: retained %d, want more than its size %d", r, s)
+	}
+}
+
+func TestGoroutineStates(t *testing.T) {
+	p := loadExample(t)
+	if w := p.Warnings(); len(w) != 0 {
+		t.Errorf("got warnings %q, want none", w)
+	}
+	states := map[string]int{}
+	for _, g := range p.Goroutines() {
+		states[g.State()]++
+	}
+	// The example program crashed on its main goroutine.
+	if states["running"] != 1 {
+		t.Errorf("got goroutine states %v, want one running goroutine", states)
+	}
+	for s := range states {
+		if strings.HasPrefix(s, "unknown") {
+			t.Errorf("got goroutine state %q", s)
+		}
+	}
+}
+
+// Test386 checks a core of a 32-bit program, from linux/386.
+func Test386(t *testing.T) {
+	p := loadExampleVersion(t, "1.21-386.zip")
+	if w := p.Warnings(); len(w) != 0 {
+		t.Errorf("got warnings %q, want none", w)
+	}
+	if arch := p.Process().Arch(); arch != "386" {
+		t.Fatalf("got arch %s, want 386", arch)
+	}
+
+	// The executable's mappings come from the NT_FILE note.
+	found := false
+	for _, m := range p.Process().Mappings() {
+		if f, _ := m.Source(); strings.HasSuffix(f, "/tmp/coretest/test") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no mapping of the executable")
+	}
+
+	// The main goroutine crashed in main.main, and the backtrace goes
+	// through the signal handler.
+	var frames []string
+	for _, g := range p.Goroutines() {
+		if g.State() != "running" {
+			continue
+		}
+		for _, f := range g.Frames() {
+			frames = append(frames, f.Func().Name())
+		}
+	}
+	if len(frames) == 0 || frames[len(frames)-1] != "runtime.main" || frames[len(frames)-2] != "main.main" {
+		t.Errorf("got frames %v of the running goroutine, want main.main called by runtime.main last", frames)
+	}
+
+	n := 0
+	p.ForEachObject(func(x Object) bool {
+		n++
+		return true
+	})
+	if n == 0 {
+		t.Errorf("no objects")
+	}
+}
+
+// TestPIE checks a core of a position-independent executable, which was
+// loaded at an address chosen at run time.
+func TestPIE(t *testing.T) {
+	p := loadExampleVersion(t, "1.20-pie.zip")
+	if b := p.Process().LoadBias(); b == 0 {
+		t.Errorf("got load bias 0, want the executable relocated")
+	}
+
+	// The global main.list points to a list of three heap objects.
+	var list *Root
+	for _, r := range p.Globals() {
+		if r.Name == "main.list" {
+			list = r
+		}
+	}
+	if list == nil {
+		t.Fatalf("no global main.list")
+	}
+	var names []string
+	for a := p.Process().ReadPtr(list.Addr); a != 0; {
+		x, off := p.FindObject(a)
+		if x == 0 || off != 0 {
+			t.Fatalf("main.list points to %x, which isn't a heap object", a)
+		}
+		typ, _ := p.Type(x)
+		if typ == nil || typ.Name != "main.T" {
+			t.Fatalf("object %x has type %v, want main.T", a, typ)
+		}
+		r := region{p: p, a: a, typ: typ}
+		names = append(names, r.Field("name").String())
+		a = r.Field("next").Address()
+	}
+	if got, want := strings.Join(names, " "), "t2 t1 t0"; got != want {
+		t.Errorf("got list %s, want %s", got, want)
+	}
+
+	// A goroutine is blocked receiving in main.block, called by the
+	// wrapper of the go statement.
+	want := []string{"runtime.gopark", "runtime.chanrecv", "runtime.chanrecv1", "main.block", "main.main.func1"}
+	found := false
+	for _, g := range p.Goroutines() {
+		var frames []string
+		for _, f := range g.Frames() {
+			frames = append(frames, f.Func().Name())
+		}
+		if reflect.DeepEqual(frames, want) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no goroutine with frames %v", want)
+	}
+}
+
+// TestCgoFrames checks that the backtrace of a goroutine in a cgo call
+// goes through the C functions it called, found by their symbols and
+// frame pointers.
+func TestCgoFrames(t *testing.T) {
+	p := loadExampleVersion(t, "1.20-cgo.zip")
+
+	// main.main called level1, which called crash, which faulted. The
+	// runtime's handler made the fault a call to sigpanic, which
+	// crashed the program with SIGABRT.
+	var frames []string
+	for _, g := range p.Goroutines() {
+		if g.ID() != 1 {
+			continue
+		}
+		for _, f := range g.Frames() {
+			name := f.Func().Name()
+			if strings.HasPrefix(name, "_cgo_") {
+				name = "_cgo_" // the cgo wrapper, named after a hash
+			}
+			frames = append(frames, name)
+		}
+	}
+	want := []string{"runtime.sigpanic", "crash", "level1", "_cgo_", "runtime.asmcgocall", "runtime.cgocall", "main._Cfunc_level1", "main.main", "runtime.main"}
+	if len(frames) < len(want) || !reflect.DeepEqual(frames[len(frames)-len(want):], want) {
+		t.Errorf("got frames %v of the main goroutine, want them to end with %v", frames, want)
+	}
+}
+
+// TestTruncated makes sure a core file cut off while it was dumped
+// loads, reports its missing mappings, and that the objects, roots and
+// goroutines that remain can be looked at without reading the missing
+// data.
+func TestTruncated(t *testing.T) {
+	b, err := os.ReadFile("testdata/core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		size int   // of the truncated file
+		min  int64 // of the first missing mapping
+	}{
+		// In the middle of the main thread's stack, which starts
+		// at 0x1cf000 in the file.
+		{"stack", 0x1cf000 + 0x10800, 0x7fffe9aba000},
+		// In the middle of the heap arena, which starts at 0x27000.
+		{"heap", 0x27000 + 0x40000, 0xc420038000},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "core")
+			if err := os.WriteFile(path, b[:test.size], 0666); err != nil {
+				t.Fatal(err)
+			}
+			c, err := core.Core(path, "testdata", "")
+			if err != nil {
+				t.Fatalf("can't load truncated core file: %v", err)
+			}
+			p, err := Core(c)
+			if err != nil {
+				t.Fatalf("can't parse truncated Go core: %v", err)
+			}
+
+			var truncated bool
+			for _, w := range c.Warnings() {
+				if strings.Contains(w, "truncated") {
+					truncated = true
+				}
+			}
+			if !truncated {
+				t.Errorf("warnings %q don't mention truncation", c.Warnings())
+			}
+			var missing *core.Mapping
+			for _, m := range c.Mappings() {
+				if m.Missing() {
+					missing = m
+					break
+				}
+			}
+			if missing == nil || int64(missing.Min()) != test.min {
+				t.Fatalf("first missing mapping is %v, want one starting at %#x", missing, test.min)
+			}
+			if c.Readable(missing.Min()) {
+				t.Errorf("address %x, after the end of the file, is readable", missing.Min())
+			}
+			if len(p.Warnings()) == 0 {
+				t.Errorf("no warnings about the data missing from the Go process")
+			}
+
+			// None of these may panic.
+			p.ForEachObject(func(x Object) bool {
+				p.Type(x)
+				p.Retained(x)
+				p.ForEachPtr(x, func(int64, Object, int64) bool { return true })
+				return true
+			})
+			p.ForEachRoot(func(r *Root) bool {
+				p.RootRetained(r)
+				return true
+			})
+			for _, g := range p.Goroutines() {
+				for _, f := range g.Frames() {
+					f.Func().Name()
+				}
+			}
+		})
+	}
+}
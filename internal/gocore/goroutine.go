@@ -11,6 +11,7 @@ import (
 type Goroutine struct {
 	r         region // inferior region holding the runtime.g
 	stackSize int64  // current stack allocation
+	state     string // status, like "running" or "waiting"
 	frames    []*Frame
 
 	// TODO: defers, in-progress panics
@@ -21,6 +22,18 @@ func (g *Goroutine) Stack() int64 {
 	return g.stackSize
 }
 
+// State returns the status of g, as shown in tracebacks, like "running"
+// or "waiting". States gocore doesn't know of are reported as
+// "unknown state N", and the stacks of such goroutines are not read.
+func (g *Goroutine) State() string {
+	return g.state
+}
+
+// ID returns the goroutine ID, as printed in tracebacks.
+func (g *Goroutine) ID() int64 {
+	return g.r.p.proc.ReadInt64(g.r.Field("goid").a)
+}
+
 // Addr returns the address of the runtime.g that identifies this goroutine.
 func (g *Goroutine) Addr() core.Address {
 	return g.r.a
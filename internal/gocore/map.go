@@ -0,0 +1,186 @@
+// Copyright 2024 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"strings"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// isMapHeader reports whether t is the runtime header type of a map,
+// which DWARF calls hash<K,V>.
+func isMapHeader(t *Type) bool {
+	return t != nil && t.Kind == KindStruct && strings.HasPrefix(t.Name, "hash<")
+}
+
+// forEachMapEntry calls fn for each occupied slot of the map whose
+// header (a hash<K,V>) is in r. fn is called with the regions holding
+// the key and the value. Keys and values too large to be stored inline
+// are passed as the pointers the runtime stores in their place. If fn
+// returns false, forEachMapEntry returns immediately.
+//
+// Both the current and, if the map is growing, the old buckets are
+// visited. The old buckets are half as many, unless the map is growing
+// to the same size to get rid of overflow buckets. Slots of the old buckets that have already been evacuated
+// are skipped, so each entry is reported exactly once.
+func (p *Process) forEachMapEntry(r region, fn func(k, v region) bool) {
+	if !isMapHeader(r.typ) {
+		panic("can't iterate over a non-map: " + r.typ.Name)
+	}
+	nb := int64(1) << r.Field("B").Uint8()
+	sameSize := r.Field("flags").Uint8()&uint8(p.rtConstants["sameSizeGrow"]) != 0
+	for _, f := range [2]string{"buckets", "oldbuckets"} {
+		b := r.Field(f)
+		if b.Address() == 0 {
+			continue
+		}
+		n := nb
+		if f == "oldbuckets" && !sameSize {
+			// The map is doubling in size.
+			n /= 2
+		}
+		bucket := b.Deref()
+		for i := int64(0); i < n; i++ {
+			x := region{p: p, a: bucket.a.Add(i * bucket.typ.Size), typ: bucket.typ}
+			if !p.forEachBucketEntry(x, fn) {
+				return
+			}
+		}
+	}
+}
+
+// forEachBucketEntry calls fn for each occupied slot of the map bucket b
+// and of its overflow chain. It returns false if fn did.
+func (p *Process) forEachBucketEntry(b region, fn func(k, v region) bool) bool {
+	minTopHash := uint8(p.rtConstants["minTopHash"])
+	elems := "elems"
+	if !b.HasField(elems) { // before go 1.14
+		elems = "values"
+	}
+	tophash := "topbits"
+	if !b.HasField(tophash) {
+		tophash = "tophash"
+	}
+	for b.a != 0 {
+		top := b.Field(tophash)
+		keys := b.Field("keys")
+		vals := b.Field(elems)
+		for i := int64(0); i < top.ArrayLen(); i++ {
+			if top.ArrayIndex(i).Uint8() < minTopHash {
+				// Empty, or evacuated to the new buckets.
+				continue
+			}
+			k := keys.ArrayIndex(i)
+			v := vals.ArrayIndex(i)
+			if !fn(k, v) {
+				return false
+			}
+		}
+		ov := b.Field("overflow")
+		b = region{p: p, a: p.proc.ReadPtr(ov.a), typ: b.typ}
+	}
+	return true
+}
+
+// MapTypes returns the key and element types of the map type t.
+// It returns nil, nil if t is not a map type.
+func (p *Process) MapTypes(t *Type) (key, elem *Type) {
+	k, v, _, _ := p.mapTypes(t)
+	return k, v
+}
+
+// mapTypes is like MapTypes, but also reports whether keys and elements
+// are stored indirectly. The runtime stores pointers to keys and elements
+// larger than 128 bytes in the buckets instead of the values themselves.
+func (p *Process) mapTypes(t *Type) (key, elem *Type, indirectKey, indirectElem bool) {
+	if t.Kind != KindPtr || !isMapHeader(t.Elem) {
+		return nil, nil, false, false
+	}
+	b := t.Elem.field("buckets").Type.Elem
+	elems := b.field("elems")
+	if elems == nil { // before go 1.14
+		elems = b.field("values")
+	}
+	key = b.field("keys").Type.Elem
+	elem = elems.Type.Elem
+	// The bucket type doesn't say whether a pointer slot holds a
+	// pointer-typed value or points to the value, but the name of the
+	// map type does: map[K]V.
+	if key.Kind == KindPtr && key.Elem != nil && !strings.HasPrefix(t.Name, "map["+key.Name+"]") {
+		key, indirectKey = key.Elem, true
+	}
+	if elem.Kind == KindPtr && elem.Elem != nil && !strings.HasSuffix(t.Name, "]"+elem.Name) {
+		elem, indirectElem = elem.Elem, true
+	}
+	return key, elem, indirectKey, indirectElem
+}
+
+// MapLen returns the number of entries in the map m of type t.
+// m is the value of the map, that is, the address of its runtime header.
+func (p *Process) MapLen(t *Type, m core.Address) int64 {
+	if t.Kind != KindPtr || !isMapHeader(t.Elem) || m == 0 {
+		return 0
+	}
+	return mapLen(region{p: p, a: m, typ: t.Elem})
+}
+
+// MapBuckets returns the number of buckets allocated for the map m of
+// type t, including the old buckets of a map that is growing, and the
+// size of each bucket. Overflow buckets are not included.
+func (p *Process) MapBuckets(t *Type, m core.Address) (n, size int64) {
+	if t.Kind != KindPtr || !isMapHeader(t.Elem) || m == 0 {
+		return 0, 0
+	}
+	r := region{p: p, a: m, typ: t.Elem}
+	n = int64(1) << r.Field("B").Uint8()
+	if r.Field("buckets").Address() == 0 {
+		n = 0 // lazily allocated
+	}
+	if r.Field("oldbuckets").Address() != 0 {
+		n += n / 2
+	}
+	return n, r.Field("buckets").typ.Elem.Size
+}
+
+// ForEachMapEntry calls fn with the addresses of the key and the element
+// of each entry of the map m of type t. m is the value of the map, that
+// is, the address of its runtime header. The types of the key and element
+// are given by MapTypes. If fn returns false, ForEachMapEntry returns
+// immediately.
+func (p *Process) ForEachMapEntry(t *Type, m core.Address, fn func(k, v core.Address) bool) {
+	_, _, ik, iv := p.mapTypes(t)
+	if t.Kind != KindPtr || !isMapHeader(t.Elem) || m == 0 {
+		return
+	}
+	p.forEachMapEntry(region{p: p, a: m, typ: t.Elem}, func(k, v region) bool {
+		ka, va := k.a, v.a
+		if ik {
+			ka = p.proc.ReadPtr(ka)
+		}
+		if iv {
+			va = p.proc.ReadPtr(va)
+		}
+		return fn(ka, va)
+	})
+}
+
+// mapLen returns the number of entries in the map whose header is in r.
+func mapLen(r region) int64 {
+	return r.Field("count").Int()
+}
+
+// mapHeaderAt returns the region holding the map header pointed to by
+// the map-typed value at a. It returns false for nil maps.
+func (p *Process) mapHeaderAt(t *Type, a core.Address) (region, bool) {
+	if t.Kind != KindPtr || !isMapHeader(t.Elem) {
+		return region{}, false
+	}
+	h := p.proc.ReadPtr(a)
+	if h == 0 {
+		return region{}, false
+	}
+	return region{p: p, a: h, typ: t.Elem}, true
+}
@@ -27,6 +27,9 @@ func (p *Process) markObjects() {
 
 	var q []Object
 
+	// Objects and pointer slots missing from the core.
+	unreadable := map[core.Address]bool{}
+
 	// Function to call when we find a new pointer.
 	add := func(x core.Address) {
 		h := p.findHeapInfo(x)
@@ -43,6 +46,12 @@ func (p *Process) markObjects() {
 		if h.mark&(uint64(1)<<b) != 0 { // already found
 			return
 		}
+		if !p.proc.ReadableN(x, h.size) {
+			// Leave out objects we can't scan, so
+			// that all objects are readable.
+			unreadable[x] = true
+			return
+		}
 		h.mark |= uint64(1) << b
 		n++
 		live += h.size
@@ -53,11 +62,20 @@ func (p *Process) markObjects() {
 	// Note that we don't just use the DWARF roots, just in case DWARF isn't complete.
 	// Instead we use exactly what the runtime uses.
 
+	// Function to call for a pointer slot in a root.
+	addAt := func(a core.Address) {
+		if !p.proc.ReadableN(a, ptrSize) {
+			unreadable[a] = true
+			return
+		}
+		add(p.proc.ReadPtr(a))
+	}
+
 	// Goroutine roots
 	for _, g := range p.goroutines {
 		for _, f := range g.frames {
 			for a := range f.Live {
-				add(p.proc.ReadPtr(a))
+				addAt(a)
 			}
 		}
 	}
@@ -70,8 +88,14 @@ func (p *Process) markObjects() {
 			gc := m.r.Field("gc" + s + "mask").Field("bytedata").Address()
 			num := max.Sub(min) / ptrSize
 			for i := int64(0); i < num; i++ {
+				if !p.proc.Readable(gc.Add(i / 8)) {
+					// Without the pointer mask, we don't know
+					// which words are pointers.
+					unreadable[gc.Add(i/8)] = true
+					continue
+				}
 				if p.proc.ReadUint8(gc.Add(i/8))>>uint(i%8)&1 != 0 {
-					add(p.proc.ReadPtr(min.Add(i * ptrSize)))
+					addAt(min.Add(i * ptrSize))
 				}
 			}
 		}
@@ -84,7 +108,7 @@ func (p *Process) markObjects() {
 		}
 		for _, f := range r.Type.Fields {
 			if f.Type.Kind == KindPtr {
-				add(p.proc.ReadPtr(r.Addr.Add(f.Off)))
+				addAt(r.Addr.Add(f.Off))
 			}
 		}
 	}
@@ -105,6 +129,9 @@ func (p *Process) markObjects() {
 	}
 
 	p.nObj = n
+	if len(unreadable) > 0 {
+		p.warnf("%d heap objects and pointers are missing from the core; objects reachable only through them are not found", len(unreadable))
+	}
 
 	// Initialize firstIdx fields in the heapInfo, for fast object index lookups.
 	n = 0
@@ -117,7 +144,8 @@ func (p *Process) markObjects() {
 		return true
 	})
 	if n != p.nObj {
-		panic("object count wrong")
+		p.warnf("found %d heap objects, but marked %d", n, p.nObj)
+		p.nObj = n
 	}
 
 	// Update stats to include the live/garbage distinction.
@@ -288,7 +316,7 @@ func edges1(p *Process, r *Root, off int64, t *Type, fn func(int64, Object, int6
 		// Itabs are never in the heap.
 		// Types might be, though.
 		a := r.Addr.Add(off)
-		if r.Frame == nil || r.Frame.Live[a] {
+		if rootPtrLive(p, r, a) {
 			dst, off2 := p.FindObject(p.proc.ReadPtr(a))
 			if dst != 0 {
 				if !fn(off, dst, off2) {
@@ -301,7 +329,7 @@ func edges1(p *Process, r *Root, off int64, t *Type, fn func(int64, Object, int6
 		fallthrough
 	case KindPtr, KindString, KindSlice, KindFunc:
 		a := r.Addr.Add(off)
-		if r.Frame == nil || r.Frame.Live[a] {
+		if rootPtrLive(p, r, a) {
 			dst, off2 := p.FindObject(p.proc.ReadPtr(a))
 			if dst != 0 {
 				if !fn(off, dst, off2) {
@@ -326,6 +354,16 @@ func edges1(p *Process, r *Root, off int64, t *Type, fn func(int64, Object, int6
 	return true
 }
 
+// rootPtrLive reports whether the pointer slot at a in the root r is
+// live and can be read. Slots in memory missing from the core, like a
+// stack cut off from a truncated core, are skipped.
+func rootPtrLive(p *Process, r *Root, a core.Address) bool {
+	if r.Frame != nil && !r.Frame.Live[a] {
+		return false
+	}
+	return p.proc.ReadableN(a, p.proc.PtrSize())
+}
+
 const heapInfoSize = 512
 
 // Information for heapInfoSize bytes of heap.
@@ -6,6 +6,7 @@ package gocore
 
 import (
 	"debug/dwarf"
+	"errors"
 	"fmt"
 	"math/bits"
 	"strings"
@@ -40,6 +41,9 @@ type Process struct {
 	// address -> function mapping
 	funcTab funcTab
 
+	// functions that aren't Go code, by entry point
+	cFuncs map[core.Address]*Func
+
 	// map from dwarf type to *Type
 	dwarfMap map[dwarf.Type]*Type
 
@@ -76,6 +80,16 @@ type Process struct {
 	// Sorted list of all roots.
 	// Only initialized if FlagReverse is passed to Core.
 	rootIdx []*Root
+
+	// Dominator tree, used for retained sizes.
+	initDominators sync.Once
+	dominators     *dominators
+
+	// context.Context implementations found in the heap.
+	initContexts sync.Once
+	contexts     []*Context
+
+	warnings []string // problems found while reading the core
 }
 
 // Process returns the core.Process used to construct this Process.
@@ -97,6 +111,18 @@ func (p *Process) BuildVersion() string {
 	return p.buildVersion
 }
 
+// Warnings returns the problems found while reading the Go state of
+// the process that weren't bad enough to give up on it, like
+// unreadable spans or goroutines. The information affected by them
+// is missing from p.
+func (p *Process) Warnings() []string {
+	return p.warnings
+}
+
+func (p *Process) warnf(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
 func (p *Process) Globals() []*Root {
 	return p.globals
 }
@@ -106,6 +132,17 @@ func (p *Process) FindFunc(pc core.Address) *Func {
 	return p.funcTab.find(pc)
 }
 
+// FindType returns the type with the given name, or nil if there is none.
+// Package paths in name are ignored, as the runtime does.
+// If more than one type has the name, one of them is returned.
+func (p *Process) FindType(name string) *Type {
+	s := p.runtimeNameMap[stripPackagePath(name)]
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
+
 func (p *Process) findType(name string) *Type {
 	s := p.runtimeNameMap[name]
 	if len(s) == 0 {
@@ -114,28 +151,48 @@ func (p *Process) findType(name string) *Type {
 	return s[0]
 }
 
+// A LoadError is returned by Core when the Go state of the process
+// can't be read from the core.
+type LoadError struct {
+	Phase string // what Core was doing, like "reading heap"
+	Err   error
+}
+
+func (e *LoadError) Error() string {
+	return "gocore: " + e.Phase + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
 // Core takes a loaded core file and extracts Go information from it.
+// Problems that affect only part of the process, like an unreadable
+// span, are recorded in Warnings. Others make Core fail with a *LoadError.
 func Core(proc *core.Process) (p *Process, err error) {
 	// Make sure we have DWARF info.
 	if _, err := proc.DWARF(); err != nil {
 		return nil, fmt.Errorf("error reading dwarf: %w", err)
 	}
 
-	// Guard against failures of proc.Read* routines.
-	/*
-		defer func() {
-			e := recover()
-			if e == nil {
-				return
-			}
-			p = nil
-			if x, ok := e.(error); ok {
-				err = x
-				return
-			}
+	// Guard against failures of proc.Read* routines and against
+	// inconsistencies in the runtime data structures.
+	phase := "reading DWARF"
+	defer func() {
+		e := recover()
+		if e == nil {
+			return
+		}
+		p = nil
+		switch x := e.(type) {
+		case error:
+			err = &LoadError{Phase: phase, Err: x}
+		case string:
+			err = &LoadError{Phase: phase, Err: errors.New(x)}
+		default:
 			panic(e) // Not an error, re-panic it.
-		}()
-	*/
+		}
+	}()
 
 	p = &Process{
 		proc:       proc,
@@ -146,6 +203,7 @@ func Core(proc *core.Process) (p *Process, err error) {
 	// Initialize everything that just depends on DWARF.
 	p.readDWARFTypes()
 	p.readRuntimeConstants()
+	phase = "reading globals"
 	p.readGlobals()
 
 	// Find runtime globals we care about. Initialize regions for them.
@@ -168,15 +226,37 @@ func Core(proc *core.Process) (p *Process, err error) {
 	// version.
 	p.is117OrGreater = p.findType("runtime._func").HasField("flag")
 
+	phase = "reading modules"
 	p.readModules()
+	phase = "reading heap"
 	p.readHeap()
+	phase = "reading goroutines"
 	p.readGs()
+	phase = "reading stack variables"
 	p.readStackVars() // needs to be after readGs.
-	p.markObjects()   // needs to be after readGlobals, readStackVars.
+	phase = "marking objects"
+	p.markObjects() // needs to be after readGlobals, readStackVars.
 
 	return p, nil
 }
 
+// catchReadError calls fn and returns the error it panicked with if
+// the panic came from reading unreadable memory of the inferior.
+// Other panics are passed on.
+func catchReadError(fn func()) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			re, ok := e.(*core.ReadError)
+			if !ok {
+				panic(e)
+			}
+			err = re
+		}
+	}()
+	fn()
+	return nil
+}
+
 // arena is a summary of the size of components of a heapArena.
 type arena struct {
 	heapMin core.Address
@@ -393,7 +473,8 @@ func (p *Process) readSpans(mheap region, arenas []arena) {
 			// TODO: how to distinguish original bss from anonymous mmap?
 			bss += size
 		default:
-			panic("weird mapping " + m.Perm().String())
+			p.warnf("ignoring mapping [%x %x] with unexpected permissions %s", m.Min(), m.Max(), m.Perm())
+			all -= size
 		}
 	}
 	if !p.is117OrGreater && mheap.HasField("curArena") {
@@ -430,169 +511,188 @@ func (p *Process) readSpans(mheap region, arenas []arena) {
 	var manualAllocSize int64
 	var manualFreeSize int64
 	n := allspans.SliceLen()
+	var skipped int64
+	var skipErr error
 	for i := int64(0); i < n; i++ {
-		s := allspans.SliceIndex(i).Deref()
-		min := core.Address(s.Field("startAddr").Uintptr())
-		elemSize := int64(s.Field("elemsize").Uintptr())
-		nPages := int64(s.Field("npages").Uintptr())
-		spanSize := nPages * pageSize
-		max := min.Add(spanSize)
-		for a := min; a != max; a = a.Add(pageSize) {
-			if !p.proc.Readable(a) {
-				// Sometimes allocated but not yet touched pages or
-				// MADV_DONTNEEDed pages are not written
-				// to the core file.  Don't count these pages toward
-				// space usage (otherwise it can look like the heap
-				// is larger than the total memory used).
-				spanSize -= pageSize
-			}
-		}
-		st := s.Field("state")
-		if st.IsStruct() && st.HasField("s") { // go1.14+
-			st = st.Field("s")
-		}
-		if st.IsStruct() && st.HasField("value") { // go1.20+
-			st = st.Field("value")
-		}
-		switch st.Uint8() {
-		case spanInUse:
-			inUseSpanSize += spanSize
-			nelems := s.Field("nelems")
-			var n int64
-			if nelems.IsUint16() { // go1.22+
-				n = int64(nelems.Uint16())
-			} else {
-				n = int64(nelems.Uintptr())
-			}
-			// An object is allocated if it is marked as
-			// allocated or it is below freeindex.
-			x := s.Field("allocBits").Address()
-			alloc := make([]bool, n)
-			for i := int64(0); i < n; i++ {
-				alloc[i] = p.proc.ReadUint8(x.Add(i/8))>>uint(i%8)&1 != 0
+		err := catchReadError(func() {
+			s := allspans.SliceIndex(i).Deref()
+			min := core.Address(s.Field("startAddr").Uintptr())
+			elemSize := int64(s.Field("elemsize").Uintptr())
+			nPages := int64(s.Field("npages").Uintptr())
+			spanSize := nPages * pageSize
+			max := min.Add(spanSize)
+			for a := min; a != max; a = a.Add(pageSize) {
+				if !p.proc.Readable(a) {
+					// Sometimes allocated but not yet touched pages or
+					// MADV_DONTNEEDed pages are not written
+					// to the core file.  Don't count these pages toward
+					// space usage (otherwise it can look like the heap
+					// is larger than the total memory used).
+					spanSize -= pageSize
+				}
 			}
-			freeindex := s.Field("freeindex")
-			var k int64
-			if freeindex.IsUint16() { // go1.22+
-				k = int64(freeindex.Uint16())
-			} else {
-				k = int64(freeindex.Uintptr())
+			st := s.Field("state")
+			if st.IsStruct() && st.HasField("s") { // go1.14+
+				st = st.Field("s")
 			}
-			for i := int64(0); i < k; i++ {
-				alloc[i] = true
+			if st.IsStruct() && st.HasField("value") { // go1.20+
+				st = st.Field("value")
 			}
-			for i := int64(0); i < n; i++ {
-				if alloc[i] {
-					allocSize += elemSize
+			switch st.Uint8() {
+			case spanInUse:
+				inUseSpanSize += spanSize
+				nelems := s.Field("nelems")
+				var n int64
+				if nelems.IsUint16() { // go1.22+
+					n = int64(nelems.Uint16())
 				} else {
-					freeSize += elemSize
+					n = int64(nelems.Uintptr())
 				}
-			}
-			spanRoundSize += spanSize - n*elemSize
+				// An object is allocated if it is marked as
+				// allocated or it is below freeindex.
+				x := s.Field("allocBits").Address()
+				alloc := make([]bool, n)
+				for i := int64(0); i < n; i++ {
+					alloc[i] = p.proc.ReadUint8(x.Add(i/8))>>uint(i%8)&1 != 0
+				}
+				freeindex := s.Field("freeindex")
+				var k int64
+				if freeindex.IsUint16() { // go1.22+
+					k = int64(freeindex.Uint16())
+				} else {
+					k = int64(freeindex.Uintptr())
+				}
+				for i := int64(0); i < k; i++ {
+					alloc[i] = true
+				}
+				for i := int64(0); i < n; i++ {
+					if alloc[i] {
+						allocSize += elemSize
+					} else {
+						freeSize += elemSize
+					}
+				}
+				spanRoundSize += spanSize - n*elemSize
 
-			// initialize heap info records for all inuse spans.
-			for a := min; a < max; a += heapInfoSize {
-				h := p.allocHeapInfo(a)
-				h.base = min
-				h.size = elemSize
-			}
+				// initialize heap info records for all inuse spans.
+				for a := min; a < max; a += heapInfoSize {
+					h := p.allocHeapInfo(a)
+					h.base = min
+					h.size = elemSize
+				}
 
-			// Process special records.
-			for sp := s.Field("specials"); sp.Address() != 0; sp = sp.Field("next") {
-				sp = sp.Deref() // *special to special
-				if sp.Field("kind").Uint8() != uint8(p.rtConstants["_KindSpecialFinalizer"]) {
-					// All other specials (just profile records) can't point into the heap.
-					continue
+				// Process special records.
+				for sp := s.Field("specials"); sp.Address() != 0; sp = sp.Field("next") {
+					sp = sp.Deref() // *special to special
+					if sp.Field("kind").Uint8() != uint8(p.rtConstants["_KindSpecialFinalizer"]) {
+						// All other specials (just profile records) can't point into the heap.
+						continue
+					}
+					obj := min.Add(int64(sp.Field("offset").Uint16()))
+					p.globals = append(p.globals,
+						&Root{
+							Name:  fmt.Sprintf("finalizer for %x", obj),
+							Addr:  sp.a,
+							Type:  p.findType("runtime.specialfinalizer"),
+							Frame: nil,
+						})
+					// TODO: these aren't really "globals", as they
+					// are kept alive by the object they reference being alive.
+					// But we have no way of adding edges from an object to
+					// the corresponding finalizer data, so we punt on that thorny
+					// issue for now.
 				}
-				obj := min.Add(int64(sp.Field("offset").Uint16()))
-				p.globals = append(p.globals,
-					&Root{
-						Name:  fmt.Sprintf("finalizer for %x", obj),
-						Addr:  sp.a,
-						Type:  p.findType("runtime.specialfinalizer"),
-						Frame: nil,
-					})
-				// TODO: these aren't really "globals", as they
-				// are kept alive by the object they reference being alive.
-				// But we have no way of adding edges from an object to
-				// the corresponding finalizer data, so we punt on that thorny
-				// issue for now.
-			}
-		case spanFree:
-			freeSpanSize += spanSize
-			if s.HasField("npreleased") { // go 1.11 and earlier
-				nReleased := int64(s.Field("npreleased").Uintptr())
-				releasedSpanSize += nReleased * pageSize
-			} else { // go 1.12 and beyond
-				if s.Field("scavenged").Bool() {
-					releasedSpanSize += spanSize
+			case spanFree:
+				freeSpanSize += spanSize
+				if s.HasField("npreleased") { // go 1.11 and earlier
+					nReleased := int64(s.Field("npreleased").Uintptr())
+					releasedSpanSize += nReleased * pageSize
+				} else { // go 1.12 and beyond
+					if s.Field("scavenged").Bool() {
+						releasedSpanSize += spanSize
+					}
+				}
+			case spanDead:
+				// These are just deallocated span descriptors. They use no heap.
+			case spanManual:
+				manualSpanSize += spanSize
+				manualAllocSize += spanSize
+				for x := core.Address(s.Field("manualFreeList").Cast("uintptr").Uintptr()); x != 0; x = p.proc.ReadPtr(x) {
+					manualAllocSize -= elemSize
+					manualFreeSize += elemSize
 				}
 			}
-		case spanDead:
-			// These are just deallocated span descriptors. They use no heap.
-		case spanManual:
-			manualSpanSize += spanSize
-			manualAllocSize += spanSize
-			for x := core.Address(s.Field("manualFreeList").Cast("uintptr").Uintptr()); x != 0; x = p.proc.ReadPtr(x) {
-				manualAllocSize -= elemSize
-				manualFreeSize += elemSize
+		})
+		if err != nil {
+			// Skip the span. Its objects will be missing.
+			if skipped == 0 {
+				skipErr = err
 			}
+			skipped++
 		}
 	}
+	if skipped > 0 {
+		p.warnf("skipped %d of %d spans that can't be read (first error: %v); their objects are missing", skipped, n, skipErr)
+	}
 	if mheap.HasField("pages") { // go1.14+
-		// There are no longer "free" mspans to represent unused pages.
-		// Instead, there are just holes in the pagemap into which we can allocate.
-		// Look through the page allocator and count the total free space.
-		// Also keep track of how much has been scavenged.
-		pages := mheap.Field("pages")
-		chunks := pages.Field("chunks")
-		arenaBaseOffset := p.getArenaBaseOffset()
-		pallocChunkBytes := p.rtConstants["pallocChunkBytes"]
-		pallocChunksL1Bits := p.rtConstants["pallocChunksL1Bits"]
-		pallocChunksL2Bits := p.rtConstants["pallocChunksL2Bits"]
-		inuse := pages.Field("inUse")
-		ranges := inuse.Field("ranges")
-		for i := int64(0); i < ranges.SliceLen(); i++ {
-			r := ranges.SliceIndex(i)
-			baseField := r.Field("base")
-			if baseField.IsStruct() { // go 1.15+
-				baseField = baseField.Field("a")
-			}
-			base := core.Address(baseField.Uintptr())
-			limitField := r.Field("limit")
-			if limitField.IsStruct() { // go 1.15+
-				limitField = limitField.Field("a")
-			}
-			limit := core.Address(limitField.Uintptr())
-			chunkBase := (int64(base) + arenaBaseOffset) / pallocChunkBytes
-			chunkLimit := (int64(limit) + arenaBaseOffset) / pallocChunkBytes
-			for chunkIdx := chunkBase; chunkIdx < chunkLimit; chunkIdx++ {
-				var l1, l2 int64
-				if pallocChunksL1Bits == 0 {
-					l2 = chunkIdx
-				} else {
-					l1 = chunkIdx >> uint(pallocChunksL2Bits)
-					l2 = chunkIdx & (1<<uint(pallocChunksL2Bits) - 1)
+		err := catchReadError(func() {
+			// There are no longer "free" mspans to represent unused pages.
+			// Instead, there are just holes in the pagemap into which we can allocate.
+			// Look through the page allocator and count the total free space.
+			// Also keep track of how much has been scavenged.
+			pages := mheap.Field("pages")
+			chunks := pages.Field("chunks")
+			arenaBaseOffset := p.getArenaBaseOffset()
+			pallocChunkBytes := p.rtConstants["pallocChunkBytes"]
+			pallocChunksL1Bits := p.rtConstants["pallocChunksL1Bits"]
+			pallocChunksL2Bits := p.rtConstants["pallocChunksL2Bits"]
+			inuse := pages.Field("inUse")
+			ranges := inuse.Field("ranges")
+			for i := int64(0); i < ranges.SliceLen(); i++ {
+				r := ranges.SliceIndex(i)
+				baseField := r.Field("base")
+				if baseField.IsStruct() { // go 1.15+
+					baseField = baseField.Field("a")
 				}
-				chunk := chunks.ArrayIndex(l1).Deref().ArrayIndex(l2)
-				// Count the free bits in this chunk.
-				alloc := chunk.Field("pallocBits")
-				for i := int64(0); i < pallocChunkBytes/pageSize/64; i++ {
-					freeSpanSize += int64(bits.OnesCount64(^alloc.ArrayIndex(i).Uint64())) * pageSize
+				base := core.Address(baseField.Uintptr())
+				limitField := r.Field("limit")
+				if limitField.IsStruct() { // go 1.15+
+					limitField = limitField.Field("a")
 				}
-				// Count the scavenged bits in this chunk.
-				scavenged := chunk.Field("scavenged")
-				for i := int64(0); i < pallocChunkBytes/pageSize/64; i++ {
-					releasedSpanSize += int64(bits.OnesCount64(scavenged.ArrayIndex(i).Uint64())) * pageSize
+				limit := core.Address(limitField.Uintptr())
+				chunkBase := (int64(base) + arenaBaseOffset) / pallocChunkBytes
+				chunkLimit := (int64(limit) + arenaBaseOffset) / pallocChunkBytes
+				for chunkIdx := chunkBase; chunkIdx < chunkLimit; chunkIdx++ {
+					var l1, l2 int64
+					if pallocChunksL1Bits == 0 {
+						l2 = chunkIdx
+					} else {
+						l1 = chunkIdx >> uint(pallocChunksL2Bits)
+						l2 = chunkIdx & (1<<uint(pallocChunksL2Bits) - 1)
+					}
+					chunk := chunks.ArrayIndex(l1).Deref().ArrayIndex(l2)
+					// Count the free bits in this chunk.
+					alloc := chunk.Field("pallocBits")
+					for i := int64(0); i < pallocChunkBytes/pageSize/64; i++ {
+						freeSpanSize += int64(bits.OnesCount64(^alloc.ArrayIndex(i).Uint64())) * pageSize
+					}
+					// Count the scavenged bits in this chunk.
+					scavenged := chunk.Field("scavenged")
+					for i := int64(0); i < pallocChunkBytes/pageSize/64; i++ {
+						releasedSpanSize += int64(bits.OnesCount64(scavenged.ArrayIndex(i).Uint64())) * pageSize
+					}
 				}
 			}
-		}
-		// Also count pages in the page cache for each P.
-		allp := p.rtGlobals["allp"]
-		for i := int64(0); i < allp.SliceLen(); i++ {
-			pcache := allp.SliceIndex(i).Deref().Field("pcache")
-			freeSpanSize += int64(bits.OnesCount64(pcache.Field("cache").Uint64())) * pageSize
-			releasedSpanSize += int64(bits.OnesCount64(pcache.Field("scav").Uint64())) * pageSize
+			// Also count pages in the page cache for each P.
+			allp := p.rtGlobals["allp"]
+			for i := int64(0); i < allp.SliceLen(); i++ {
+				pcache := allp.SliceIndex(i).Deref().Field("pcache")
+				freeSpanSize += int64(bits.OnesCount64(pcache.Field("cache").Uint64())) * pageSize
+				releasedSpanSize += int64(bits.OnesCount64(pcache.Field("scav").Uint64())) * pageSize
+			}
+		})
+		if err != nil {
+			p.warnf("free page counts are incomplete: %v", err)
 		}
 	}
 
@@ -630,7 +730,8 @@ func (p *Process) readSpans(mheap region, arenas []arena) {
 			sum += c.Size
 		}
 		if sum != s.Size {
-			panic(fmt.Sprintf("check failed for %s: %d vs %d", s.Name, s.Size, sum))
+			// Can happen if spans were skipped above.
+			p.warnf("memory stats don't add up for %s: %d vs %d", s.Name, s.Size, sum)
 		}
 		for _, c := range s.Children {
 			check(c)
@@ -643,9 +744,18 @@ func (p *Process) readGs() {
 	// TODO: figure out how to "flush" running Gs.
 	allgs := p.rtGlobals["allgs"]
 	n := allgs.SliceLen()
+	if !p.unwinds() {
+		p.warnf("not reading goroutine stacks: unwinding them is only supported on 386 and amd64, not %s", p.proc.Arch())
+	}
 	for i := int64(0); i < n; i++ {
-		r := allgs.SliceIndex(i).Deref()
-		g := p.readG(r)
+		var g *Goroutine
+		err := catchReadError(func() {
+			g = p.readG(allgs.SliceIndex(i).Deref())
+		})
+		if err != nil {
+			p.warnf("skipping goroutine %d of %d: %v", i, n, err)
+			continue
+		}
 		if g == nil {
 			continue
 		}
@@ -676,7 +786,8 @@ func (p *Process) readG(r region) *Goroutine {
 	}
 	status := st.Uint32()
 	status &^= uint32(p.rtConstants["_Gscan"])
-	var sp, pc core.Address
+	g.state = p.gStateName(status)
+	var sp, pc, fp core.Address
 	switch status {
 	case uint32(p.rtConstants["_Gidle"]):
 		return g
@@ -685,26 +796,91 @@ func (p *Process) readG(r region) *Goroutine {
 		sp = core.Address(sched.Field("sp").Uintptr())
 		pc = core.Address(sched.Field("pc").Uintptr())
 	case uint32(p.rtConstants["_Grunning"]):
+		if osT == nil {
+			p.warnf("goroutine %d is running, but its thread is missing; not reading its stack", g.ID())
+			return g
+		}
 		sp = osT.SP()
 		pc = osT.PC()
+		if regs := osT.Registers(); regs != nil {
+			fp = regs.FP()
+		}
 		// TODO: back up to the calling frame?
 	case uint32(p.rtConstants["_Gsyscall"]):
 		sp = core.Address(r.Field("syscallsp").Uintptr())
 		pc = core.Address(r.Field("syscallpc").Uintptr())
 		// TODO: or should we use the osT registers?
+		if m := mp.Deref(); osT != nil && mp.Address() != 0 && m.HasField("incgo") && m.Field("incgo").Bool() && p.proc.Arch() == "amd64" {
+			// In a cgo call, the thread runs C code on the
+			// system stack. Start there; readFrames goes back
+			// to the goroutine's stack at runtime.asmcgocall.
+			sp, pc = osT.SP(), osT.PC()
+			if regs := osT.Registers(); regs != nil {
+				fp = regs.FP()
+			}
+		}
 	case uint32(p.rtConstants["_Gdead"]):
 		return nil
 		// TODO: copystack, others?
 	default:
 		// Unknown state. We can't read the frames, so just bail now.
-		// TODO: make this switch complete and then panic here.
-		// TODO: or just return nil?
+		p.warnf("goroutine %d is in %s; not reading its stack", g.ID(), g.state)
+		return g
+	}
+	if !p.unwinds() {
 		return g
 	}
+	err := catchReadError(func() { p.readFrames(g, r, sp, pc, fp) })
+	if err != nil {
+		p.warnf("goroutine %d: giving up on backtrace: %v", g.ID(), err)
+	}
+	return g
+}
+
+// gStateNames maps the runtime's goroutine status constants to the
+// names used for them in tracebacks.
+var gStateNames = []struct{ constant, name string }{
+	{"_Gidle", "idle"},
+	{"_Grunnable", "runnable"},
+	{"_Grunning", "running"},
+	{"_Gsyscall", "syscall"},
+	{"_Gwaiting", "waiting"},
+	{"_Gdead", "dead"},
+	{"_Gcopystack", "copystack"},
+	{"_Gpreempted", "preempted"},
+}
+
+// gStateName returns the name of the goroutine status,
+// with the _Gscan bit cleared.
+func (p *Process) gStateName(status uint32) string {
+	for _, s := range gStateNames {
+		if c, ok := p.rtConstants[s.constant]; ok && uint32(c) == status {
+			return s.name
+		}
+	}
+	return fmt.Sprintf("unknown state %d", status)
+}
+
+// readFrames reads the stack frames of g, starting with the frame
+// at sp and pc. fp is the frame pointer, used to unwind functions that
+// aren't Go code.
+func (p *Process) readFrames(g *Goroutine, r region, sp, pc, fp core.Address) {
 	for {
+		if f, callerPC, callerFP := p.readCFrame(sp, pc, fp); f != nil {
+			if len(g.frames) > 0 {
+				g.frames[len(g.frames)-1].parent = f
+			}
+			g.frames = append(g.frames, f)
+			if callerPC == 0 {
+				p.warnf("goroutine %d: giving up on backtrace: can't find the caller of %s", g.ID(), f.f.name)
+				break
+			}
+			sp, pc, fp = f.max, callerPC, callerFP
+			continue
+		}
 		f, err := p.readFrame(sp, pc)
 		if err != nil {
-			fmt.Printf("warning: giving up on backtrace: %v\n", err)
+			p.warnf("goroutine %d: giving up on backtrace: %v", g.ID(), err)
 			break
 		}
 		if f.f.name == "runtime.goexit" {
@@ -715,19 +891,44 @@ func (p *Process) readG(r region) *Goroutine {
 		}
 		g.frames = append(g.frames, f)
 
-		if f.f.name == "runtime.sigtrampgo" {
+		if f.f.name == "runtime.sigtrampgo" && p.proc.Arch() == "386" {
 			// Continue traceback at location where the signal
 			// interrupted normal execution.
-			ctxt := p.proc.ReadPtr(sp.Add(16)) // 3rd arg
+			ctxt := p.proc.ReadPtr(f.max.Add(8)) // 3rd arg, on the stack
+			// ctxt is a *ucontext
+			mctxt := ctxt.Add(5 * 4)
+			// mctxt is a *sigcontext
+			sp = p.proc.ReadPtr(mctxt.Add(7 * 4))
+			pc = p.proc.ReadPtr(mctxt.Add(14 * 4))
+		} else if f.f.name == "runtime.sigtramp" && p.proc.Arch() == "amd64" {
+			// Continue traceback at location where the signal
+			// interrupted normal execution. The kernel called
+			// sigtramp with the return address to the signal
+			// restorer on the stack, right below the ucontext.
+			// Unlike the arguments of sigtrampgo, which may be
+			// passed in registers, it is there in all versions.
+			ctxt := f.max
 			//ctxt is a *ucontext
 			mctxt := ctxt.Add(5 * 8)
 			// mctxt is a *mcontext
 			sp = p.proc.ReadPtr(mctxt.Add(15 * 8))
 			pc = p.proc.ReadPtr(mctxt.Add(16 * 8))
-			// TODO: totally arch-dependent!
+			fp = p.proc.ReadPtr(mctxt.Add(10 * 8))
+			if pc == 0 {
+				break
+			}
+			continue
 		} else {
+			// The call instruction pushed the return address.
 			sp = f.max
-			pc = core.Address(p.proc.ReadUintptr(sp - 8)) // TODO:amd64 only
+			pc = core.Address(p.proc.ReadUintptr(sp.Add(-p.proc.PtrSize())))
+		}
+		fp = 0
+		if p.proc.Arch() == "amd64" && f.max.Sub(f.min) > p.proc.PtrSize() {
+			// A function with a frame saves the frame pointer
+			// right below the return address. It leads to the
+			// frames of C functions the caller may be called by.
+			fp = p.proc.ReadPtr(f.max.Add(-2 * p.proc.PtrSize()))
 		}
 		if pc == 0 {
 			// TODO: when would this happen?
@@ -739,8 +940,52 @@ func (p *Process) readG(r region) *Goroutine {
 			sp = core.Address(sched.Field("sp").Uintptr())
 			pc = core.Address(sched.Field("pc").Uintptr())
 		}
+		if f.f.name == "runtime.asmcgocall" && r.Field("syscallsp").Uintptr() != 0 {
+			// Back from the C code of a cgo call, on the system
+			// stack, to the goroutine stack.
+			sp = core.Address(r.Field("syscallsp").Uintptr())
+			pc = core.Address(r.Field("syscallpc").Uintptr())
+		}
 	}
-	return g
+}
+
+// maxCFrameSize bounds the size of the frames of functions that aren't
+// Go code, to tell frame pointers from other uses of the register.
+const maxCFrameSize = 1 << 20
+
+// readCFrame reads the frame at sp and pc of a function that isn't Go
+// code, such as a C function called through cgo, named after the symbol
+// containing pc. It returns nil if pc is in Go code or in no known
+// function.
+//
+// The caller is found by following the frame pointer fp, on amd64, where
+// it points to the caller's frame pointer and the return address, just
+// below the caller's stack pointer. C code need not keep a frame
+// pointer, so fp is only trusted if it points into the stack just
+// above sp. Otherwise the returned callerPC is 0.
+func (p *Process) readCFrame(sp, pc, fp core.Address) (f *Frame, callerPC, callerFP core.Address) {
+	if p.funcTab.find(pc) != nil {
+		return nil, 0, 0
+	}
+	s, _ := p.proc.SymbolAt(pc)
+	if s == nil || !s.Func {
+		return nil, 0, 0
+	}
+	fn := p.cFuncs[s.Addr]
+	if fn == nil {
+		fn = &Func{name: s.Name, entry: s.Addr}
+		if p.cFuncs == nil {
+			p.cFuncs = map[core.Address]*Func{}
+		}
+		p.cFuncs[s.Addr] = fn
+	}
+	f = &Frame{f: fn, pc: pc, min: sp, max: sp, Live: map[core.Address]bool{}}
+	ptrSize := p.proc.PtrSize()
+	if p.proc.Arch() != "amd64" || fp < sp || fp.Sub(sp) > maxCFrameSize || fp%core.Address(ptrSize) != 0 || !p.proc.ReadableN(fp, 2*ptrSize) {
+		return f, 0, 0
+	}
+	f.max = fp.Add(2 * ptrSize)
+	return f, p.proc.ReadPtr(fp.Add(ptrSize)), p.proc.ReadPtr(fp)
 }
 
 func (p *Process) readFrame(sp, pc core.Address) (*Frame, error) {
@@ -753,7 +998,7 @@ func (p *Process) readFrame(sp, pc core.Address) (*Frame, error) {
 	if err != nil {
 		return nil, fmt.Errorf("cannot read frame size at pc=%#x: %v", pc, err)
 	}
-	size += p.proc.PtrSize() // TODO: on amd64, the pushed return address
+	size += p.proc.PtrSize() // the pushed return address
 
 	frame := &Frame{f: f, pc: pc, min: sp, max: sp.Add(size)}
 
@@ -777,8 +1022,13 @@ func (p *Process) readFrame(sp, pc core.Address) (*Frame, error) {
 			}
 			if idx < int64(n) {
 				bits := locals.Field("bytedata").a.Add(int64(nbit+7) / 8 * idx)
-				base := frame.max.Add(-16).Add(-int64(nbit) * p.proc.PtrSize())
-				// TODO: -16 for amd64. Return address and parent's frame pointer
+				// Locals end below the return address and the saved
+				// frame pointer, as in the runtime's frame.varp.
+				varp := frame.max.Add(-p.proc.PtrSize())
+				if p.proc.Arch() == "amd64" && varp > frame.min {
+					varp = varp.Add(-p.proc.PtrSize())
+				}
+				base := varp.Add(-int64(nbit) * p.proc.PtrSize())
 				for i := int64(0); i < int64(nbit); i++ {
 					if p.proc.ReadUint8(bits.Add(i/8))>>uint(i&7)&1 != 0 {
 						live[base.Add(i*p.proc.PtrSize())] = true
@@ -804,7 +1054,6 @@ func (p *Process) readFrame(sp, pc core.Address) (*Frame, error) {
 			if idx < int64(n) {
 				bits := args.Field("bytedata").a.Add(int64(nbit+7) / 8 * idx)
 				base := frame.max
-				// TODO: add to base for LR archs.
 				for i := int64(0); i < int64(nbit); i++ {
 					if p.proc.ReadUint8(bits.Add(i/8))>>uint(i&7)&1 != 0 {
 						live[base.Add(i*p.proc.PtrSize())] = true
@@ -818,6 +1067,18 @@ func (p *Process) readFrame(sp, pc core.Address) (*Frame, error) {
 	return frame, nil
 }
 
+// unwinds reports whether goroutine stacks can be unwound on the
+// architecture. Only 386 and amd64, where call instructions push the
+// return address, are supported. Unwinding on architectures with a
+// link register hasn't been tested against a real core.
+func (p *Process) unwinds() bool {
+	switch p.proc.Arch() {
+	case "386", "amd64":
+		return true
+	}
+	return false
+}
+
 // A Stats struct is the node of a tree representing the entire memory
 // usage of the Go program. Children of a node break its usage down
 // by category.
@@ -0,0 +1,20 @@
+package main
+
+// #cgo CFLAGS: -O0 -fno-omit-frame-pointer
+//
+// #include <sys/resource.h>
+//
+// static void crash(void) {
+//	*(volatile int *)0 = 0;
+// }
+//
+// static void level1(void) {
+//	struct rlimit lim = {RLIM_INFINITY, RLIM_INFINITY};
+//	setrlimit(RLIMIT_CORE, &lim);
+//	crash();
+// }
+import "C"
+
+func main() {
+	C.level1()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+type key string
+
+// contexts keeps the contexts reachable after main returns to the
+// crash.
+var contexts = map[string]context.Context{}
+
+func main() {
+	inf := int64(syscall.RLIM_INFINITY)
+	lim := syscall.Rlimit{
+		Cur: uint64(inf),
+		Max: uint64(inf),
+	}
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &lim); err != nil {
+		panic(fmt.Sprintf("error setting rlimit: %v", err))
+	}
+
+	root, cancel := context.WithCancel(context.Background())
+	value := context.WithValue(root, key("user"), "gopher")
+	deadline, cancelDeadline := context.WithDeadline(value, time.Date(2030, 1, 2, 3, 4, 5, 6, time.UTC))
+	canceled, cancelCanceled := context.WithCancel(root)
+	cancelCanceled()
+	contexts["root"] = root
+	contexts["value"] = value
+	contexts["deadline"] = deadline
+	contexts["canceled"] = canceled
+	contexts["canceledValue"] = context.WithValue(canceled, key("n"), 1)
+
+	_ = *(*int)(nil)
+	cancel()
+	cancelDeadline()
+}
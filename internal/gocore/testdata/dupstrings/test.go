@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+type entry struct {
+	name  string
+	value []byte
+}
+
+type table struct {
+	entries []*entry
+}
+
+// tab holds entries whose names and values are separately allocated
+// copies of the same contents.
+var tab *table
+
+func main() {
+	inf := int64(syscall.RLIM_INFINITY)
+	lim := syscall.Rlimit{
+		Cur: uint64(inf),
+		Max: uint64(inf),
+	}
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &lim); err != nil {
+		panic(fmt.Sprintf("error setting rlimit: %v", err))
+	}
+
+	tab = &table{}
+	for i := 0; i < 10; i++ {
+		tab.entries = append(tab.entries, &entry{
+			name:  strings.Repeat("x", 20) + "name",
+			value: []byte(fmt.Sprintf("value %d", i%2)),
+		})
+	}
+
+	_ = *(*int)(nil)
+}
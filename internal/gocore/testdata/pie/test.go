@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+type T struct {
+	name string
+	next *T
+}
+
+// list is a global list for the test to find. In a position-independent
+// executable, its address and the pointers to it are relocated.
+var list *T
+
+// block blocks forever, for the test to find its goroutine's frames.
+//
+//go:noinline
+func block(c chan int) {
+	<-c
+}
+
+func main() {
+	inf := int64(syscall.RLIM_INFINITY)
+	lim := syscall.Rlimit{
+		Cur: uint64(inf),
+		Max: uint64(inf),
+	}
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &lim); err != nil {
+		panic(fmt.Sprintf("error setting rlimit: %v", err))
+	}
+
+	for i := 0; i < 3; i++ {
+		list = &T{name: fmt.Sprint("t", i), next: list}
+	}
+	go block(make(chan int))
+	runtime.Gosched()
+
+	_ = *(*int)(nil)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+type cache struct {
+	items map[int]string
+	ids   []int
+	buf   bytes.Buffer
+	sb    strings.Builder
+}
+
+// caches hold a map that grew and then emptied, a slice with unused
+// capacity, a mostly read buffer and a builder grown beyond its use.
+var caches = make([]*cache, 2)
+
+// lists are slices with unused capacity in a global array.
+var lists [3][]byte
+
+func main() {
+	inf := int64(syscall.RLIM_INFINITY)
+	lim := syscall.Rlimit{
+		Cur: uint64(inf),
+		Max: uint64(inf),
+	}
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &lim); err != nil {
+		panic(fmt.Sprintf("error setting rlimit: %v", err))
+	}
+
+	for i := range caches {
+		c := &cache{items: map[int]string{}}
+		for j := 0; j < 1000; j++ {
+			c.items[j] = "x"
+		}
+		for j := 0; j < 1000; j++ {
+			delete(c.items, j)
+		}
+		c.ids = make([]int, 10, 1000)
+		c.buf.Write(make([]byte, 1000))
+		c.buf.Next(900)
+		c.sb.Grow(4096)
+		c.sb.WriteString("hello")
+		caches[i] = c
+	}
+	for i := range lists {
+		lists[i] = make([]byte, 1, 64)
+	}
+
+	_ = *(*int)(nil)
+}
@@ -115,6 +115,28 @@ func (p *Process) DynamicType(t *Type, a core.Address) *Type {
 	}
 }
 
+// DynamicValue returns the concrete type stored in the interface type t at
+// address a, along with the address at which the concrete value lives.
+// Values the runtime stores directly in the interface's data word (pointers
+// and pointer-shaped types) live in the interface itself; all others live
+// in memory pointed to by the data word.
+// If the interface is nil, returns nil, 0.
+func (p *Process) DynamicValue(t *Type, a core.Address) (*Type, core.Address) {
+	dt := p.DynamicType(t, a)
+	if dt == nil {
+		return nil, 0
+	}
+	typPtr := p.proc.ReadPtr(a)
+	if t.Kind == KindIface {
+		typPtr = p.proc.ReadPtr(typPtr.Add(p.proc.PtrSize()))
+	}
+	data := a.Add(p.proc.PtrSize())
+	if ifaceIndir(typPtr, p) {
+		return dt, p.proc.ReadPtr(data)
+	}
+	return dt, data
+}
+
 // return the number of bytes of the variable int and its value,
 // which means the length of a name.
 func readNameLen(p *Process, a core.Address) (int64, int64) {
@@ -374,8 +396,25 @@ func (c typeChunk) String() string {
 }
 
 // typeHeap tries to label all the heap objects with types.
+// Objects that can't be typed because of missing or inconsistent
+// memory are left untyped, and recorded in Warnings.
 func (p *Process) typeHeap() {
 	p.initTypeHeap.Do(func() {
+		// typeHeap runs lazily, long after Core returned, so guard
+		// against inconsistencies the same way Core does.
+		defer func() {
+			e := recover()
+			if e == nil {
+				return
+			}
+			switch e.(type) {
+			case error, string:
+				p.warnf("giving up on typing heap objects: %v; the remaining objects are untyped", e)
+			default:
+				panic(e) // Not an error, re-panic it.
+			}
+		}()
+
 		// Type info for the start of each object. a.k.a. "0 offset" typings.
 		p.types = make([]typeInfo, p.nObj)
 
@@ -499,14 +538,28 @@ func (p *Process) typeHeap() {
 			}
 		}
 
+		// scan types the object at address a, skipping it if it
+		// reads memory missing from the core.
+		var skipped int
+		var skipErr error
+		scan := func(a core.Address, t *Type, r reader) {
+			err := catchReadError(func() { p.typeObject(a, t, r, add) })
+			if err != nil {
+				if skipped == 0 {
+					skipErr = err
+				}
+				skipped++
+			}
+		}
+
 		// Get typings starting at roots.
 		fr := &frameReader{p: p}
 		p.ForEachRoot(func(r *Root) bool {
 			if r.Frame != nil {
 				fr.live = r.Frame.Live
-				p.typeObject(r.Addr, r.Type, fr, add)
+				scan(r.Addr, r.Type, fr)
 			} else {
-				p.typeObject(r.Addr, r.Type, p.proc, add)
+				scan(r.Addr, r.Type, p.proc)
 			}
 			return true
 		})
@@ -521,9 +574,12 @@ func (p *Process) typeHeap() {
 				continue
 			}
 			for i := int64(0); i < c.r; i++ {
-				p.typeObject(c.a.Add(i*c.t.Size), c.t, p.proc, add)
+				scan(c.a.Add(i*c.t.Size), c.t, p.proc)
 			}
 		}
+		if skipped > 0 {
+			p.warnf("skipped typing %d objects that can't be read (first error: %v)", skipped, skipErr)
+		}
 
 		// Merge any interior typings with the 0-offset typing.
 		for i, chunks := range interior {
@@ -655,7 +711,8 @@ func (p *Process) typeObject(a core.Address, t *Type, r reader, add func(core.Ad
 				}
 			}
 			if directTyp.Kind != KindFunc && directTyp.Kind != KindPtr {
-				panic(fmt.Sprintf("type of direct interface, originally %s (kind %s), isn't a pointer: %s (kind %s)", typ, typ.Kind, directTyp, directTyp.Kind))
+				p.warnf("not typing %x: type of direct interface, originally %s (kind %s), isn't a pointer: %s (kind %s)", data, typ, typ.Kind, directTyp, directTyp.Kind)
+				return
 			}
 			break
 		}
@@ -685,7 +742,8 @@ func (p *Process) typeObject(a core.Address, t *Type, r reader, add func(core.Ad
 		pc := p.proc.ReadPtr(closure)
 		f := p.funcTab.find(pc)
 		if f == nil {
-			panic(fmt.Sprintf("can't find func for closure pc %x", pc))
+			p.warnf("not typing closure %x: can't find func for pc %x", closure, pc)
+			return
 		}
 		ft := f.closure
 		if ft == nil {